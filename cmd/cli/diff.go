@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffLine is a line of a diff: kind is ' ' for unchanged lines, '-' for
+// removed lines and '+' for added lines.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff returns the differences between the old and new contents of a file
+// in unified diff format, or "" if they are equal.
+func unifiedDiff(name string, old, new []byte) string {
+	lines := diffLines(splitLines(string(old)), splitLines(string(new)))
+	var b strings.Builder
+	// oldLine and newLine are the 1-based numbers of the next old and new lines.
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i, oldLine, newLine = i+1, oldLine+1, newLine+1
+			continue
+		}
+		// A hunk starts diffContext lines before the change and ends when
+		// there are more than 2*diffContext unchanged lines in a row.
+		start := max(i-diffContext, 0)
+		end, unchanged := i, 0
+		for ; end < len(lines) && unchanged <= 2*diffContext; end++ {
+			if lines[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		end -= max(unchanged-diffContext, 0)
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+		var hunk strings.Builder
+		for _, l := range lines[start:end] {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+			hunk.WriteByte(l.kind)
+			hunk.WriteString(l.text)
+			hunk.WriteByte('\n')
+		}
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", name, name)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), hunk.String())
+		oldLine, newLine = oldStart+oldCount, newStart+newCount
+		i = end
+	}
+	return b.String()
+}
+
+// hunkRange formats the line range of a hunk. An empty range starts at the
+// line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits text into lines, without line terminators.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns a shortest edit script from a to b, using the longest
+// common subsequence of their lines. Metadata files are small, so the quadratic
+// cost is not a concern.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
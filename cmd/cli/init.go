@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// initCollection implements "lbx init [flags] ROOT": it creates the metadata files
+// that the collection rooted at ROOT lacks: the collection metadata file, from
+// flags or by asking on the terminal, and a metadata file in every album. Existing
+// files are never overwritten.
+func initCollection(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var cm metadata.CollectionMetadata
+	fs.StringVar(&cm.Name, "name", "", "name of the collection")
+	fs.StringVar(&cm.Author, "author", "", "author of the collection")
+	fs.StringVar(&cm.Language, "language", "", "BCP 47 code of the default language of the collection (e.g., en)")
+	fs.StringVar(&cm.URL, "url", "", "base URL of the collection")
+	fs.StringVar(&cm.Storage, "storage", "", "where the collection is published: a directory or an s3://BUCKET URL")
+	fs.StringVar(&cm.Server, "server", "", "base URL of the lbxd server through which the collection is published")
+	fs.StringVar(&cm.S3AccessCode, "s3-access-code", "", "S3 access code reference (env:NAME or file:PATH)")
+	fs.StringVar(&cm.S3SecretKey, "s3-secret-key", "", "S3 secret key reference (env:NAME or file:PATH)")
+	disabled := fs.Bool("disabled", false, "create metadata files with enabled=false, so that nothing is published")
+	dates := fs.Bool("dates", false, "add the range of EXIF capture dates of its photos to the title of each album")
+	dryRun := fs.Bool("dry-run", false, "print the files that would be created without writing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lbx init [flags] <root>")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	root := pos[0]
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		fatalf("%s is not a directory", root)
+	}
+
+	var files []metadata.NewFile
+	version := metadata.CurrentVersion
+	rootFile := filepath.Join(root, "metadata.json")
+	if _, err := os.Stat(rootFile); err == nil {
+		// Album files are created in the version of the existing collection.
+		existing, err := metadata.ReadCollectionMetadata(root)
+		if err != nil {
+			fatalf("invalid collection metadata:\n%v", err)
+		}
+		version = existing.Version
+	} else if os.IsNotExist(err) {
+		cm.Enabled = !*disabled
+		askCollection(&cm)
+		data, err := metadata.NewCollectionFile(&cm)
+		if err != nil {
+			fatalf("invalid collection metadata:\n%v", err)
+		}
+		files = append(files, metadata.NewFile{Path: rootFile, Data: data})
+	} else {
+		fatalf("%v", err)
+	}
+	albums, err := metadata.NewAlbumFiles(root, version, !*disabled, *dates)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	files = append(files, albums...)
+
+	if len(files) == 0 {
+		fmt.Println("All metadata files exist.")
+		return
+	}
+	if *dryRun {
+		for _, f := range files {
+			fmt.Printf("%s:\n%s", f.Path, f.Data)
+		}
+		fmt.Printf("Would create %d files.\n", len(files))
+		return
+	}
+	for _, f := range files {
+		if err := createFile(f); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Created %s\n", f.Path)
+	}
+	fmt.Printf("Created %d files.\n", len(files))
+	if _, err := metadata.ReadMetadata(root); err != nil {
+		warnf("metadata is invalid:\n%v", err)
+	}
+}
+
+// createFile creates a new metadata file, failing if it exists.
+func createFile(f metadata.NewFile) error {
+	out, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists", f.Path)
+	} else if err != nil {
+		return err
+	}
+	if _, err := out.Write(f.Data); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// askCollection asks on the terminal for the fields of the collection metadata
+// that are required and not set by flags. It does nothing if the standard input
+// is not a terminal.
+func askCollection(cm *metadata.CollectionMetadata) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	in := bufio.NewReader(os.Stdin)
+	if cm.Name == "" {
+		cm.Name = ask(in, "Collection name")
+	}
+	if cm.URL == "" {
+		cm.URL = ask(in, "Collection URL (e.g., https://janesmith.com/photos)")
+	}
+	if cm.Storage == "" && cm.Server == "" {
+		cm.Storage = ask(in, "Storage (a directory or an s3://BUCKET URL)")
+	}
+	if cm.UsesS3() {
+		if cm.S3AccessCode == "" {
+			cm.S3AccessCode = ask(in, "S3 access code (env:NAME or file:PATH)")
+		}
+		if cm.S3SecretKey == "" {
+			cm.S3SecretKey = ask(in, "S3 secret key (env:NAME or file:PATH)")
+		}
+	}
+}
+
+// ask prints a question and returns the answer, without surrounding whitespace.
+func ask(in *bufio.Reader, question string) string {
+	fmt.Printf("%s: ", question)
+	answer, err := in.ReadString('\n')
+	if err != nil && answer == "" {
+		fatalf("no answer")
+	}
+	return strings.TrimSpace(answer)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
@@ -16,6 +17,18 @@ func main() {
 	switch subcommand {
 	case "version":
 		printVersion()
+	case "scan":
+		scan(os.Args[2:])
+	case "sync":
+		syncCollection(os.Args[2:])
+	case "metadata":
+		metadataCommand(os.Args[2:])
+	case "validate":
+		validate(os.Args[2:])
+	case "schema":
+		printSchema(os.Args[2:])
+	case "init":
+		initCollection(os.Args[2:])
 	default:
 		fmt.Println("Invalid subcommand")
 		os.Exit(1)
@@ -25,3 +38,32 @@ func main() {
 func printVersion() {
 	fmt.Println("0.01")
 }
+
+// parseArgs parses flags in args, allowing flags and positional arguments to be
+// interspersed (e.g., "lbx scan ROOT --json"). Returns the positional arguments.
+// Exits on a parse error, following the flag.ExitOnError convention.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// fatalf prints an error message to stderr and exits with a non-zero status.
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "lbx: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// warnf prints a warning to stderr.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "lbx: warning: "+format+"\n", args...)
+}
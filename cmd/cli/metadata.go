@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// metadataCommand implements "lbx metadata SUBCOMMAND": operations on the
+// metadata files of a collection.
+func metadataCommand(args []string) {
+	if len(args) == 0 {
+		fatalf("usage: lbx metadata upgrade [--dry-run] <root>")
+	}
+	switch args[0] {
+	case "upgrade":
+		upgradeMetadata(args[1:])
+	default:
+		fatalf("unknown metadata subcommand %q", args[0])
+	}
+}
+
+// upgradeMetadata implements "lbx metadata upgrade [--dry-run] ROOT": it rewrites
+// every metadata file of the collection rooted at ROOT in the current version of
+// the metadata format, printing the changes as a diff.
+func upgradeMetadata(args []string) {
+	fs := flag.NewFlagSet("metadata upgrade", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the changes without writing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lbx metadata upgrade [--dry-run] <root>")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	from, files, err := metadata.Upgrade(pos[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if len(files) == 0 {
+		fmt.Printf("Metadata is already at version %s.\n", metadata.CurrentVersion)
+		return
+	}
+	for _, f := range files {
+		fmt.Print(unifiedDiff(f.Path, f.Old, f.New))
+	}
+	if *dryRun {
+		fmt.Printf("Would upgrade %d files from version %s to %s.\n", len(files), from, metadata.CurrentVersion)
+		return
+	}
+	// The collection metadata file comes last, so that an interrupted upgrade
+	// can be resumed by running it again.
+	for _, f := range files {
+		fi, err := os.Stat(f.Path)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if err := os.WriteFile(f.Path, f.New, fi.Mode().Perm()); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	fmt.Printf("Upgraded %d files from version %s to %s.\n", len(files), from, metadata.CurrentVersion)
+	if _, err := metadata.ReadMetadata(pos[0]); err != nil {
+		warnf("upgraded metadata is invalid: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// scanAlbum is the effective (post-inheritance) metadata of one album, as printed by "lbx scan".
+type scanAlbum struct {
+	Path      string   `json:"path"`
+	Title     string   `json:"title"`
+	Enabled   bool     `json:"enabled"`
+	SortOrder string   `json:"sort_order"`
+	Tags      []string `json:"tags"`
+	Access    []string `json:"access"`
+	Filter    []string `json:"filter"`
+}
+
+// scan implements "lbx scan [--json] ROOT": it reads the metadata of the collection
+// rooted at ROOT and prints the resolved metadata of every album.
+func scan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print albums as a JSON array")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lbx scan [--json] <root>")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	mdList, err := metadata.ReadMetadata(pos[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+	albums := make([]scanAlbum, 0, len(mdList))
+	for _, md := range mdList {
+		albums = append(albums, scanAlbum{
+			Path:      md.Path,
+			Title:     md.Title,
+			Enabled:   md.Enabled,
+			SortOrder: md.SortOrder,
+			Tags:      nonNil(md.Tags),
+			Access:    nonNil(md.Access),
+			Filter:    nonNil(md.Filter),
+		})
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(albums); err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+	printAlbums(os.Stdout, albums)
+}
+
+// printAlbums prints albums in a human-readable form.
+func printAlbums(w io.Writer, albums []scanAlbum) {
+	for i, a := range albums {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", a.Path)
+		fmt.Fprintf(w, "  title:      %s\n", a.Title)
+		fmt.Fprintf(w, "  enabled:    %v\n", a.Enabled)
+		fmt.Fprintf(w, "  sort order: %s\n", a.SortOrder)
+		fmt.Fprintf(w, "  tags:       %s\n", listOrNone(a.Tags))
+		// An empty access list means public access.
+		if len(a.Access) == 0 {
+			fmt.Fprintf(w, "  access:     (public)\n")
+		} else {
+			fmt.Fprintf(w, "  access:     %s\n", strings.Join(a.Access, ", "))
+		}
+		fmt.Fprintf(w, "  filter:     %s\n", listOrNone(a.Filter))
+	}
+}
+
+// listOrNone formats a list of strings for display.
+func listOrNone(l []string) string {
+	if len(l) == 0 {
+		return "(none)"
+	}
+	return strings.Join(l, ", ")
+}
+
+// nonNil returns l, or an empty list if l is nil, so that JSON output has [] rather than null.
+func nonNil(l []string) []string {
+	if l == nil {
+		return []string{}
+	}
+	return l
+}
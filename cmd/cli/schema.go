@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// printSchema implements "lbx schema [collection|album|sidecar]": it prints the
+// JSON Schema of collection or album metadata files or photo sidecar files, or
+// by default of any metadata file, for use by editors (e.g., with "json.schemas" in VS Code).
+func printSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: lbx schema [%s|%s|%s]\n", metadata.SchemaCollection, metadata.SchemaAlbum, metadata.SchemaSidecar)
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kind := ""
+	if len(pos) == 1 {
+		kind = pos[0]
+	}
+	schema, err := metadata.Schema(kind)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Println(string(schema))
+}
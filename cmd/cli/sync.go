@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+	"github.com/maxpoletto/lbx/internal/client/syncer"
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// changeSymbols are the prefixes of the change lines printed by "lbx sync".
+var changeSymbols = map[syncer.Action]string{
+	syncer.Add:    "+",
+	syncer.Update: "~",
+	syncer.Remove: "-",
+}
+
+// syncCollection implements "lbx sync [--dry-run] [--storage LOCATION | --server URL] ROOT": it
+// publishes the selected media of the collection rooted at ROOT, uploading only new or changed
+// files, either directly to storage or through the ingest API of an lbxd server.
+func syncCollection(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print changes without applying them")
+	location := fs.String("storage", "", "storage location (directory, file:// or s3:// URL); "+
+		"default is the storage of the collection metadata")
+	server := fs.String("server", "", "URL of an lbxd server to publish to instead of storage; "+
+		"default is the server of the collection metadata. The admin token is read from LBX_ADMIN_TOKEN")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lbx sync [--dry-run] [--storage <location> | --server <url>] <root>")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	// Use an absolute root so that hash cache entries do not depend on the working directory.
+	root, err := filepath.Abs(pos[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	cm, err := metadata.ReadCollectionMetadata(root)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	albums, err := metadata.ReadMetadata(root)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	for _, w := range cm.Warnings {
+		warnf("%s", w)
+	}
+	if *server != "" && *location != "" {
+		fatalf("--storage and --server are mutually exclusive")
+	}
+	// Explicit storage takes precedence over the server of the metadata.
+	if *server == "" && *location == "" {
+		*server = cm.Server
+	}
+	var remote syncer.Remote
+	switch {
+	case *server != "":
+		c, err := ingest.NewClient(*server, os.Getenv("LBX_ADMIN_TOKEN"), nil)
+		if err != nil {
+			fatalf("%v (set LBX_ADMIN_TOKEN to a token from \"lbxd admin create\")", err)
+		}
+		remote = syncer.NewServerRemote(c, albums, cm.MaxSize, cm.Language)
+	default:
+		remote = storageRemote(root, cm, *location)
+	}
+
+	var hc *syncer.HashCache
+	if dir, err := os.UserCacheDir(); err == nil {
+		hc = syncer.LoadHashCache(filepath.Join(dir, "lbx", "hashes.json"))
+	}
+	plan, err := syncer.MakePlan(root, albums, remote, hc)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	for _, key := range plan.Skipped {
+		warnf("skipping %s: unsupported format", key)
+	}
+	if err := hc.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "lbx: failed to save hash cache: %v\n", err)
+	}
+
+	printChange := func(c syncer.Change) {
+		fmt.Printf("%s %s\n", changeSymbols[c.Action], c.Item.Key())
+	}
+	if *dryRun {
+		for _, c := range plan.Changes {
+			printChange(c)
+		}
+	} else if err := syncer.Apply(remote, plan, printChange); err != nil {
+		fatalf("%v", err)
+	}
+	added, updated, removed := plan.Counts()
+	verb := "synced"
+	if *dryRun {
+		verb = "would sync"
+	}
+	fmt.Printf("%s: %d added, %d updated, %d removed, %d unchanged\n", verb, added, updated, removed, plan.Unchanged)
+}
+
+// storageRemote opens the remote for publishing directly to the storage at location
+// or, if location is empty, at the storage location of the collection metadata cm.
+func storageRemote(root string, cm *metadata.CollectionMetadata, location string) syncer.Remote {
+	if location == "" {
+		location = cm.Storage
+		// A relative storage directory in the metadata is relative to the collection root.
+		if location != "" && !strings.Contains(location, "://") && !filepath.IsAbs(location) {
+			location = filepath.Join(root, location)
+		}
+	}
+	if location == "" {
+		fatalf("no storage location: set \"storage\" in %s, or use --storage or --server", filepath.Join(root, "metadata.json"))
+	}
+	var accessCode, secretKey string
+	if !metadata.IsLocalStorage(location) {
+		var err error
+		if accessCode, secretKey, err = cm.S3Credentials(); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	s, err := storage.Open(location, accessCode, secretKey)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	remote, err := syncer.NewStorageRemote(s)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return remote
+}
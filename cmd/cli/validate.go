@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// validate implements "lbx validate ROOT": it checks every metadata file of the
+// collection rooted at ROOT and prints each problem found, one per line, as
+// "PATH:LINE:COLUMN: FIELD: MESSAGE". Exits with status 1 if there are problems.
+func validate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lbx validate <root>")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	albums, err := metadata.ReadMetadata(pos[0])
+	if err != nil {
+		var errs metadata.Errors
+		if !errors.As(err, &errs) {
+			fatalf("%v", err)
+		}
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+		s := "s"
+		if len(errs) == 1 {
+			s = ""
+		}
+		fatalf("%d problem%s found", len(errs), s)
+	}
+	if cm, err := metadata.ReadCollectionMetadata(pos[0]); err == nil {
+		for _, w := range cm.Warnings {
+			warnf("%s", w)
+		}
+	}
+	fmt.Printf("Metadata is valid: %d albums.\n", len(albums))
+}
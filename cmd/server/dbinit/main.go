@@ -1,40 +1,46 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"os"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/maxpoletto/lbx/internal/server/db"
 )
 
-// Invoke with `go run internal/server/dbinit.go cmd/server/schema.sql /tmp/lbx.db`
+// Invoke with `go run ./cmd/server/dbinit up /tmp/lbx.db`
 
 func main() {
-	// Read schema filename and db filename from command line
-	if len(os.Args) != 3 {
-		log.Fatalf("Usage: %s <schema.sql> <database.db>", os.Args[0])
-	}
-
-	// Read the schema
-	schema, err := os.ReadFile(os.Args[1])
-	if err != nil {
-		log.Fatalf("Failed to read schema.sql: %v", err)
+	// Read subcommand and db filename from command line
+	if len(os.Args) != 3 || (os.Args[1] != "up" && os.Args[1] != "version") {
+		log.Fatalf("Usage: %s up|version <database.db>", os.Args[0])
 	}
 
 	// Open a connection to the SQLite database
-	db, err := sql.Open("sqlite3", os.Args[2])
+	conn, err := db.Open(os.Args[2])
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
-	defer db.Close()
-
-	// Execute the schema
-	_, err = db.Exec(string(schema))
-	if err != nil {
-		log.Fatalf("Failed to execute schema: %v", err)
+	defer conn.Close()
+
+	switch os.Args[1] {
+	case "up":
+		// Apply pending migrations
+		from, to, err := db.Migrate(conn)
+		if err != nil {
+			log.Fatalf("Failed to migrate schema: %v", err)
+		}
+		if from == to {
+			fmt.Printf("Schema is up to date (version %d)\n", to)
+		} else {
+			fmt.Printf("Schema migrated from version %d to %d\n", from, to)
+		}
+	case "version":
+		// Report current and supported versions
+		v, err := db.Version(conn)
+		if err != nil {
+			log.Fatalf("Failed to read schema version: %v", err)
+		}
+		fmt.Printf("Schema version %d (latest supported: %d)\n", v, db.LatestVersion())
 	}
-
-	fmt.Println("Schema created successfully")
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+const adminUsage = `Usage:
+  lbxd admin create [-db DB] NAME
+  lbxd admin list [-db DB]
+  lbxd admin revoke [-db DB] NAME`
+
+// admin implements "lbxd admin": minting, listing and revoking the admin tokens
+// with which lbx publishes through the ingest API.
+func admin(args []string) {
+	if len(args) == 0 {
+		log.Fatal(adminUsage)
+	}
+	fs := flag.NewFlagSet("admin "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", "lbx.db", "path of the SQLite database")
+	fs.Parse(args[1:])
+
+	conn := openDB(*dbPath)
+	defer conn.Close()
+	r := repo.New(conn)
+
+	switch args[0] {
+	case "create":
+		if fs.NArg() != 1 {
+			log.Fatal(adminUsage)
+		}
+		token, err := r.CreateAdminToken(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(token)
+		fmt.Fprintf(os.Stderr, "Admin token %q created; it cannot be shown again. Use it with LBX_ADMIN_TOKEN=%s lbx sync --server URL\n",
+			fs.Arg(0), token)
+	case "list":
+		tokens, err := r.AdminTokens()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCREATED")
+		for _, t := range tokens {
+			fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Created.Local().Format(time.DateTime))
+		}
+		w.Flush()
+	case "revoke":
+		if fs.NArg() != 1 {
+			log.Fatal(adminUsage)
+		}
+		if err := r.RevokeAdminToken(fs.Arg(0)); errors.Is(err, repo.ErrNotFound) {
+			log.Fatalf("no admin token %q", fs.Arg(0))
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Revoked %s\n", fs.Arg(0))
+	default:
+		log.Fatal(adminUsage)
+	}
+}
@@ -1,16 +1,77 @@
 package main
 
 import (
-	"fmt"
+	"database/sql"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/api"
+	"github.com/maxpoletto/lbx/internal/server/db"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
 )
 
 func main() {
-	http.HandleFunc("/", handler)
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "share":
+			share(os.Args[2:])
+			return
+		case "admin":
+			admin(os.Args[2:])
+			return
+		}
+	}
+
+	dbPath := flag.String("db", "lbx.db", "path of the SQLite database")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	location := flag.String("storage", "", "storage location of renditions (directory or s3://BUCKET?...); "+
+		"S3 credentials are read from LBX_S3_ACCESS_KEY and LBX_S3_SECRET_KEY")
+	uploadTTL := flag.Duration("upload-ttl", 24*time.Hour, "time after which idle resumable uploads are deleted")
+	flag.Parse()
+	if *location == "" {
+		log.Fatal("-storage is required")
+	}
+	if *uploadTTL <= 0 {
+		log.Fatal("-upload-ttl must be positive")
+	}
+
+	conn := openDB(*dbPath)
+	defer conn.Close()
+	store, err := storage.Open(*location, os.Getenv("LBX_S3_ACCESS_KEY"), os.Getenv("LBX_S3_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := api.New(repo.New(conn), store)
+	go expireUploads(srv, *uploadTTL)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
+
+// expireUploads periodically deletes the resumable uploads idle for longer than ttl.
+func expireUploads(srv *api.Server, ttl time.Duration) {
+	for {
+		if n, err := srv.ExpireUploads(time.Now().Add(-ttl)); err != nil {
+			log.Printf("failed to expire uploads: %v", err)
+		} else if n > 0 {
+			log.Printf("expired %d idle uploads", n)
+		}
+		time.Sleep(min(ttl, time.Hour))
+	}
 }
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Hello, World!")
+// openDB opens the database at path, exiting on error.
+func openDB(path string) *sql.DB {
+	conn, err := db.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Refuse to run against a schema this binary does not understand.
+	if err := db.Check(conn); err != nil {
+		log.Fatal(err)
+	}
+	return conn
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+const shareUsage = `Usage:
+  lbxd share create [-db DB] [-expires DURATION] [-views N] album|folder PATH
+  lbxd share list [-db DB]
+  lbxd share revoke [-db DB] TOKEN`
+
+// share implements "lbxd share": minting, listing and revoking share links.
+func share(args []string) {
+	if len(args) == 0 {
+		log.Fatal(shareUsage)
+	}
+	fs := flag.NewFlagSet("share "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", "lbx.db", "path of the SQLite database")
+	expires := fs.Duration("expires", 7*24*time.Hour, "lifetime of the link")
+	views := fs.Int("views", 0, "number of times the link may be opened (0 for no limit)")
+	fs.Parse(args[1:])
+
+	conn := openDB(*dbPath)
+	defer conn.Close()
+	r := repo.New(conn)
+
+	switch args[0] {
+	case "create":
+		if fs.NArg() != 2 {
+			log.Fatal(shareUsage)
+		}
+		if *expires <= 0 {
+			log.Fatal("-expires must be positive")
+		}
+		l := &repo.ShareLink{Expires: time.Now().Add(*expires), MaxViews: *views}
+		path := strings.Trim(fs.Arg(1), "/")
+		switch fs.Arg(0) {
+		case "album":
+			a, err := r.AlbumByPath(path)
+			if err != nil {
+				log.Fatalf("album %q: %v", path, err)
+			}
+			l.AlbumID = a.ID
+		case "folder":
+			f, err := r.FolderByPath(path)
+			if err != nil {
+				log.Fatalf("folder %q: %v", path, err)
+			}
+			l.FolderID = f.ID
+		default:
+			log.Fatal(shareUsage)
+		}
+		if err := r.CreateShareLink(l); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(l.Token)
+		fmt.Fprintf(os.Stderr, "Share link for %s %q expires %s; open with ?share=%s\n",
+			fs.Arg(0), l.Path, l.Expires.Local().Format(time.DateTime), l.Token)
+	case "list":
+		links, err := r.ShareLinks()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TOKEN\tKIND\tPATH\tEXPIRES\tVIEWS\tSTATUS")
+		now := time.Now()
+		for _, l := range links {
+			kind := "album"
+			if l.FolderID != 0 {
+				kind = "folder"
+			}
+			limit := "-"
+			if l.MaxViews > 0 {
+				limit = fmt.Sprint(l.MaxViews)
+			}
+			status := "active"
+			switch {
+			case l.Revoked:
+				status = "revoked"
+			case !l.Expires.After(now):
+				status = "expired"
+			case l.MaxViews > 0 && l.Views >= l.MaxViews:
+				status = "used up"
+			}
+			fmt.Fprintf(w, "%s\t%s\t/%s\t%s\t%d/%s\t%s\n",
+				l.Token, kind, l.Path, l.Expires.Local().Format(time.DateTime), l.Views, limit, status)
+		}
+		w.Flush()
+	case "revoke":
+		if fs.NArg() != 1 {
+			log.Fatal(shareUsage)
+		}
+		if err := r.RevokeShareLink(fs.Arg(0)); errors.Is(err, repo.ErrNotFound) {
+			log.Fatalf("no share link %s", fs.Arg(0))
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Revoked %s\n", fs.Arg(0))
+	default:
+		log.Fatal(shareUsage)
+	}
+}
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// accessRule is a compiled per-photo access entry "FILENAME:KEY".
+type accessRule struct {
+	re  *regexp.Regexp
+	key string
+}
+
+// isMediaAccessEntry returns true if an access entry has the per-photo form "FILENAME:KEY".
+func isMediaAccessEntry(entry string) bool {
+	return strings.Contains(entry, ":")
+}
+
+// compileAccessEntry compiles a per-photo access entry "FILENAME:KEY". FILENAME is
+// a filename or a regexp, which must match the entire filename. KEY follows the
+// last colon, so FILENAME may itself contain colons.
+func compileAccessEntry(entry string) (accessRule, error) {
+	i := strings.LastIndex(entry, ":")
+	pattern, key := entry[:i], entry[i+1:]
+	if pattern == "" || key == "" {
+		return accessRule{}, fmt.Errorf("invalid access entry: %s", entry)
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return accessRule{}, fmt.Errorf("invalid access regexp in %s: %v", entry, err)
+	}
+	return accessRule{re: re, key: key}, nil
+}
+
+// compileMediaAccess compiles the per-photo entries of an access list, ignoring
+// plain keys. If entries are invalid, the error is an Errors listing each of them.
+func compileMediaAccess(entries []string) ([]accessRule, error) {
+	var rules []accessRule
+	var errs Errors
+	for i, entry := range entries {
+		if !isMediaAccessEntry(entry) {
+			continue
+		}
+		rule, err := compileAccessEntry(entry)
+		if err != nil {
+			errs = append(errs, fieldErrorf(fmt.Sprintf("access[%d]", i), "%v", err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return rules, nil
+}
+
+// AlbumAccess returns the access keys that apply to the whole album: the
+// entries of Access that are not per-photo entries.
+func (m *AlbumMetadata) AlbumAccess() []string {
+	keys := []string{}
+	for _, entry := range m.Access {
+		if !isMediaAccessEntry(entry) {
+			keys = append(keys, entry)
+		}
+	}
+	return keys
+}
+
+// checkMediaKey returns the problem with restricting photos to key, if key is
+// not granted access to the album: viewers with other keys never see the album,
+// and thus the photos. Every key has access to public albums. what and photos
+// describe the restriction and the photos for the message. The album metadata
+// must be merged with that of its parents.
+func (m *AlbumMetadata) checkMediaKey(key, what, photos string) *Error {
+	album := m.AlbumAccess()
+	if len(album) == 0 || slices.Contains(album, key) {
+		return nil
+	}
+	return fieldErrorf("access", "%s restricts %s to key %s, which has no access to the album (album access: %s)",
+		what, photos, key, strings.Join(album, ", "))
+}
+
+// checkMediaAccess returns the problems with the keys of the per-photo access
+// entries of the album metadata (see checkMediaKey).
+func (m *AlbumMetadata) checkMediaAccess() Errors {
+	var errs Errors
+	for _, entry := range m.Access {
+		if !isMediaAccessEntry(entry) {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if err := m.checkMediaKey(entry[i+1:], "entry "+entry, "photos "+entry[:i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// MediaAccess returns the access keys to which the photo with the given filename
+// is restricted: the keys of all per-photo entries that match it and of its
+// sidecar, if any. An empty list means that the photo has the access of its album.
+func (m *AlbumMetadata) MediaAccess(name string) ([]string, error) {
+	if m.mediaAccess == nil {
+		rules, err := compileMediaAccess(m.Access)
+		if err != nil {
+			return nil, err
+		}
+		m.mediaAccess = rules
+	}
+	keys := []string{}
+	for _, r := range m.mediaAccess {
+		if r.re.MatchString(name) {
+			keys = append(keys, r.key)
+		}
+	}
+	if sc := m.sidecars[name]; sc != nil {
+		keys = append(keys, sc.Access...)
+	}
+	return mergeLists(keys, nil), nil
+}
@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMediaAccess(t *testing.T) {
+	am, err := ParseAlbumMetadata([]byte(`{
+		"title": "Family",
+		"access": ["family", "IMG_0001.jpg:parents", "IMG_00[0-9]\\d\\.jpg:grandma", "a:b.jpg:alice"]
+	}`), true)
+	if err != nil {
+		t.Fatalf("ParseAlbumMetadata() error = %v", err)
+	}
+	if got := am.AlbumAccess(); !reflect.DeepEqual(got, []string{"family"}) {
+		t.Errorf("AlbumAccess() = %v, want [family]", got)
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"IMG_0001.jpg", []string{"grandma", "parents"}},
+		{"IMG_0002.jpg", []string{"grandma"}},
+		{"IMG_0100.jpg", []string{}},
+		{"xIMG_0001.jpg", []string{}},
+		{"a:b.jpg", []string{"alice"}},
+	}
+	for _, tt := range tests {
+		got, err := am.MediaAccess(tt.name)
+		if err != nil {
+			t.Fatalf("MediaAccess(%s) error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MediaAccess(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Metadata built without ParseAlbumMetadata compiles entries on first use.
+	am = &AlbumMetadata{CommonMetadata: CommonMetadata{Access: []string{"x.jpg:bob"}}}
+	if got, err := am.MediaAccess("x.jpg"); err != nil || !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Errorf("MediaAccess(x.jpg) = %v, %v, want [bob]", got, err)
+	}
+}
+
+func TestCheckMediaAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		access []string
+		want   []string
+	}{
+		{"public album", []string{"IMG.jpg:family"}, nil},
+		{"granted key", []string{"family", "friends", "IMG.jpg:family"}, nil},
+		{"ungranted key", []string{"friends", "IMG.jpg:family", "a:b.jpg:friends"}, []string{
+			"access: entry IMG.jpg:family restricts photos IMG.jpg to key family, which has no access to the album (album access: friends)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := &AlbumMetadata{CommonMetadata: CommonMetadata{Access: tt.access}}
+			var got []string
+			for _, e := range am.checkMediaAccess() {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkMediaAccess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccessEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		album   bool
+		wantErr bool
+	}{
+		{"plain keys outside album", `{"access": ["family"]}`, false, false},
+		{"per-photo entry outside album", `{"access": ["IMG_1.jpg:family"]}`, false, true},
+		{"per-photo entry", `{"title": "A", "access": ["IMG_1.jpg:family"]}`, true, false},
+		{"empty key", `{"title": "A", "access": ["IMG_1.jpg:"]}`, true, true},
+		{"empty filename", `{"title": "A", "access": [":family"]}`, true, true},
+		{"invalid regexp", `{"title": "A", "access": ["*.jpg:family"]}`, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAlbumMetadata([]byte(tt.input), tt.album)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAlbumMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := ParseCollectionMetadata([]byte(`{
+		"version": "1",
+		"name": "My Collection",
+		"url": "https://example.com/photos",
+		"s3_access_code": "ACCESSCODE123",
+		"s3_secret_key": "SECRETKEY123",
+		"access": ["IMG_1.jpg:family"]
+	}`))
+	if err == nil {
+		t.Errorf("ParseCollectionMetadata() with per-photo access entry error = nil, want error")
+	}
+}
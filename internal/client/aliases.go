@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// checkAlias checks that an alias is a normalized album path: a non-empty,
+// slash-separated path relative to the collection root, without empty, "." or
+// ".." components (and so without leading or trailing slash). Album paths on the
+// server have the same form.
+func checkAlias(alias string) error {
+	switch {
+	case alias == "":
+		return fmt.Errorf("empty alias")
+	case strings.HasPrefix(alias, "/"):
+		return fmt.Errorf("invalid alias %q: must be relative to the collection root, without leading slash", alias)
+	case strings.HasSuffix(alias, "/"):
+		return fmt.Errorf("invalid alias %q: trailing slash", alias)
+	}
+	for _, part := range strings.Split(alias, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid alias %q: empty, \".\" or \"..\" path component", alias)
+		}
+	}
+	return nil
+}
+
+// checkAliases checks that the aliases of the albums of the collection rooted at
+// root, which are sorted by (relative) path, are unique and differ from the paths
+// of all albums and folders. Each problem names both albums (or the album and
+// folder) involved; the album that comes first keeps a disputed alias.
+func checkAliases(root string, albums []*AlbumMetadata) Errors {
+	// paths maps the slash-separated paths of albums and folders to a description.
+	paths := map[string]string{}
+	for _, md := range albums {
+		p := filepath.ToSlash(md.Path)
+		paths[p] = "the path of album " + p
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, ok := paths[dir]; !ok {
+				paths[dir] = "the path of folder " + dir
+			}
+		}
+	}
+	var errs Errors
+	owners := map[string]string{}
+	for _, md := range albums {
+		p := filepath.ToSlash(md.Path)
+		for i, alias := range md.Aliases {
+			var conflict string
+			if what, ok := paths[alias]; ok {
+				conflict = what
+			} else if owner, ok := owners[alias]; ok && owner == p {
+				conflict = "listed twice"
+			} else if ok {
+				conflict = "also an alias of album " + owner
+			} else {
+				owners[alias] = p
+				continue
+			}
+			errs = append(errs, &Error{
+				Path:  filepath.Join(root, md.Path, "metadata.json"),
+				Field: fmt.Sprintf("aliases[%d]", i),
+				Err:   fmt.Errorf("alias %q of album %s is %s", alias, p, conflict),
+			})
+		}
+	}
+	return errs
+}
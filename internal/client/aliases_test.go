@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckAlias(t *testing.T) {
+	tests := []struct {
+		alias   string
+		wantErr bool
+	}{
+		{"paris", false},
+		{"2019/paris", false},
+		{".hidden/..paris", false},
+		{"", true},
+		{"/paris", true},
+		{"paris/", true},
+		{"2019//paris", true},
+		{"./paris", true},
+		{"2019/../paris", true},
+		{"..", true},
+	}
+	for _, tt := range tests {
+		if err := checkAlias(tt.alias); (err != nil) != tt.wantErr {
+			t.Errorf("checkAlias(%q) error = %v, wantErr %t", tt.alias, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadMetadataAliases(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	if err := os.Mkdir(filepath.Join(rootDir, "europe"), 0755); err != nil {
+		t.Fatalf("Failed to create subdir: %v", err)
+	}
+	initAlbum(t, rootDir, "europe/paris", `{"title": "Paris", "aliases": ["paris", "france/paris"]}`)
+	initAlbum(t, rootDir, "oslo", `{"title": "Oslo", "aliases": ["norway/oslo"]}`)
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if got := mdList[0].Aliases; !reflect.DeepEqual(got, []string{"paris", "france/paris"}) {
+		t.Errorf("Aliases = %v, want [paris france/paris]", got)
+	}
+
+	// Conflicting aliases name both albums, or the album and the folder.
+	initAlbum(t, rootDir, "europe/rome", `{"title": "Rome", "aliases": ["paris", "europe", "rome", "rome"]}`)
+	createFile(t, filepath.Join(rootDir, "oslo"), "metadata.json", `{"title": "Oslo", "aliases": ["europe/rome", "/oslo"]}`)
+	_, err = ReadMetadata(rootDir)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("ReadMetadata() error = %v, want Errors", err)
+	}
+	oslo := filepath.Join(rootDir, "oslo", "metadata.json")
+	rome := filepath.Join(rootDir, "europe", "rome", "metadata.json")
+	want := []string{
+		oslo + ":1:46: aliases[1]: invalid alias \"/oslo\": must be relative to the collection root, without leading slash",
+		rome + `: aliases[0]: alias "paris" of album europe/rome is also an alias of album europe/paris`,
+		rome + `: aliases[1]: alias "europe" of album europe/rome is the path of folder europe`,
+		rome + `: aliases[3]: alias "rome" of album europe/rome is listed twice`,
+	}
+	got := []string{}
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadMetadata() errors = %q, want %q", got, want)
+	}
+
+	// An alias may not be the path of another album.
+	createFile(t, filepath.Join(rootDir, "oslo"), "metadata.json", `{"title": "Oslo", "aliases": ["europe/rome"]}`)
+	createFile(t, filepath.Join(rootDir, "europe", "rome"), "metadata.json", `{"title": "Rome"}`)
+	_, err = ReadMetadata(rootDir)
+	if err == nil || err.Error() != oslo+`: aliases[0]: alias "europe/rome" of album oslo is the path of album europe/rome` {
+		t.Errorf("ReadMetadata() error = %v, want conflict with album europe/rome", err)
+	}
+}
@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credential references. A credential field of the collection metadata
+// (s3_access_code, s3_secret_key) may hold a reference instead of the secret itself:
+//
+//	env:NAME   the value of the environment variable NAME
+//	file:PATH  the contents of the file PATH, without trailing whitespace
+//
+// Literal credentials are deprecated: metadata.json lives in the collection
+// tree, which is typically copied and backed up.
+const (
+	envPrefix  = "env:"
+	filePrefix = "file:"
+)
+
+// checkCredential checks the syntax of a credential field. Returns a deprecation
+// warning for literal credentials.
+func checkCredential(field, value string) (warning string, err error) {
+	switch {
+	case value == "":
+		return "", nil
+	case strings.HasPrefix(value, envPrefix):
+		if value == envPrefix {
+			return "", fieldErrorf(field, "missing environment variable name")
+		}
+	case strings.HasPrefix(value, filePrefix):
+		if value == filePrefix {
+			return "", fieldErrorf(field, "missing file name")
+		}
+	default:
+		return fmt.Sprintf("%s: literal credentials are deprecated; use %sNAME or %sPATH", field, envPrefix, filePrefix), nil
+	}
+	return "", nil
+}
+
+// resolveCredential returns the value of a credential field, resolving references.
+// Errors are *Error values for the field.
+func resolveCredential(field, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		name := strings.TrimPrefix(value, envPrefix)
+		v := os.Getenv(name)
+		if v == "" {
+			return "", fieldErrorf(field, "environment variable %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, filePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(value, filePrefix))
+		if err != nil {
+			return "", fieldErrorf(field, "%v", err)
+		}
+		v := strings.TrimRight(string(data), " \t\r\n")
+		if v == "" {
+			return "", fieldErrorf(field, "file %s is empty", strings.TrimPrefix(value, filePrefix))
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// IsLocalStorage reports whether a storage location (see storage.Open) names a
+// local directory rather than an S3 bucket.
+func IsLocalStorage(location string) bool {
+	return !strings.Contains(location, "://") || strings.HasPrefix(location, "file://")
+}
+
+// UsesS3 reports whether the collection is published to S3, and so needs S3
+// credentials: it has no server, and its storage is an S3 URL or empty (the
+// default).
+func (cm *CollectionMetadata) UsesS3() bool {
+	return cm.Server == "" && (cm.Storage == "" || !IsLocalStorage(cm.Storage))
+}
+
+// S3Credentials returns the S3 access code and secret key of the collection,
+// resolving references. Both are empty if the collection has none (filesystem storage).
+func (cm *CollectionMetadata) S3Credentials() (accessCode, secretKey string, err error) {
+	if accessCode, err = resolveCredential("s3_access_code", cm.S3AccessCode); err != nil {
+		return "", "", err
+	}
+	if secretKey, err = resolveCredential("s3_secret_key", cm.S3SecretKey); err != nil {
+		return "", "", err
+	}
+	return accessCode, secretKey, nil
+}
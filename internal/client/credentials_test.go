@@ -0,0 +1,126 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       string
+		wantErr      bool
+		wantWarnings int
+	}{
+		{"references", `"s3_access_code": "env:LBX_S3_ACCESS", "s3_secret_key": "file:/etc/lbx/secret"`, false, 0},
+		{"literal", `"s3_access_code": "ACCESSCODE123", "s3_secret_key": "SECRETKEY123"`, false, 2},
+		{"mixed", `"s3_access_code": "ACCESSCODE123", "s3_secret_key": "env:LBX_S3_SECRET"`, false, 1},
+		{"empty env reference", `"s3_access_code": "env:", "s3_secret_key": "env:LBX_S3_SECRET"`, true, 0},
+		{"empty file reference", `"s3_access_code": "env:LBX_S3_ACCESS", "s3_secret_key": "file:"`, true, 0},
+		{"missing for S3", `"storage": "s3://photos"`, true, 0},
+		{"missing for default storage", ``, true, 0},
+		{"missing for directory", `"storage": "/srv/photos"`, false, 0},
+		{"missing for file URL", `"storage": "file:///srv/photos"`, false, 0},
+		{"missing for server", `"server": "https://photos.example.com"`, false, 0},
+		{"invalid server", `"server": "photos.example.com"`, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `{"version": "1", "name": "C", "url": "https://example.com"`
+			if tt.fields != "" {
+				input += ", " + tt.fields
+			}
+			cm, err := ParseCollectionMetadata([]byte(input + "}"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCollectionMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(cm.Warnings) != tt.wantWarnings {
+				t.Errorf("ParseCollectionMetadata() warnings = %v, want %d", cm.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestS3Credentials(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	t.Setenv("LBX_TEST_ACCESS", "AKID")
+	t.Setenv("LBX_TEST_EMPTY", "")
+
+	tests := []struct {
+		name                   string
+		accessCode, secretKey  string
+		wantAccess, wantSecret string
+		wantErr                string
+	}{
+		{"env and file", "env:LBX_TEST_ACCESS", "file:" + secretFile, "AKID", "s3cr3t", ""},
+		{"literal", "AKID", "s3cr3t", "AKID", "s3cr3t", ""},
+		{"none", "", "", "", "", ""},
+		{"unset variable", "env:LBX_TEST_UNSET", "file:" + secretFile, "", "", "LBX_TEST_UNSET"},
+		{"empty variable", "env:LBX_TEST_EMPTY", "file:" + secretFile, "", "", "LBX_TEST_EMPTY"},
+		{"missing file", "env:LBX_TEST_ACCESS", "file:" + secretFile + ".missing", "", "", "s3_secret_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &CollectionMetadata{S3AccessCode: tt.accessCode, S3SecretKey: tt.secretKey}
+			access, secret, err := cm.S3Credentials()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("S3Credentials() error = %v, want error mentioning %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("S3Credentials() error = %v", err)
+			}
+			if access != tt.wantAccess || secret != tt.wantSecret {
+				t.Errorf("S3Credentials() = %q, %q, want %q, %q", access, secret, tt.wantAccess, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestReadCollectionCredentials(t *testing.T) {
+	t.Setenv("LBX_TEST_ACCESS", "AKID")
+	tests := []struct {
+		name      string
+		fields    string
+		wantField string
+	}{
+		{"resolved", `"s3_access_code": "env:LBX_TEST_ACCESS", "s3_secret_key": "env:LBX_TEST_ACCESS"`, ""},
+		{"unset variable", `"s3_access_code": "env:LBX_TEST_ACCESS", "s3_secret_key": "env:LBX_TEST_UNSET"`, "s3_secret_key"},
+		{"missing file", `"s3_access_code": "file:/nonexistent/lbx", "s3_secret_key": "env:LBX_TEST_ACCESS"`, "s3_access_code"},
+		{"not needed for directory", `"storage": "/srv/photos", "s3_access_code": "env:LBX_TEST_UNSET", "s3_secret_key": "env:LBX_TEST_UNSET"`, ""},
+		{"not needed for server", `"server": "https://photos.example.com", "s3_access_code": "env:LBX_TEST_UNSET", "s3_secret_key": "env:LBX_TEST_UNSET"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			createFile(t, root, "metadata.json", `{"version": "1", "name": "C", "url": "https://example.com",
+				`+tt.fields+`}`)
+			_, err := ReadCollectionMetadata(root)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ReadCollectionMetadata() error = %v", err)
+				}
+				return
+			}
+			errs, ok := err.(Errors)
+			if !ok || len(errs) != 1 || errs[0].Field != tt.wantField || errs[0].Line != 2 {
+				t.Errorf("ReadCollectionMetadata() error = %v, want one error for %s on line 2", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestIsLocalStorage(t *testing.T) {
+	for loc, want := range map[string]bool{"/srv/photos": true, "file:///srv/photos": true, "relative/dir": true, "s3://photos": false} {
+		if got := IsLocalStorage(loc); got != want {
+			t.Errorf("IsLocalStorage(%s) = %v, want %v", loc, got, want)
+		}
+	}
+}
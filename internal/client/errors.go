@@ -0,0 +1,229 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Error is a problem with a metadata file.
+type Error struct {
+	// Path is the path of the file, or of the directory for problems with a
+	// directory (e.g., a missing metadata file). Empty for errors returned by
+	// the Parse functions, which do not know where data comes from.
+	Path string
+	// Line and Column locate the problem in the file (1-based), or are 0 if unknown.
+	Line, Column int
+	// Field is the JSON field at fault (e.g., "sort_order", "translations.fr.title"),
+	// or "" for problems with the file as a whole.
+	Field string
+	// Err is the problem.
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path + ":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", e.Line, e.Column)
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of problems with the metadata of a collection. It is the
+// error returned by ReadMetadata and the Parse functions.
+type Errors []*Error
+
+// Error returns the problems, one per line.
+func (l Errors) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// err returns l as an error, or nil if it is empty.
+func (l Errors) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// withPath sets the path of the errors in l that have none and returns l.
+func (l Errors) withPath(path string) Errors {
+	for _, e := range l {
+		if e.Path == "" {
+			e.Path = path
+		}
+	}
+	return l
+}
+
+// fieldErrorf returns an error for a field.
+func fieldErrorf(field, format string, args ...any) *Error {
+	return &Error{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// problems collects the problems found in a metadata file.
+type problems struct {
+	data []byte
+	// offsets are the offsets in data of the values of fields (see scanFields).
+	offsets map[string]int
+	// failed are the top-level fields that could not be decoded. Other problems
+	// with them, which follow from their zero value, are not recorded.
+	failed map[string]bool
+	errs   Errors
+}
+
+// add records a problem with field. If err is an *Error for a field, that field
+// is used instead; if it is an Errors, each of its problems is recorded.
+func (p *problems) add(field string, err error) {
+	if l, ok := err.(Errors); ok {
+		for _, e := range l {
+			p.add(field, e)
+		}
+		return
+	}
+	e := &Error{Field: field, Err: err}
+	if fe, ok := err.(*Error); ok {
+		e.Err = fe.Err
+		if fe.Field != "" {
+			e.Field = fe.Field
+		}
+	}
+	if p.failed[topField(e.Field)] {
+		return
+	}
+	p.locate(e)
+	p.errs = append(p.errs, e)
+}
+
+// addf records a problem with field.
+func (p *problems) addf(field, format string, args ...any) {
+	p.add(field, fmt.Errorf(format, args...))
+}
+
+// locate sets the position of an error from the offset of its field, or of
+// the closest enclosing field whose offset is known.
+func (p *problems) locate(e *Error) {
+	for f := e.Field; f != ""; f = parentField(f) {
+		if off, ok := p.offsets[f]; ok {
+			e.Line, e.Column = lineCol(p.data, off)
+			return
+		}
+	}
+}
+
+// parentField returns the field that contains a field ("translations.fr" for
+// "translations.fr.title", "tags" for "tags[1]"), or "" for top-level fields.
+func parentField(field string) string {
+	i := strings.LastIndexAny(field, ".[")
+	if i < 0 {
+		return ""
+	}
+	return field[:i]
+}
+
+// topField returns the top-level field that contains a field ("translations" for
+// "translations.fr.title", "tags" for "tags[1]").
+func topField(field string) string {
+	if i := strings.IndexAny(field, ".["); i >= 0 {
+		return field[:i]
+	}
+	return field
+}
+
+// lineCol returns the 1-based line and column (in bytes) of offset off in data.
+func lineCol(data []byte, off int) (line, col int) {
+	off = min(max(off, 0), len(data))
+	before := data[:off]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = off - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// decode decodes the JSON metadata in data into v, which must be a pointer to a
+// struct. Returns the problems found so far, which include syntax errors, type
+// errors and unknown fields, along with a collector for further problems.
+// If ok is false, v could not be decoded. Otherwise, fields with type errors may
+// be partly decoded, and further problems with them are not recorded, so that
+// the other fields can still be checked.
+func decode(data []byte, v any) (p *problems, ok bool) {
+	p = &problems{data: data, offsets: map[string]int{}, failed: map[string]bool{}}
+	if len(bytes.TrimSpace(data)) == 0 {
+		p.errs = append(p.errs, &Error{Err: errors.New("empty metadata file")})
+		return p, false
+	}
+	offsets, unknown, err := scanFields(data, v)
+	if err != nil {
+		e := &Error{Err: err}
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			// The offset is past the offending byte, unless the input ended.
+			off := int(se.Offset)
+			if off < len(data) {
+				off--
+			}
+			e.Line, e.Column = lineCol(data, off)
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			e.Line, e.Column = lineCol(data, len(data))
+		}
+		p.errs = append(p.errs, e)
+		return p, false
+	}
+	p.offsets = offsets
+	for _, u := range unknown {
+		e := &Error{Field: u.field, Err: errors.New("unknown field")}
+		e.Line, e.Column = lineCol(data, u.offset)
+		p.errs = append(p.errs, e)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		var te *json.UnmarshalTypeError
+		if !errors.As(err, &te) {
+			p.add("", err)
+			return p, false
+		}
+		// Unmarshal decodes what it can but only reports the first type error,
+		// so top-level fields are decoded again one at a time to find the others.
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			p.add("", err)
+			return p, false
+		}
+		keys := sortedKeys(fields)
+		sort.SliceStable(keys, func(i, j int) bool { return p.offsets[keys[i]] < p.offsets[keys[j]] })
+		t := reflect.TypeOf(v).Elem()
+		for _, key := range keys {
+			if _, ok := structField(t, key); !ok {
+				continue
+			}
+			k, _ := json.Marshal(key)
+			field := append(append(append(append([]byte("{"), k...), ':'), fields[key]...), '}')
+			if err := json.Unmarshal(field, reflect.New(t).Interface()); errors.As(err, &te) {
+				p.addf(te.Field, "cannot use JSON %s as %s", te.Value, te.Type)
+				p.failed[topField(te.Field)] = true
+			}
+		}
+	}
+	return p, true
+}
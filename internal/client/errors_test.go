@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseAlbumMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "Syntax error",
+			data: "{\n  \"title\": \"T\",\n  \"tags\": [\"a\" \"b\"]\n}",
+			want: []string{"3:16: invalid character '\"' after array element"},
+		},
+		{
+			name: "Truncated",
+			data: "{\n  \"title\": \"T\",",
+			want: []string{"2:16: unexpected end of JSON input"},
+		},
+		{
+			name: "Empty",
+			data: " \n",
+			want: []string{"empty metadata file"},
+		},
+		{
+			name: "Type error",
+			data: "{\"title\": \"T\",\n \"tags\": \"a\"}",
+			want: []string{"2:10: tags: cannot use JSON string as []string"},
+		},
+		{
+			name: "Type errors",
+			data: "{\"title\": 1,\n \"tags\": \"a\",\n \"sort_order\": \"size\",\n \"translations\": {\"fr\": {\"title\": 2}}}",
+			want: []string{
+				"1:11: title: cannot use JSON number as string",
+				"2:10: tags: cannot use JSON string as []string",
+				"4:35: translations.fr.title: cannot use JSON number as string",
+				"3:16: sort_order: invalid sort order size",
+			},
+		},
+		{
+			name: "Unknown fields",
+			data: "{\n  \"title\": \"T\",\n  \"sort-order\": \"name\",\n  \"translations\": {\"fr\": {\"title\": \"T\", \"blrub\": \"B\"}},\n  \"path\": \"p\"\n}",
+			want: []string{
+				"3:3: sort-order: unknown field",
+				"4:41: translations.fr.blrub: unknown field",
+				"5:3: path: unknown field",
+			},
+		},
+		{
+			name: "Constraint violations",
+			data: "{\n  \"sort_order\": \"size\",\n  \"filter\": [\"include:.*\", \"keep:a\"],\n  \"titles\": [\"a.jpg\"]\n}",
+			want: []string{
+				"title: require album title",
+				"2:17: sort_order: invalid sort order size",
+				"3:28: filter[1]: invalid filter entry: keep:a",
+				`4:14: titles[0]: invalid entry "a.jpg": want FILENAME:[LANG:]TEXT`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAlbumMetadata([]byte(tt.data), true)
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ParseAlbumMetadata() error = %v, want Errors", err)
+			}
+			got := []string{}
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAlbumMetadata() errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMetadataErrors(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	// An intermediate directory without metadata file is valid.
+	if err := os.MkdirAll(filepath.Join(rootDir, "europe", "paris"), 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	createFile(t, filepath.Join(rootDir, "europe", "paris"), "metadata.json", `{"title": "Paris"}`)
+	if _, err := ReadMetadata(rootDir); err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+
+	// Problems in different files are all reported.
+	initAlbum(t, rootDir, "rome", `{"title": "Rome", "sort-order": "name"}`)
+	initAlbum(t, rootDir, "oslo", `{"title": "Oslo",`)
+	if err := os.Mkdir(filepath.Join(rootDir, "lisbon"), 0755); err != nil {
+		t.Fatalf("Failed to create subdir: %v", err)
+	}
+	createFile(t, filepath.Join(rootDir, "lisbon"), "a.jpg", "")
+	_, err := ReadMetadata(rootDir)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ReadMetadata() error = %v, want Errors", err)
+	}
+	want := []*Error{
+		{Path: filepath.Join(rootDir, "lisbon"), Err: errors.New("missing metadata file in media directory")},
+		{Path: filepath.Join(rootDir, "oslo", "metadata.json"), Line: 1, Column: 18, Err: errors.New("unexpected end of JSON input")},
+		{Path: filepath.Join(rootDir, "rome", "metadata.json"), Line: 1, Column: 19, Field: "sort-order", Err: errors.New("unknown field")},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("ReadMetadata() errors = %v, want %d errors", errs, len(want))
+	}
+	for i, e := range errs {
+		w := want[i]
+		if e.Path != w.Path || e.Line != w.Line || e.Column != w.Column || e.Field != w.Field || e.Err.Error() != w.Err.Error() {
+			t.Errorf("ReadMetadata() error %d = %v, want %v", i, e, w)
+		}
+	}
+}
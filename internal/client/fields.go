@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// fieldScanner walks a JSON document alongside the Go type it decodes into.
+type fieldScanner struct {
+	data    []byte
+	dec     *json.Decoder
+	offsets map[string]int
+	unknown []unknownField
+}
+
+// unknownField is an object key that matches no field.
+type unknownField struct {
+	// field is the path of the key (see scanFields).
+	field string
+	// offset is the offset of the key.
+	offset int
+}
+
+// scanFields walks the JSON document data, which decodes into v. Returns the
+// offset of the value of every field by path (e.g., "sort_order",
+// "translations.fr.title", "tags[1]") and the object keys that match no field
+// of the corresponding struct, in document order. Keys are matched
+// case-insensitively, like encoding/json does, but only to fields with a JSON
+// name: fields such as AlbumMetadata.Path cannot be set from metadata files.
+func scanFields(data []byte, v any) (map[string]int, []unknownField, error) {
+	s := &fieldScanner{data: data, dec: json.NewDecoder(bytes.NewReader(data)), offsets: map[string]int{}}
+	if err := s.value("", reflect.TypeOf(v)); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.dec.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("invalid data after top-level value")
+		}
+		return nil, nil, err
+	}
+	return s.offsets, s.unknown, nil
+}
+
+// next returns the offset of the next token.
+func (s *fieldScanner) next() int {
+	off := int(s.dec.InputOffset())
+	for off < len(s.data) && strings.IndexByte(" \t\r\n:,", s.data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// value scans a value at path, which decodes into a value of type t (nil if unknown).
+func (s *fieldScanner) value(path string, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if path != "" {
+		s.offsets[path] = s.next()
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for s.dec.More() {
+			off := s.next()
+			tok, err := s.dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			var ft reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					var ok bool
+					if ft, ok = structField(t, key); !ok {
+						s.unknown = append(s.unknown, unknownField{field, off})
+					}
+				case reflect.Map:
+					ft = t.Elem()
+				}
+			}
+			if err := s.value(field, ft); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		for i := 0; s.dec.More(); i++ {
+			if err := s.value(fmt.Sprintf("%s[%d]", path, i), et); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	// Closing delimiter.
+	_, err = s.dec.Token()
+	return err
+}
+
+// structField returns the type of the field of struct type t, or of its embedded
+// structs, whose JSON name matches key.
+func structField(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if ft, ok := structField(f.Type, key); ok {
+				return ft, true
+			}
+			continue
+		}
+		if name := jsonName(f); name != "" && strings.EqualFold(name, key) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// jsonName returns the name of a struct field in metadata files, or "" if it
+// cannot be set from them.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if !f.IsExported() || name == "-" {
+		return ""
+	}
+	return name
+}
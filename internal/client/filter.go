@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mediaExtensions is the set of (lowercase) file extensions recognized as media.
+var mediaExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true,
+	".tif": true, ".tiff": true, ".heic": true, ".webp": true,
+	".mp4": true, ".mov": true, ".m4v": true, ".avi": true,
+}
+
+// IsMediaFile returns true if name has the extension of a supported photo or video format.
+func IsMediaFile(name string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// videoExtensions is the subset of mediaExtensions recognized as videos.
+var videoExtensions = map[string]bool{".mp4": true, ".mov": true, ".m4v": true, ".avi": true}
+
+// IsVideoFile returns true if name has the extension of a supported video format.
+func IsVideoFile(name string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// filterRule is a compiled filter entry.
+type filterRule struct {
+	include bool
+	re      *regexp.Regexp
+	entry   string // Original "include:REGEX" or "exclude:REGEX" entry.
+}
+
+// Filter is a compiled filter chain (see CommonMetadata.Filter).
+type Filter struct {
+	rules []filterRule
+}
+
+// CompileFilter compiles a list of filter entries. Each regexp must match
+// the entire filename (it is implicitly anchored at both ends).
+func CompileFilter(entries []string) (*Filter, error) {
+	f := &Filter{}
+	for _, entry := range entries {
+		rule, err := compileFilterEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, rule)
+	}
+	return f, nil
+}
+
+// compileFilterEntry compiles a single "include:REGEX" or "exclude:REGEX" entry.
+func compileFilterEntry(entry string) (filterRule, error) {
+	kind, pattern, ok := strings.Cut(entry, ":")
+	if !ok || (kind != "include" && kind != "exclude") {
+		return filterRule{}, fmt.Errorf("invalid filter entry: %s", entry)
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return filterRule{}, fmt.Errorf("invalid filter regexp in %s: %v", entry, err)
+	}
+	return filterRule{include: kind == "include", re: re, entry: entry}, nil
+}
+
+// Match evaluates the filter chain against a filename. The first matching rule wins.
+// Returns whether the file is included and the entry of the rule that decided it,
+// or an empty string if no rule matched (in which case the file is not included).
+func (f *Filter) Match(name string) (bool, string) {
+	for _, r := range f.rules {
+		if r.re.MatchString(name) {
+			return r.include, r.entry
+		}
+	}
+	return false, ""
+}
+
+// MediaFile describes a media file in an album directory.
+type MediaFile struct {
+	// Name is the filename, relative to the album directory.
+	Name string
+	// Path is the path of the file.
+	Path string
+	// Size is the size of the file in bytes.
+	Size int64
+	// ModTime is the modification time of the file.
+	ModTime time.Time
+}
+
+// ExcludedFile is a media file rejected by an album's filter chain.
+type ExcludedFile struct {
+	MediaFile
+	// Rule is the filter entry that excluded the file, or "" if no rule matched.
+	Rule string
+}
+
+// Selection is the result of applying an album's filter chain to its media files.
+type Selection struct {
+	// Selected is the list of included media files, sorted by name.
+	Selected []MediaFile
+	// Excluded is the list of excluded media files, sorted by name.
+	Excluded []ExcludedFile
+}
+
+// SelectMedia lists the media files of an album, where root is the collection root
+// and md is the album metadata as returned by ReadMetadata, and applies the album's
+// filter chain to them.
+func SelectMedia(root string, md *AlbumMetadata) (*Selection, error) {
+	f := md.filter
+	if f == nil {
+		var err error
+		if f, err = CompileFilter(md.Filter); err != nil {
+			return nil, fmt.Errorf("album %s: %v", md.Path, err)
+		}
+	}
+	dir := filepath.Join(root, md.Path)
+	// os.ReadDir returns entries sorted by filename.
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+	sel := &Selection{Selected: []MediaFile{}, Excluded: []ExcludedFile{}}
+	for _, e := range dirEntries {
+		if e.IsDir() || !IsMediaFile(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %v", err)
+		}
+		mf := MediaFile{
+			Name:    e.Name(),
+			Path:    filepath.Join(dir, e.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if ok, rule := f.Match(e.Name()); ok {
+			sel.Selected = append(sel.Selected, mf)
+		} else {
+			sel.Excluded = append(sel.Excluded, ExcludedFile{MediaFile: mf, Rule: rule})
+		}
+	}
+	return sel, nil
+}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   []string
+		file     string
+		included bool
+		rule     string
+	}{
+		{"include all", []string{"include:.*"}, "a.jpg", true, "include:.*"},
+		{"first match wins", []string{"exclude:.*\\.png", "include:.*"}, "a.png", false, "exclude:.*\\.png"},
+		{"first match wins include", []string{"include:a\\.png", "exclude:.*\\.png"}, "a.png", true, "include:a\\.png"},
+		{"no match", []string{"include:.*\\.jpg"}, "a.png", false, ""},
+		{"anchored", []string{"include:IMG"}, "IMG_1.jpg", false, ""},
+		{"literal filename", []string{"exclude:IMG_1.jpg", "include:.*"}, "IMG_1.jpg", false, "exclude:IMG_1.jpg"},
+		{"colon in regexp", []string{"include:a:b\\.jpg"}, "a:b.jpg", true, "include:a:b\\.jpg"},
+		{"empty chain", nil, "a.jpg", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := CompileFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("CompileFilter() error = %v", err)
+			}
+			included, rule := f.Match(tt.file)
+			if included != tt.included || rule != tt.rule {
+				t.Errorf("Match(%s) = %v, %q, want %v, %q", tt.file, included, rule, tt.included, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	for _, entry := range []string{"include", "keep:.*", "include:*.jpg", "exclude:(foo"} {
+		if _, err := CompileFilter([]string{entry}); err == nil {
+			t.Errorf("CompileFilter(%s): expected error, got nil", entry)
+		}
+	}
+}
+
+func TestSelectMedia(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	initAlbum(t, rootDir, "subdir1", `{
+		"enabled": true,
+		"filter": ["exclude:.*\\.png"]
+	}`)
+	initAlbum(t, rootDir, "subdir1/album1", `{
+		"enabled": true,
+		"title": "Test Album1",
+		"filter": ["include:keep\\.png", "exclude:IMG_2\\.jpg"]
+	}`)
+	albumDir := filepath.Join(rootDir, "subdir1/album1")
+	for _, fn := range []string{"IMG_1.jpg", "IMG_2.jpg", "keep.png", "drop.png", "notes.txt"} {
+		createFile(t, albumDir, fn, "")
+	}
+
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	sel, err := SelectMedia(rootDir, mdList[0])
+	if err != nil {
+		t.Fatalf("SelectMedia failed: %v", err)
+	}
+	selected := []string{}
+	for _, mf := range sel.Selected {
+		selected = append(selected, mf.Name)
+	}
+	if !reflect.DeepEqual(selected, []string{"IMG_1.jpg", "keep.png"}) {
+		t.Errorf("Expected selected [IMG_1.jpg keep.png], got %v", selected)
+	}
+	excluded := []string{}
+	for _, ef := range sel.Excluded {
+		excluded = append(excluded, ef.Name+"="+ef.Rule)
+	}
+	want := []string{"IMG_2.jpg=exclude:IMG_2\\.jpg", "drop.png=exclude:.*\\.png"}
+	if !reflect.DeepEqual(excluded, want) {
+		t.Errorf("Expected excluded %v, got %v", want, excluded)
+	}
+	if sel.Selected[0].Path != filepath.Join(albumDir, "IMG_1.jpg") {
+		t.Errorf("Expected path %s, got %s", filepath.Join(albumDir, "IMG_1.jpg"), sel.Selected[0].Path)
+	}
+}
+
+func TestReadMetadataInvalidFilter(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	initAlbum(t, rootDir, "album1", `{
+		"title": "Test Album1",
+		"filter": ["include:[a-"]
+	}`)
+	_, err := ReadMetadata(rootDir)
+	if err == nil {
+		t.Fatalf("Expected error for invalid filter regexp, got nil")
+	}
+	if !strings.Contains(err.Error(), filepath.Join(rootDir, "album1")) {
+		t.Errorf("Expected error to name album path, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/maxpoletto/lbx/internal/xmp"
+)
+
+// ratingTagFormat is the format of the tag that records the star rating of a
+// photo imported from XMP (e.g., "rating-4").
+const ratingTagFormat = "rating-%d"
+
+// MediaDescription returns the texts and tags of the photo with the given filename
+// (see MediaTexts and MediaTags), completed with metadata x imported from its XMP
+// or IPTC data, which may be nil. Metadata files take precedence: imported titles
+// and captions only fill in languages and fields they leave unset, and imported
+// keywords and rating are only used if they set no tags for the photo.
+func (m *AlbumMetadata) MediaDescription(name string, x *xmp.Metadata) ([]MediaText, []string, error) {
+	texts, err := m.MediaTexts(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags := m.MediaTags(name)
+	if x == nil {
+		return texts, tags, nil
+	}
+	texts = mergeMediaTexts(importedTexts(x), texts)
+	if len(tags) == 0 {
+		tags = mergeLists(x.Keywords, nil)
+		if x.Rating > 0 {
+			tags = mergeLists(tags, []string{fmt.Sprintf(ratingTagFormat, x.Rating)})
+		}
+	}
+	return texts, tags, nil
+}
+
+// importedTexts returns the titles and descriptions of imported metadata as media
+// texts. The XMP default language maps to the default language of the collection;
+// texts in other languages that are not well-formed language codes are dropped.
+func importedTexts(x *xmp.Metadata) []MediaText {
+	byLang := map[string]*MediaText{}
+	add := func(lang, text string, caption bool) {
+		if lang == xmp.DefaultLanguage {
+			lang = ""
+		} else if canonical, err := CanonicalLanguage(lang); err == nil {
+			lang = canonical
+		} else {
+			return
+		}
+		t := byLang[lang]
+		if t == nil {
+			t = &MediaText{Language: lang}
+			byLang[lang] = t
+		}
+		if caption {
+			t.Caption = text
+		} else {
+			t.Title = text
+		}
+	}
+	for lang, s := range x.Title {
+		add(lang, s, false)
+	}
+	for lang, s := range x.Description {
+		add(lang, s, true)
+	}
+	texts := []MediaText{}
+	for _, t := range byLang {
+		texts = append(texts, *t)
+	}
+	sort.Slice(texts, func(i, j int) bool { return texts[i].Language < texts[j].Language })
+	return texts
+}
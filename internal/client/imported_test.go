@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/maxpoletto/lbx/internal/xmp"
+)
+
+func TestMediaDescription(t *testing.T) {
+	am, err := ParseAlbumMetadata([]byte(`{
+		"title": "Paris",
+		"translations": {"fr": {"title": "Paris"}},
+		"titles": ["a.jpg:The tower", "a.jpg:fr:La tour"],
+		"tags": ["a.jpg:tower"]
+	}`), true)
+	if err != nil {
+		t.Fatalf("ParseAlbumMetadata() error = %v", err)
+	}
+	x := &xmp.Metadata{
+		Title:       map[string]string{"x-default": "Eiffel Tower", "de": "Eiffelturm", "not a language": "?"},
+		Description: map[string]string{"x-default": "From Trocadéro", "fr": "Du Trocadéro"},
+		Keywords:    []string{"night", "Paris"},
+		Rating:      4,
+	}
+	tests := []struct {
+		name      string
+		x         *xmp.Metadata
+		wantTexts []MediaText
+		wantTags  []string
+	}{
+		{"a.jpg", nil, []MediaText{{"", "The tower", ""}, {"fr", "La tour", ""}}, []string{"tower"}},
+		{"a.jpg", x,
+			[]MediaText{{"", "The tower", "From Trocadéro"}, {"de", "Eiffelturm", ""}, {"fr", "La tour", "Du Trocadéro"}},
+			[]string{"tower"}},
+		{"b.jpg", x,
+			[]MediaText{{"", "Eiffel Tower", "From Trocadéro"}, {"de", "Eiffelturm", ""}, {"fr", "", "Du Trocadéro"}},
+			[]string{"Paris", "night", "rating-4"}},
+		{"b.jpg", &xmp.Metadata{Rating: -1}, []MediaText{}, []string{}},
+	}
+	for _, tt := range tests {
+		texts, tags, err := am.MediaDescription(tt.name, tt.x)
+		if err != nil {
+			t.Fatalf("MediaDescription(%s) error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(texts, tt.wantTexts) || !reflect.DeepEqual(tags, tt.wantTags) {
+			t.Errorf("MediaDescription(%s, %+v) = %+v, %q, want %+v, %q", tt.name, tt.x, texts, tags, tt.wantTexts, tt.wantTags)
+		}
+	}
+}
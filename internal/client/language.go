@@ -0,0 +1,95 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CanonicalLanguage checks that tag is a well-formed BCP 47 language tag (RFC 5646)
+// and returns it in canonical case (e.g., "en-US", "zh-Hant-TW"). Primary language
+// subtags must have 2 or 3 letters, since no longer ones are registered; private-use
+// tags ("x-...") and grandfathered tags are not accepted.
+func CanonicalLanguage(tag string) (string, error) {
+	st := strings.Split(strings.ToLower(tag), "-")
+	invalid := func(reason string) (string, error) {
+		if reason != "" {
+			return "", fmt.Errorf("invalid language code %q: %s", tag, reason)
+		}
+		return "", fmt.Errorf("invalid language code %q", tag)
+	}
+	if len(st[0]) < 2 || len(st[0]) > 3 || !isAlpha(st[0]) {
+		return invalid("")
+	}
+	out := []string{st[0]}
+	i := 1
+	// Extended language subtags (e.g., "zh-yue").
+	for n := 0; n < 3 && i < len(st) && len(st[i]) == 3 && isAlpha(st[i]); n, i = n+1, i+1 {
+		out = append(out, st[i])
+	}
+	// Script (e.g., "Hant").
+	if i < len(st) && len(st[i]) == 4 && isAlpha(st[i]) {
+		out = append(out, strings.ToUpper(st[i][:1])+st[i][1:])
+		i++
+	}
+	// Region (e.g., "US" or "419").
+	if i < len(st) && (len(st[i]) == 2 && isAlpha(st[i]) || len(st[i]) == 3 && isDigit(st[i])) {
+		out = append(out, strings.ToUpper(st[i]))
+		i++
+	}
+	// Variants (e.g., "1901"), each at most once.
+	seen := map[string]bool{}
+	for ; i < len(st) && isVariant(st[i]); i++ {
+		if seen[st[i]] {
+			return invalid("duplicate variant " + st[i])
+		}
+		seen[st[i]] = true
+		out = append(out, st[i])
+	}
+	// Extensions (e.g., "u-ca-buddhist") and private use ("x-..."): a singleton
+	// followed by one or more subtags. Each extension singleton appears at most once.
+	for i < len(st) && len(st[i]) == 1 && isAlnum(st[i]) {
+		singleton, minLen := st[i], 2
+		if singleton == "x" {
+			minLen = 1
+		} else if seen[singleton] {
+			return invalid("duplicate extension " + singleton)
+		}
+		seen[singleton] = true
+		j := i + 1
+		for j < len(st) && len(st[j]) >= minLen && len(st[j]) <= 8 && isAlnum(st[j]) {
+			j++
+		}
+		if j == i+1 {
+			return invalid("empty extension " + singleton)
+		}
+		out = append(out, st[i:j]...)
+		i = j
+		if singleton == "x" {
+			break
+		}
+	}
+	if i != len(st) {
+		return invalid("")
+	}
+	return strings.Join(out, "-"), nil
+}
+
+// isVariant reports whether s is a variant subtag: 5-8 alphanumerics, or a
+// digit followed by 3 alphanumerics.
+func isVariant(s string) bool {
+	return isAlnum(s) && (len(s) >= 5 && len(s) <= 8 || len(s) == 4 && isDigit(s[:1]))
+}
+
+// isAlpha, isDigit and isAlnum report whether s is a non-empty string of
+// lowercase ASCII letters, digits, or both.
+func isAlpha(s string) bool {
+	return s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyz") == ""
+}
+
+func isDigit(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func isAlnum(s string) bool {
+	return s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyz0123456789") == ""
+}
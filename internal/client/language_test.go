@@ -0,0 +1,43 @@
+package metadata
+
+import "testing"
+
+func TestCanonicalLanguage(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{"en", "en", false},
+		{"EN-us", "en-US", false},
+		{"fr-CA", "fr-CA", false},
+		{"zh-hant-tw", "zh-Hant-TW", false},
+		{"es-419", "es-419", false},
+		{"zh-yue-HK", "zh-yue-HK", false},
+		{"sl-rozaj-biske", "sl-rozaj-biske", false},
+		{"de-CH-1901", "de-CH-1901", false},
+		{"en-US-u-ca-gregory", "en-US-u-ca-gregory", false},
+		{"en-x-pirate", "en-x-pirate", false},
+		{"", "", true},
+		{"e", "", true},
+		{"Note", "", true},
+		{"Paris", "", true},
+		{"en_US", "", true},
+		{"en-", "", true},
+		{"en-US-US", "", true},
+		{"de-1901-1901", "", true},
+		{"en-u", "", true},
+		{"en-u-ca-u-nu", "", true},
+		{"x-private", "", true},
+		{"i-klingon", "", true},
+		{"12", "", true},
+	}
+	for _, tt := range tests {
+		got, err := CanonicalLanguage(tt.tag)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CanonicalLanguage(%q) error = %v, wantErr %t", tt.tag, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("CanonicalLanguage(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -1,50 +1,100 @@
 package metadata
 
 import (
-	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
-	"strings"
+	"slices"
 )
 
-// ParseCollectionMetadata parses the metadata of an LBX photo collection.
+// urlPattern is the pattern of collection URLs.
+const urlPattern = `^https?://[a-zA-Z0-9.-]+(?:\.[a-zA-Z]{2,})+`
+
+// sortOrders are the valid values of CommonMetadata.SortOrder.
+var sortOrders = []string{"taken", "taken:reverse", "name", "name:reverse", "mtime", "mtime:reverse"}
+
+// ParseCollectionMetadata parses the metadata of an LBX photo collection. If the
+// metadata is invalid, the error is an Errors listing every problem found.
 func ParseCollectionMetadata(data []byte) (*CollectionMetadata, error) {
-	urlPattern := regexp.MustCompile(`^https?://[a-zA-Z0-9.-]+(?:\.[a-zA-Z]{2,})+`)
+	cm, errs := parseCollectionMetadata(data, false)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cm, nil
+}
 
+// parseCollectionMetadata parses the metadata of an LBX photo collection and
+// returns every problem found. The metadata is nil if it could not be decoded. If
+// resolve is true, the S3 credentials of collections published to S3 are resolved
+// (see S3Credentials), so that missing environment variables and files are
+// reported with the other problems.
+func parseCollectionMetadata(data []byte, resolve bool) (*CollectionMetadata, Errors) {
 	var cm CollectionMetadata
-	if err := json.Unmarshal(data, &cm); err != nil {
-		return nil, err
+	p, ok := decode(data, &cm)
+	if !ok {
+		return nil, p.errs
 	}
 	// Enforce constraints and set defaults.
-	// Version must be present and non-empty.
-	if cm.Version == "" {
-		return nil, fmt.Errorf("require metadata version = 1")
+	// Version must be a known version of the metadata format.
+	if err := checkVersion(cm.Version); err != nil {
+		p.add("version", err)
 	}
 	// Name must be present and non-empty.
 	if cm.Name == "" {
-		return nil, fmt.Errorf("require collection name")
+		p.addf("name", "require collection name")
+	}
+	// Language, if present, must be a language code.
+	if cm.Language != "" {
+		if lang, err := CanonicalLanguage(cm.Language); err != nil {
+			p.add("language", err)
+		} else {
+			cm.Language = lang
+		}
 	}
 	// URL must be present and match a URL pattern.
 	if cm.URL == "" {
-		return nil, fmt.Errorf("require collection URL")
-	} else if !urlPattern.MatchString(cm.URL) {
-		return nil, fmt.Errorf("invalid collection URL")
+		p.addf("url", "require collection URL")
+	} else if !regexp.MustCompile(urlPattern).MatchString(cm.URL) {
+		p.addf("url", "invalid collection URL")
+	}
+	// Server, if present, must be an HTTP(S) URL.
+	if cm.Server != "" {
+		if u, err := url.Parse(cm.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.addf("server", "invalid server URL")
+		}
 	}
-	// S3AccessCode must be present and non-empty.
-	if cm.S3AccessCode == "" {
-		return nil, fmt.Errorf("require S3 access code")
+	// S3AccessCode and S3SecretKey must be present and non-empty unless the collection
+	// is published to the filesystem or through a server. Literal credentials are deprecated.
+	if cm.UsesS3() {
+		if cm.S3AccessCode == "" {
+			p.addf("s3_access_code", "require S3 access code")
+		}
+		if cm.S3SecretKey == "" {
+			p.addf("s3_secret_key", "require S3 secret key")
+		}
 	}
-	// S3SecretKey must be present and non-empty.
-	if cm.S3SecretKey == "" {
-		return nil, fmt.Errorf("require S3 secret key")
+	for _, c := range []struct{ field, value string }{
+		{"s3_access_code", cm.S3AccessCode},
+		{"s3_secret_key", cm.S3SecretKey},
+	} {
+		warning, err := checkCredential(c.field, c.value)
+		if err != nil {
+			p.add(c.field, err)
+		} else if resolve && c.value != "" && cm.UsesS3() {
+			if _, err := resolveCredential(c.field, c.value); err != nil {
+				p.add(c.field, err)
+			}
+		}
+		if warning != "" {
+			cm.Warnings = append(cm.Warnings, warning)
+		}
 	}
 	// MaxSize must be non-negative.
 	if cm.MaxSize < 0 {
-		return nil, fmt.Errorf("invalid max size")
-	}
-	if err := checkCommonMetadata(&cm.CommonMetadata); err != nil {
-		return nil, err
+		p.addf("max_size", "invalid max size")
 	}
+	checkCommonMetadata(&cm.CommonMetadata, p)
+	checkPlainAccess(cm.Access, p)
 	// Set some site-wide defaults.
 	if len(cm.Filter) == 0 {
 		cm.Filter = []string{"include:.*"}
@@ -52,48 +102,108 @@ func ParseCollectionMetadata(data []byte) (*CollectionMetadata, error) {
 	if cm.SortOrder == "" {
 		cm.SortOrder = "taken"
 	}
-	return &cm, nil
+	return &cm, p.errs
 }
 
 // ParseAlbumMetadata parses the metadata of an LBX photo album or intermediate directory.
-// If album is true, metadata corresponds to an album (media directory).
+// If album is true, metadata corresponds to an album (media directory). If the
+// metadata is invalid, the error is an Errors listing every problem found.
 func ParseAlbumMetadata(data []byte, album bool) (*AlbumMetadata, error) {
+	am, errs := parseAlbumMetadata(data, album, "")
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return am, nil
+}
+
+// parseAlbumMetadata parses the metadata of an LBX photo album or intermediate
+// directory and returns every problem found. The metadata is nil if it could not
+// be decoded. If collection is not empty, it is the version of the collection,
+// which the version of the file is checked against (see checkFileVersion).
+func parseAlbumMetadata(data []byte, album bool, collection string) (*AlbumMetadata, Errors) {
 	var am AlbumMetadata
-	if err := json.Unmarshal(data, &am); err != nil {
-		return nil, err
+	p, ok := decode(data, &am)
+	if !ok {
+		return nil, p.errs
+	}
+	if collection != "" {
+		if err := checkFileVersion(am.Version, collection); err != nil {
+			p.add("version", err)
+		}
 	}
 	// Title must be set iff album is true.
 	if !album && am.Title != "" {
-		return nil, fmt.Errorf("title can only be set in an album folder")
+		p.addf("title", "can only be set in an album folder")
 	} else if album && am.Title == "" {
-		return nil, fmt.Errorf("require album title")
-	}
-	// TitlePhoto, HighlightPhoto, Aliases, Titles, and Captions can only be set in an album folder.
-	if !album && (am.TitlePhoto != "" || am.HighlightPhoto != "" || len(am.Aliases) > 0 || len(am.Titles) > 0 || len(am.Captions) > 0) {
-		return nil, fmt.Errorf("title photo, highlight photo, aliases, titles, and captions can only be set in an album folder")
+		p.addf("title", "require album title")
+	}
+	// Blurb, translations, TitlePhoto, HighlightPhoto, Aliases, Titles, and Captions
+	// can only be set in an album folder.
+	if !album {
+		for _, f := range []struct {
+			field string
+			set   bool
+		}{
+			{"blurb", am.Blurb != ""},
+			{"translations", len(am.Translations) > 0},
+			{"title_photo", am.TitlePhoto != ""},
+			{"highlight_photo", am.HighlightPhoto != ""},
+			{"aliases", len(am.Aliases) > 0},
+			{"titles", len(am.Titles) > 0},
+			{"captions", len(am.Captions) > 0},
+		} {
+			if f.set {
+				p.addf(f.field, "can only be set in an album folder")
+			}
+		}
 	}
-	if err := checkCommonMetadata(&am.CommonMetadata); err != nil {
-		return nil, err
+	if _, err := am.AlbumTexts(); err != nil {
+		p.add("translations", err)
+	}
+	checkCommonMetadata(&am.CommonMetadata, p)
+	// Per-photo access entries can only be set in an album folder.
+	if !album {
+		checkPlainAccess(am.Access, p)
+	} else {
+		rules, err := compileMediaAccess(am.Access)
+		if err != nil {
+			p.add("access", err)
+		}
+		am.mediaAccess = rules
+		for i, alias := range am.Aliases {
+			if err := checkAlias(alias); err != nil {
+				p.add(fmt.Sprintf("aliases[%d]", i), err)
+			}
+		}
+		if am.mediaTexts, err = parseMediaTexts(am.Titles, am.Captions, am.translationLanguages()); err != nil {
+			p.add("", err)
+		}
 	}
-	return &am, nil
+	return &am, p.errs
 }
 
 // checkCommonMetadata checks the format of shared metadata fields.
-func checkCommonMetadata(cm *CommonMetadata) error {
+func checkCommonMetadata(cm *CommonMetadata, p *problems) {
 	// Set / check sort order.
-	switch cm.SortOrder {
-	case "", "taken", "taken:reverse", "name", "name:reverse", "mtime", "mtime:reverse":
-		// Empty sort order is allowed to support inheritance.
-		// It is set to "taken" only in the collection metadata.
-	default:
-		return fmt.Errorf("invalid sort order %s", cm.SortOrder)
-	}
-	// Check that filter entries have the form "include:FILE" or "exclude:FILE".
-	for _, entry := range cm.Filter {
-		parts := strings.Split(entry, ":")
-		if len(parts) != 2 || (parts[0] != "include" && parts[0] != "exclude") {
-			return fmt.Errorf("invalid filter entry: %s", entry)
+	// Empty sort order is allowed to support inheritance.
+	// It is set to "taken" only in the collection metadata.
+	if cm.SortOrder != "" && !slices.Contains(sortOrders, cm.SortOrder) {
+		p.addf("sort_order", "invalid sort order %s", cm.SortOrder)
+	}
+	// Check that filter entries have the form "include:REGEX" or "exclude:REGEX"
+	// and that REGEX compiles.
+	for i, entry := range cm.Filter {
+		if _, err := compileFilterEntry(entry); err != nil {
+			p.add(fmt.Sprintf("filter[%d]", i), err)
+		}
+	}
+}
+
+// checkPlainAccess checks that an access list outside an album has no per-photo entries.
+func checkPlainAccess(access []string, p *problems) {
+	for i, entry := range access {
+		if isMediaAccessEntry(entry) {
+			p.addf(fmt.Sprintf("access[%d]", i), "per-photo access entry %s can only be set in an album folder", entry)
 		}
 	}
-	return nil
 }
@@ -36,6 +36,38 @@ func TestParseCollectionMetadata(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Language",
+			input: `{
+				"version": "2",
+				"name": "My Collection",
+				"language": "EN-us",
+				"url": "https://example.com/photos",
+				"storage": "/srv/photos"
+			}`,
+			want: &CollectionMetadata{
+				Version:  "2",
+				Name:     "My Collection",
+				Language: "en-US",
+				URL:      "https://example.com/photos",
+				Storage:  "/srv/photos",
+				CommonMetadata: CommonMetadata{
+					SortOrder: "taken",
+					Filter:    []string{"include:.*"},
+				},
+			},
+		},
+		{
+			name: "Invalid language",
+			input: `{
+				"version": "2",
+				"name": "My Collection",
+				"language": "english",
+				"url": "https://example.com/photos",
+				"storage": "/srv/photos"
+			}`,
+			wantErr: true,
+		},
 		{
 			name: "Missing version",
 			input: `{
@@ -47,6 +79,17 @@ func TestParseCollectionMetadata(t *testing.T) {
 			}`,
 			wantErr: true,
 		},
+		{
+			name: "Unknown version",
+			input: `{
+				"version": "99",
+				"name": "My Collection",
+				"url": "https://example.com/photos",
+				"s3_access_code": "ACCESSCODE123",
+				"s3_secret_key": "SECRETKEY123"
+			}`,
+			wantErr: true,
+		},
 		{
 			name: "Invalid URL",
 			input: `{
@@ -142,7 +185,7 @@ func TestParseCollectionMetadata(t *testing.T) {
 				"s3_access_code": "ACCESSCODE123",
 				"s3_secret_key": "SECRETKEY123",
 				"max_size": 1024,
-				"filter": ["include:*.jpg", "exclude:*.png"]
+				"filter": ["include:.*\\.jpg", "exclude:.*\\.png"]
 			}`,
 			wantErr: false,
 			want: &CollectionMetadata{
@@ -154,10 +197,23 @@ func TestParseCollectionMetadata(t *testing.T) {
 				MaxSize:      1024,
 				CommonMetadata: CommonMetadata{
 					SortOrder: "taken",
-					Filter:    []string{"include:*.jpg", "exclude:*.png"},
+					Filter:    []string{"include:.*\\.jpg", "exclude:.*\\.png"},
 				},
 			},
 		},
+		{
+			name: "Invalid filter regexp",
+			input: `{
+				"version": "1",
+				"name": "My Collection",
+				"url": "https://example.com/photos",
+				"s3_access_code": "ACCESSCODE123",
+				"s3_secret_key": "SECRETKEY123",
+				"max_size": 1024,
+				"filter": ["include:*.jpg"]
+			}`,
+			wantErr: true,
+		},
 		{
 			name: "Empty filter",
 			input: `{
@@ -1,7 +1,9 @@
 package metadata
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,38 +12,34 @@ import (
 // ReadMetadata reads the medata of an LBX photo collection, recursively traversing
 // the directory structure and parsing metadata files. Returns an error if the metadata
 // cannot be read or is invalid, or otherwise a flat list of AlbumMetadata objects, one
-// per album (media directory) in the collection.
+// per album (media directory) in the collection. Reading continues past invalid
+// metadata files, so that the error, an Errors, lists every problem in the collection.
 func ReadMetadata(root string) ([]*AlbumMetadata, error) {
-	// Read root metadata file (metadata.json) and parse it.
-	fn := root + "/metadata.json"
-	txt, err := os.ReadFile(fn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata file: %v", err)
-	}
-	mdCollection, err := ParseCollectionMetadata(txt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse collection metadata: %v", err)
+	mdCollection, errs, ok := readCollectionMetadata(root)
+	if !ok {
+		return nil, errs
 	}
 
-	// Recursively read metadata of subdirectories.
+	// Recursively read metadata of subdirectories. If the collection metadata is
+	// invalid, albums inherit nothing from it, so that its problems are reported once.
 	mdList := []*AlbumMetadata{}
-	mdAlbum := &AlbumMetadata{
-		CommonMetadata: mdCollection.CommonMetadata,
+	mdAlbum := &AlbumMetadata{}
+	if mdCollection != nil {
+		mdAlbum.Version = mdCollection.Version
+		if len(errs) == 0 {
+			mdAlbum.CommonMetadata = mdCollection.CommonMetadata
+		}
 	}
 	dirEntries, err := os.ReadDir(root)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %v", err)
+		return nil, append(errs, readError(root, "directory", err))
 	}
 	for _, e := range dirEntries {
 		if !e.IsDir() {
 			continue
 		}
 		subdir := filepath.Join(root, e.Name())
-		mdl, err := recursivelyReadMetadata(subdir, mdAlbum)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read metadata: %v", err)
-		}
-		mdList = append(mdList, mdl...)
+		mdList = append(mdList, recursivelyReadMetadata(subdir, mdAlbum, &errs)...)
 	}
 	// Sort the list of albums by relative path.
 	for _, md := range mdList {
@@ -50,18 +48,49 @@ func ReadMetadata(root string) ([]*AlbumMetadata, error) {
 	sort.Slice(mdList, func(i, j int) bool {
 		return mdList[i].Path < mdList[j].Path
 	})
+	errs = append(errs, checkAliases(root, mdList)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return mdList, nil
 }
 
-// recursivelyReadMetadata reads the metadata of a directory and its subdirectories.
-func recursivelyReadMetadata(path string, mdParent *AlbumMetadata) ([]*AlbumMetadata, error) {
+// ReadCollectionMetadata reads and parses the root metadata file of an LBX photo
+// collection. Unlike ParseCollectionMetadata, it also checks that the credential
+// references of a collection published to S3 can be resolved.
+func ReadCollectionMetadata(root string) (*CollectionMetadata, error) {
+	mdCollection, errs, _ := readCollectionMetadata(root)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return mdCollection, nil
+}
+
+// readCollectionMetadata reads and parses the root metadata file of an LBX photo
+// collection, returning every problem found. The metadata is nil if it could not
+// be decoded; ok is false if the file could not be read at all.
+func readCollectionMetadata(root string) (md *CollectionMetadata, errs Errors, ok bool) {
+	// Read root metadata file (metadata.json) and parse it.
+	fn := filepath.Join(root, "metadata.json")
+	txt, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, Errors{readError(fn, "metadata file", err)}, false
+	}
+	md, errs = parseCollectionMetadata(txt, true)
+	return md, errs.withPath(fn), true
+}
+
+// recursivelyReadMetadata reads the metadata of a directory and its subdirectories,
+// appending the problems found to errs.
+func recursivelyReadMetadata(path string, mdParent *AlbumMetadata, errs *Errors) []*AlbumMetadata {
 	// Determine whether file is an album (media directory).
 	// If yes, it must contain a metadata file. Parse it and return it.
 	// If not, read the metadata file if it exists, then recursively read subdirectories.
 	// Inherit/override attributes between parent and child metadata as appropriate.
 	dirEntries, err := os.ReadDir(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %v", err)
+		*errs = append(*errs, readError(path, "directory", err))
+		return nil
 	}
 	isAlbum := true
 	for _, e := range dirEntries {
@@ -70,27 +99,52 @@ func recursivelyReadMetadata(path string, mdParent *AlbumMetadata) ([]*AlbumMeta
 			break
 		}
 	}
-	fn := path + "/metadata.json"
+	// A directory without a valid metadata file inherits its parent's metadata,
+	// including whether it is enabled, so that its subdirectories are published
+	// (and their problems found) as if it had none.
+	mdCur := &AlbumMetadata{CommonMetadata: CommonMetadata{Enabled: true}}
+	fn := filepath.Join(path, "metadata.json")
 	txt, err := os.ReadFile(fn)
-	if err != nil {
-		// Media directory must contain a metadata file.
-		if isAlbum && os.IsNotExist(err) {
-			return nil, fmt.Errorf("missing metadata file in media directory: %v", path)
+	switch {
+	case err == nil:
+		// File versions are only checked against a known collection version.
+		collection := mdParent.Version
+		if versionIndex(collection) < 0 {
+			collection = ""
+		}
+		md, fileErrs := parseAlbumMetadata(txt, isAlbum, collection)
+		if len(fileErrs) == 0 {
+			mdCur = md
 		}
+		*errs = append(*errs, fileErrs.withPath(fn)...)
+	case os.IsNotExist(err):
+		// Media directory must contain a metadata file.
 		// Non-media directory is allowed to not contain a metadata file.
-		if !isAlbum && !os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to read metadata file: %v", err)
+		if isAlbum {
+			*errs = append(*errs, &Error{Path: path, Err: errors.New("missing metadata file in media directory")})
 		}
+	default:
+		*errs = append(*errs, readError(fn, "metadata file", err))
 	}
-	mdCur, err := ParseAlbumMetadata(txt, isAlbum)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse album metadata: %v", err)
-	}
+	mdCur.Version = mdParent.Version
 	// Merge metadata, implementing inheritance rules.
 	mdCur.merge(mdParent)
 	if isAlbum {
+		files := map[string]bool{}
+		for _, e := range dirEntries {
+			files[e.Name()] = true
+		}
+		*errs = append(*errs, mdCur.checkMediaAccess().withPath(fn)...)
+		*errs = append(*errs, mdCur.readSidecars(path, files)...)
+		if err := mdCur.checkMediaTexts(files); err != nil {
+			*errs = append(*errs, &Error{Path: fn, Err: err})
+		}
 		mdCur.Path = path
-		return []*AlbumMetadata{mdCur}, nil
+		// Compile the complete filter chain once per album.
+		if mdCur.filter, err = CompileFilter(mdCur.Filter); err != nil {
+			*errs = append(*errs, &Error{Path: path, Err: err})
+		}
+		return []*AlbumMetadata{mdCur}
 	}
 
 	mdList := []*AlbumMetadata{}
@@ -99,11 +153,17 @@ func recursivelyReadMetadata(path string, mdParent *AlbumMetadata) ([]*AlbumMeta
 			continue
 		}
 		subdir := filepath.Join(path, e.Name())
-		mdl, err := recursivelyReadMetadata(subdir, mdCur)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read metadata: %v", err)
-		}
-		mdList = append(mdList, mdl...)
+		mdList = append(mdList, recursivelyReadMetadata(subdir, mdCur, errs)...)
 	}
-	return mdList, nil
+	return mdList
+}
+
+// readError returns the problem of a failed read of a file or directory. The
+// path is not repeated in the message.
+func readError(path, what string, err error) *Error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &Error{Path: path, Err: fmt.Errorf("failed to read %s: %v", what, err)}
 }
@@ -169,6 +169,39 @@ func TestMultipleAlbums(t *testing.T) {
 	}
 }
 
+func TestIntermediateNoMetadata(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	// Directories without metadata file inherit whether their parent is enabled.
+	initCollection(t, rootDir)
+	for _, dir := range []string{"europe", "private", "private/old"} {
+		if err := os.Mkdir(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create subdir: %v", err)
+		}
+	}
+	createFile(t, filepath.Join(rootDir, "private"), "metadata.json", `{"version": "1", "enabled": false}`)
+	initAlbum(t, rootDir, "europe/paris", `{"version": "1", "enabled": true, "title": "Paris"}`)
+	initAlbum(t, rootDir, "private/old/rome", `{"version": "1", "enabled": true, "title": "Rome"}`)
+
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	if len(mdList) != 2 {
+		t.Fatalf("Expected 2 album metadata, got %d", len(mdList))
+	}
+	if mdList[0].Path != "europe/paris" || !mdList[0].Enabled {
+		t.Errorf("Expected europe/paris enabled, got %s enabled %v", mdList[0].Path, mdList[0].Enabled)
+	}
+	if mdList[1].Path != "private/old/rome" || mdList[1].Enabled {
+		t.Errorf("Expected private/old/rome disabled, got %s enabled %v", mdList[1].Path, mdList[1].Enabled)
+	}
+	if len(mdList[0].Tags) != 2 || len(mdList[0].Access) != 2 {
+		t.Errorf("Expected tags and access of the collection, got %v, %v", mdList[0].Tags, mdList[0].Access)
+	}
+}
+
 func createTempDir(t *testing.T) string {
 	dir, err := os.MkdirTemp("", "metadata_test")
 	if err != nil {
@@ -210,3 +243,30 @@ func initAlbum(t *testing.T, rootDir, path, md string) {
 	}
 	createFile(t, subDir, "metadata.json", md)
 }
+
+func TestReadMediaTexts(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	initAlbum(t, rootDir, "paris", `{
+		"enabled": true,
+		"title": "Paris",
+		"translations": {"fr": {"title": "Paris"}},
+		"titles": ["a.jpg:The tower", "a.jpg:fr:La tour"],
+		"captions": ["b.jpg:From Trocadéro"]
+	}`)
+	createFile(t, filepath.Join(rootDir, "paris"), "a.jpg", "")
+	if _, err := ReadMetadata(rootDir); err == nil {
+		t.Fatalf("Expected error for caption of missing file b.jpg, got nil")
+	}
+	createFile(t, filepath.Join(rootDir, "paris"), "b.jpg", "")
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	texts, err := mdList[0].MediaTexts("a.jpg")
+	if err != nil || len(texts) != 2 || texts[1].Language != "fr" {
+		t.Errorf("MediaTexts(a.jpg) = %+v, %v", texts, err)
+	}
+}
@@ -12,7 +12,7 @@ type CommonMetadata struct {
 	// If a parent is disabled, all children are disabled.
 	Enabled bool `json:"enabled"`
 	// Tags is a list of tags that apply to photos.
-	// In the context of an album, a tag may optionally have the format'
+	// In the context of an album, a tag may optionally have the format
 	// "FILENAME:TAG", where FILENAME is the filename of a photo in the album.
 	// Tags accumulate from parent to child.
 	Tags []string `json:"tags"`
@@ -22,10 +22,17 @@ type CommonMetadata struct {
 	SortOrder string `json:"sort_order"`
 	// Access is a list of credentials that are granted read access.
 	// Access accumulates from parent to child. An empty access list means public access.
+	// In the context of an album, an entry may optionally have the format
+	// "FILENAME:KEY", where FILENAME is the filename of a photo in the album or a
+	// regexp that must match the entire filename. A photo matched by such entries
+	// is restricted to their keys: it is visible only with one of them. Per-photo
+	// entries narrow access, never widen it, so their keys must have access to the
+	// album.
 	Access []string `json:"access"`
 	// Filter is an ordered list of filters to apply to photos to determine which ones
 	// are uploaded for display by LBX.
-	// Each entry has the form: "include:FILE" or "exclude:FILE". FILE is a filename or a regexp.
+	// Each entry has the form: "include:FILE" or "exclude:FILE". FILE is a filename or a regexp,
+	// which must match the entire filename.
 	// Default is ["include:.*"].
 	// Filters are evaluated sequentially starting with the album directory and moving out.
 	// First rule to match wins. If no rule matches, photo is not included.
@@ -36,45 +43,129 @@ type CommonMetadata struct {
 type CollectionMetadata struct {
 	// CommonMetadata is the metadata that applies to collections or albums.
 	CommonMetadata
-	// Version is the version of the metadata format.
+	// Version is the version of the metadata format (see CurrentVersion).
 	Version string `json:"version"`
 	// Name is the name of the collection.
 	Name string `json:"name"`
 	// Author is the author of the collection.
 	Author string `json:"author"`
+	// Language is the BCP 47 code of the default language of the collection
+	// (e.g., "en"), in which album titles and blurbs and photo titles and captions
+	// are written unless they are translations. Optional, but without it viewers
+	// cannot be served the default texts by language.
+	Language string `json:"language"`
 	// URL is the base URL of the collection (e.g., "https://janesmith.com/photos").
 	URL string `json:"url"`
-	// S3AccessCode is the access code for the S3 bucket.
+	// Storage is the location where the collection is published: a directory
+	// (absolute or relative to the collection root), a file:// URL, or an
+	// s3://BUCKET URL (see storage.Open). Default is S3.
+	Storage string `json:"storage"`
+	// Server is the base URL of the lbxd server through which the collection is
+	// published (e.g., "https://photos.example.com"), instead of Storage.
+	Server string `json:"server"`
+	// S3AccessCode is the access code for the S3 bucket. Either a reference,
+	// "env:NAME" (environment variable NAME) or "file:PATH" (contents of file PATH),
+	// or, deprecated, the access code itself. Not required for filesystem storage
+	// or when publishing through a server.
 	S3AccessCode string `json:"s3_access_code"`
-	// S3SecretKey is the secret key for the S3 bucket.
+	// S3SecretKey is the secret key for the S3 bucket, in the same format as S3AccessCode.
 	S3SecretKey string `json:"s3_secret_key"`
 	// MaxSize is the maximum photo display size (pixels of longest side), or "0" for no limit.
 	MaxSize int `json:"max_size"`
+	// Warnings lists non-fatal problems found by ParseCollectionMetadata, such as
+	// deprecated literal credentials.
+	Warnings []string `json:"-"`
 }
 
-// AlbumMetadata represents the metadata of an LBX photo album.
+// AlbumMetadata represents the metadata of an LBX photo album or of an
+// intermediate directory, in which only the fields of CommonMetadata and the
+// version may be set.
 type AlbumMetadata struct {
 	// CommonMetadata is the metadata that applies to collections or albums.
 	CommonMetadata
-	// Title is the title of the album.
+	// Version is the version of the metadata format, which must be that of the
+	// collection. Required from version 2 (see CurrentVersion).
+	Version string `json:"version"`
+	// Title is the title of the album in the default language of the collection.
+	// Required in albums.
 	Title string `json:"title"`
+	// Blurb is a longer description of the album in the default language.
+	Blurb string `json:"blurb"`
+	// Translations maps BCP 47 language codes (e.g., "fr", "pt-BR") to the title
+	// and blurb of the album in that language. See AlbumTexts.
+	Translations map[string]Translation `json:"translations"`
 	// TitlePhoto is the filename of the title photo.
 	TitlePhoto string `json:"title_photo"`
 	// HighlightPhoto is the filename of the highlight photo.
 	HighlightPhoto string `json:"highlight_photo"`
 	// Aliases is a list of path aliases for the album, relative to the
-	// collection root. Aliases must be unique within the collection.
+	// collection root, without leading or trailing slash or "." or ".."
+	// components. Aliases must be unique within the collection and differ from
+	// the paths of all albums and folders.
 	Aliases []string `json:"aliases"`
 	// Titles is a list of photo titles. The format of each entry is:
-	// "FILENAME:[LANG:]TITLE". FILENAME is a photo filename, LANG is an
-	// optional language code, and TITLE is the title.
+	// "FILENAME:[LANG:]TITLE". FILENAME is the filename of a photo in the album,
+	// LANG is an optional language of the album's Translations (default is the
+	// language of the collection), and TITLE is the title, which may contain
+	// colons. If TITLE starts with something that looks like a language code and
+	// a colon (e.g., "Re:"), LANG must be given, possibly empty
+	// ("FILENAME::Re: ..."); otherwise the entry is rejected as ambiguous. There
+	// may be one title per photo and language. See MediaTexts.
 	Titles []string `json:"titles"`
-	// Captions is a list of photo captions. The format of each entry is:
-	// "FILENAME:[LANG:]CAPTION". FILENAME is a photo filename, LANG is an
-	// optional language code, and CAPTION is the caption.
+	// Captions is a list of photo captions, in the same format as Titles.
 	Captions []string `json:"captions"`
 	// Path is the path of the album relative to the collection root.
 	Path string
+	// filter is the compiled filter chain, set by ReadMetadata.
+	filter *Filter
+	// mediaAccess is the compiled list of per-photo access entries, set by
+	// ParseAlbumMetadata or on first use by MediaAccess.
+	mediaAccess []accessRule
+	// mediaTexts maps filenames to their parsed Titles and Captions, set by
+	// ParseAlbumMetadata or on first use by MediaTexts.
+	mediaTexts map[string][]MediaText
+	// sidecars maps filenames to the metadata of their sidecar files, set by ReadMetadata.
+	sidecars map[string]*MediaMetadata
+}
+
+// Translation is the title and blurb of an album in one language.
+type Translation struct {
+	// Title is the title of the album in this language. Required.
+	Title string `json:"title"`
+	// Blurb is the blurb of the album in this language.
+	Blurb string `json:"blurb"`
+}
+
+// MediaMetadata represents the metadata of a single photo, read from a sidecar
+// file named after it (e.g., "IMG_1234.jpg.json" for "IMG_1234.jpg") in the
+// album directory. It complements the per-photo entries of the album metadata:
+// the title or caption of the photo in a language, its tags and its access keys
+// may each be set in the sidecar or in the album metadata, but not in both.
+type MediaMetadata struct {
+	// Title is the title of the photo in the default language of the collection.
+	Title string `json:"title"`
+	// Caption is the caption of the photo in the default language.
+	Caption string `json:"caption"`
+	// Translations maps BCP 47 language codes to the title and caption of the
+	// photo in that language.
+	Translations map[string]MediaTranslation `json:"translations"`
+	// Tags is a list of tags of the photo.
+	Tags []string `json:"tags"`
+	// Access is a list of access keys to which the photo is restricted (see
+	// CommonMetadata.Access). Entries are plain keys, without a FILENAME, that
+	// must have access to the album.
+	Access []string `json:"access"`
+	// texts are the parsed texts of the photo, set by ParseMediaMetadata.
+	texts []MediaText
+}
+
+// MediaTranslation is the title and caption of a photo in one language.
+type MediaTranslation struct {
+	// Title is the title of the photo in this language.
+	Title string `json:"title"`
+	// Caption is the caption of the photo in this language. At least one of
+	// Title and Caption is required.
+	Caption string `json:"caption"`
 }
 
 // merge merges the receiver metadata with the given metadata. The receiver
@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/exif"
+)
+
+// sortOrderCodes maps sort orders to the values of the albums.sort_order column
+// (see internal/server/db/migrations).
+var sortOrderCodes = map[string]int{
+	"name":          0,
+	"name:reverse":  1,
+	"mtime":         2,
+	"mtime:reverse": 3,
+	"taken":         4,
+	"taken:reverse": 5,
+}
+
+// SortOrderCode returns the albums.sort_order value of a sort order.
+func SortOrderCode(sortOrder string) (int, error) {
+	code, ok := sortOrderCodes[sortOrder]
+	if !ok {
+		return 0, fmt.Errorf("invalid sort order %s", sortOrder)
+	}
+	return code, nil
+}
+
+// CaptureTime returns the time a photo was taken according to its EXIF data (see
+// exif.Exif.CaptureTime). Returns the zero time if none is available (e.g., the
+// file is not a JPEG or TIFF).
+// Times recorded without a UTC offset are taken to be in the local time zone, so
+// that they compare consistently with modification times. This is the value
+// stored in the media.exif_time column.
+func CaptureTime(path string) time.Time {
+	e, err := exif.ReadFile(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return e.CaptureTime()
+}
+
+// SortMedia sorts media files in place according to a sort order (see CommonMetadata.SortOrder).
+//
+// "name" sorts by filename. "mtime" sorts by modification time. "taken" sorts by
+// capture time (see CaptureTime), falling back to the modification time for files
+// without EXIF times. Ties are broken by filename, so the order is total and does not
+// depend on the input order. ":reverse" orders are the exact reverse of the base order.
+//
+// The server orders media the same way, using COALESCE(exif_time, mtime) and source_filename.
+func SortMedia(files []MediaFile, sortOrder string) error {
+	if _, err := SortOrderCode(sortOrder); err != nil {
+		return err
+	}
+	keys := make(map[string]time.Time, len(files))
+	switch sortOrder {
+	case "mtime", "mtime:reverse":
+		for _, f := range files {
+			keys[f.Name] = f.ModTime
+		}
+	case "taken", "taken:reverse":
+		for _, f := range files {
+			t := CaptureTime(f.Path)
+			if t.IsZero() {
+				t = f.ModTime
+			}
+			keys[f.Name] = t
+		}
+	}
+	less := func(a, b MediaFile) bool {
+		if ta, tb := keys[a.Name], keys[b.Name]; !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return a.Name < b.Name
+	}
+	switch sortOrder {
+	case "name:reverse", "mtime:reverse", "taken:reverse":
+		sort.Slice(files, func(i, j int) bool { return less(files[j], files[i]) })
+	default:
+		sort.Slice(files, func(i, j int) bool { return less(files[i], files[j]) })
+	}
+	return nil
+}
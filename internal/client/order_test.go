@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortMedia(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	// Capture times: b.jpg 2019 (DateTimeOriginal), a.jpg 2021 (DateTimeOriginal),
+	// d.tif 1998 (DateTimeDigitized only). c.jpg and e.jpg have no EXIF data and
+	// fall back to their mtime (both 2020, tied and broken by name).
+	fixtures := map[string]string{
+		"a.jpg": "nikon.jpg",
+		"b.jpg": "canon.jpg",
+		"c.jpg": "plain.jpg",
+		"d.tif": "scan.tif",
+		"e.jpg": "plain.jpg",
+	}
+	mtimes := map[string]time.Time{
+		"a.jpg": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		"b.jpg": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"c.jpg": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"d.tif": time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		"e.jpg": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	files := []MediaFile{}
+	for name, src := range fixtures {
+		data, err := os.ReadFile(filepath.Join("..", "exif", "testdata", src))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		createFile(t, dir, name, string(data))
+		path := filepath.Join(dir, name)
+		if err := os.Chtimes(path, mtimes[name], mtimes[name]); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+		files = append(files, MediaFile{Name: name, Path: path, ModTime: mtimes[name]})
+	}
+
+	tests := []struct {
+		sortOrder string
+		want      []string
+	}{
+		{"name", []string{"a.jpg", "b.jpg", "c.jpg", "d.tif", "e.jpg"}},
+		{"name:reverse", []string{"e.jpg", "d.tif", "c.jpg", "b.jpg", "a.jpg"}},
+		{"mtime", []string{"a.jpg", "c.jpg", "e.jpg", "d.tif", "b.jpg"}},
+		{"mtime:reverse", []string{"b.jpg", "d.tif", "e.jpg", "c.jpg", "a.jpg"}},
+		{"taken", []string{"d.tif", "b.jpg", "c.jpg", "e.jpg", "a.jpg"}},
+		{"taken:reverse", []string{"a.jpg", "e.jpg", "c.jpg", "b.jpg", "d.tif"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sortOrder, func(t *testing.T) {
+			sorted := append([]MediaFile{}, files...)
+			if err := SortMedia(sorted, tt.sortOrder); err != nil {
+				t.Fatalf("SortMedia() error = %v", err)
+			}
+			got := []string{}
+			for _, f := range sorted {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortMedia(%s) = %v, want %v", tt.sortOrder, got, tt.want)
+			}
+		})
+	}
+
+	if err := SortMedia(files, "size"); err == nil {
+		t.Errorf("Expected error for invalid sort order, got nil")
+	}
+}
+
+func TestSortOrderCode(t *testing.T) {
+	for order, want := range map[string]int{"name": 0, "name:reverse": 1, "mtime": 2, "mtime:reverse": 3, "taken": 4, "taken:reverse": 5} {
+		if got, err := SortOrderCode(order); err != nil || got != want {
+			t.Errorf("SortOrderCode(%s) = %d, %v, want %d", order, got, err, want)
+		}
+	}
+	if _, err := SortOrderCode(""); err == nil {
+		t.Errorf("Expected error for empty sort order, got nil")
+	}
+}
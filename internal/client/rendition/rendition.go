@@ -0,0 +1,224 @@
+// Package rendition generates the JPEG renditions (resized copies) of photos
+// stored in the blobs table (see internal/server/db/migrations).
+package rendition
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register GIF decoder.
+	"image/jpeg"
+	_ "image/png" // Register PNG decoder.
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/maxpoletto/lbx/internal/exif"
+)
+
+// DefaultSizes is the default ladder of rendition sizes (longest side, in pixels):
+// thumbnail, small, medium and large.
+var DefaultSizes = []int{256, 640, 1280, 2048}
+
+// DefaultQuality is the default JPEG quality of renditions.
+const DefaultQuality = 85
+
+// ErrUnsupported is returned for files that cannot be decoded (e.g., videos).
+var ErrUnsupported = errors.New("rendition: unsupported image format")
+
+// extensions is the set of (lowercase) file extensions of the formats that Render decodes.
+var extensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// CanRender reports whether name has the extension of a photo format that Render
+// decodes. Other photo formats (e.g., TIFF or HEIC) cannot be rendered.
+func CanRender(name string) bool {
+	return extensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// Rendition is a resized JPEG copy of a photo (one row of the blobs table).
+type Rendition struct {
+	// Width and Height are the dimensions of the rendition, after applying the EXIF orientation.
+	Width  int
+	Height int
+	// MaxDim is the longest side of the rendition (blobs.max_dim).
+	MaxDim int
+	// ContentHash is the hex SHA-256 hash of Data (blobs.content_hash).
+	ContentHash string
+	// Data is the JPEG-encoded rendition.
+	Data []byte
+}
+
+// Ladder returns the rendition sizes for a collection: the sizes in sizes that are
+// smaller than maxSize (CollectionMetadata.MaxSize), followed by maxSize. If maxSize
+// is 0 (no limit), the last size is 0, which denotes the full resolution of the photo.
+func Ladder(sizes []int, maxSize int) []int {
+	ladder := []int{}
+	for _, s := range sizes {
+		if s > 0 && (maxSize == 0 || s < maxSize) {
+			ladder = append(ladder, s)
+		}
+	}
+	sort.Ints(ladder)
+	return append(ladder, maxSize)
+}
+
+// Render generates renditions of the photo at path for each size in sizes (see Ladder),
+// honoring its EXIF orientation. Photos are never upscaled: sizes larger than the photo
+// yield a single full-resolution rendition. Renditions are returned from smallest to largest.
+func Render(path string, sizes []int, quality int) ([]Rendition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, fmt.Errorf("%s: %w", path, ErrUnsupported)
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	orientation := 0
+	if e, err := exif.ReadFile(path); err == nil {
+		orientation = e.Orientation
+	}
+	img := orient(toRGBA(src), orientation)
+	full := max(img.Bounds().Dx(), img.Bounds().Dy())
+
+	// Generate renditions from largest to smallest, each from the previous one,
+	// so that large photos are only scaled down once at full resolution.
+	targets := []int{}
+	for _, s := range sizes {
+		if s <= 0 || s > full {
+			s = full
+		}
+		targets = append(targets, s)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(targets)))
+	renditions := []Rendition{}
+	for i, s := range targets {
+		if i > 0 && s == targets[i-1] {
+			continue
+		}
+		b := img.Bounds()
+		w, h := scaledSize(b.Dx(), b.Dy(), s)
+		if w != b.Dx() || h != b.Dy() {
+			img = resize(img, w, h)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		hash := sha256.Sum256(buf.Bytes())
+		renditions = append(renditions, Rendition{
+			Width:       w,
+			Height:      h,
+			MaxDim:      max(w, h),
+			ContentHash: hex.EncodeToString(hash[:]),
+			Data:        buf.Bytes(),
+		})
+	}
+	// Smallest first.
+	for i, j := 0, len(renditions)-1; i < j; i, j = i+1, j-1 {
+		renditions[i], renditions[j] = renditions[j], renditions[i]
+	}
+	return renditions, nil
+}
+
+// scaledSize returns the dimensions of a w x h image scaled so that its longest side is maxDim.
+func scaledSize(w, h, maxDim int) (int, int) {
+	if w >= h {
+		return maxDim, max(1, (h*maxDim+w/2)/w)
+	}
+	return max(1, (w*maxDim+h/2)/h), maxDim
+}
+
+// toRGBA converts an image to RGBA, using the fast paths of image/draw.
+func toRGBA(src image.Image) *image.RGBA {
+	if img, ok := src.(*image.RGBA); ok && img.Rect.Min == (image.Point{}) {
+		return img
+	}
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Rect, src, b.Min, draw.Src)
+	return img
+}
+
+// Result is the outcome of rendering one photo with RenderAll.
+type Result struct {
+	// Index is the index of the photo in the list passed to RenderAll.
+	Index int
+	// Path is the path of the photo.
+	Path       string
+	Renditions []Rendition
+	Err        error
+}
+
+// Options configures RenderAll.
+type Options struct {
+	// Sizes is the list of sizes to render (see Ladder).
+	Sizes []int
+	// Quality is the JPEG quality. Default is DefaultQuality.
+	Quality int
+	// Workers is the number of photos rendered concurrently. Default is the
+	// number of CPUs, at most 4 (decoded photos use a lot of memory).
+	Workers int
+}
+
+// RenderAll renders the photos at paths using a bounded pool of workers. fn is
+// called once per photo, in completion order, from the calling goroutine. If fn
+// returns an error, no more photos are rendered and RenderAll returns the error
+// once the photos being rendered are done, without calling fn again.
+func RenderAll(paths []string, opts Options, fn func(Result) error) error {
+	if opts.Quality == 0 {
+		opts.Quality = DefaultQuality
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = min(runtime.NumCPU(), 4)
+	}
+	jobs := make(chan int)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				rs, err := Render(paths[i], opts.Sizes, opts.Quality)
+				results <- Result{Index: i, Path: paths[i], Renditions: rs, Err: err}
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	go func() {
+		defer func() {
+			close(jobs)
+			wg.Wait()
+			close(results)
+		}()
+		for i := range paths {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var err error
+	for r := range results {
+		if err != nil {
+			continue
+		}
+		if err = fn(r); err != nil {
+			close(stop)
+		}
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package rendition
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// canon.jpg is 16x8 pixels, with EXIF orientation 6 (rotate 90 clockwise).
+const canonJPEG = "../../exif/testdata/canon.jpg"
+
+func TestLadder(t *testing.T) {
+	tests := []struct {
+		sizes   []int
+		maxSize int
+		want    []int
+	}{
+		{DefaultSizes, 0, []int{256, 640, 1280, 2048, 0}},
+		{DefaultSizes, 1600, []int{256, 640, 1280, 1600}},
+		{DefaultSizes, 2048, []int{256, 640, 1280, 2048}},
+		{[]int{640, 256}, 100, []int{100}},
+	}
+	for _, tt := range tests {
+		if got := Ladder(tt.sizes, tt.maxSize); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ladder(%v, %d) = %v, want %v", tt.sizes, tt.maxSize, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	// 100 and 0 exceed the photo size: both yield a single full-size rendition.
+	rs, err := Render(canonJPEG, []int{4, 8, 100, 0}, DefaultQuality)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := [][3]int{{2, 4, 4}, {4, 8, 8}, {8, 16, 16}}
+	if len(rs) != len(want) {
+		t.Fatalf("Render() returned %d renditions, want %d", len(rs), len(want))
+	}
+	for i, r := range rs {
+		if r.Width != want[i][0] || r.Height != want[i][1] || r.MaxDim != want[i][2] {
+			t.Errorf("rendition %d = %dx%d (max %d), want %dx%d (max %d)",
+				i, r.Width, r.Height, r.MaxDim, want[i][0], want[i][1], want[i][2])
+		}
+		img, err := jpeg.Decode(bytes.NewReader(r.Data))
+		if err != nil {
+			t.Fatalf("rendition %d: jpeg.Decode() error = %v", i, err)
+		}
+		if b := img.Bounds(); b.Dx() != r.Width || b.Dy() != r.Height {
+			t.Errorf("rendition %d: decoded size %dx%d, want %dx%d", i, b.Dx(), b.Dy(), r.Width, r.Height)
+		}
+		hash := sha256.Sum256(r.Data)
+		if r.ContentHash != hex.EncodeToString(hash[:]) {
+			t.Errorf("rendition %d: wrong content hash", i)
+		}
+	}
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "movie.mp4")
+	if err := os.WriteFile(fn, []byte("not an image"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := Render(fn, DefaultSizes, DefaultQuality); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Render() error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestCanRender(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.jpg": true, "b.JPEG": true, "c.png": true, "d.gif": true,
+		"e.tif": false, "f.heic": false, "g.webp": false, "h.mp4": false,
+	} {
+		if got := CanRender(name); got != want {
+			t.Errorf("CanRender(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	paths := []string{}
+	for i := 0; i < 20; i++ {
+		paths = append(paths, canonJPEG)
+	}
+	paths = append(paths, "missing.jpg")
+	seen := make([]bool, len(paths))
+	err := RenderAll(paths, Options{Sizes: []int{4, 0}, Workers: 3}, func(r Result) error {
+		if seen[r.Index] {
+			t.Errorf("photo %d rendered twice", r.Index)
+		}
+		seen[r.Index] = true
+		if r.Path == "missing.jpg" {
+			if r.Err == nil {
+				t.Errorf("Expected error for missing file")
+			}
+			return nil
+		}
+		if r.Err != nil || len(r.Renditions) != 2 {
+			t.Errorf("photo %d: %d renditions, error %v", r.Index, len(r.Renditions), r.Err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("RenderAll() error = %v", err)
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("photo %d not rendered", i)
+		}
+	}
+
+	// An error of fn stops rendering.
+	calls := 0
+	stop := errors.New("stop")
+	err = RenderAll(paths, Options{Sizes: []int{4}, Workers: 3}, func(r Result) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("RenderAll() = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
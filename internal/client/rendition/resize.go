@@ -0,0 +1,122 @@
+package rendition
+
+import (
+	"image"
+)
+
+// orient transforms an image stored with the given EXIF orientation (1-8) so that
+// it is displayed upright. Other orientation values leave the image unchanged.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	// srcPoint maps destination coordinates to source coordinates.
+	var srcPoint func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2: // Mirror horizontally.
+		srcPoint = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // Rotate 180.
+		srcPoint = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // Mirror vertically.
+		srcPoint = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // Transpose.
+		dw, dh = h, w
+		srcPoint = func(x, y int) (int, int) { return y, x }
+	case 6: // Rotate 90 clockwise.
+		dw, dh = h, w
+		srcPoint = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // Transverse.
+		dw, dh = h, w
+		srcPoint = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // Rotate 90 counterclockwise.
+		dw, dh = h, w
+		srcPoint = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := srcPoint(x, y)
+			si := src.PixOffset(sx+src.Rect.Min.X, sy+src.Rect.Min.Y)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// contribution is the weight of a source pixel in a destination pixel.
+type contribution struct {
+	index  int
+	weight float32
+}
+
+// weights computes, for each of dstLen destination pixels, the source pixels it
+// covers and their coverage (area averaging, suitable for downscaling).
+func weights(srcLen, dstLen int) [][]contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	ws := make([][]contribution, dstLen)
+	for i := range ws {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		for j := int(lo); j < srcLen && float64(j) < hi; j++ {
+			cover := min(hi, float64(j+1)) - max(lo, float64(j))
+			if cover > 0 {
+				ws[i] = append(ws[i], contribution{j, float32(cover / scale)})
+			}
+		}
+	}
+	return ws
+}
+
+// resize scales src to w x h by area averaging, in two separable passes.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	xw, yw := weights(sw, w), weights(sh, h)
+
+	// Horizontal pass: sw x sh -> w x sh.
+	tmp := make([]float32, w*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, cs := range xw {
+			var r, g, b, a float32
+			for _, c := range cs {
+				p := row[c.index*4 : c.index*4+4]
+				r += float32(p[0]) * c.weight
+				g += float32(p[1]) * c.weight
+				b += float32(p[2]) * c.weight
+				a += float32(p[3]) * c.weight
+			}
+			t := tmp[(y*w+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	// Vertical pass: w x sh -> w x h.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, cs := range yw {
+		for x := 0; x < w; x++ {
+			var r, g, b, a float32
+			for _, c := range cs {
+				t := tmp[(c.index*w+x)*4:]
+				r += t[0] * c.weight
+				g += t[1] * c.weight
+				b += t[2] * c.weight
+				a += t[3] * c.weight
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = clamp(r), clamp(g), clamp(b), clamp(a)
+		}
+	}
+	return dst
+}
+
+// clamp rounds v to the nearest byte value.
+func clamp(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,87 @@
+package rendition
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// newTestImage returns a w x h image whose pixel (x, y) has red = x and green = y.
+func newTestImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 source image; each case lists the source (x, y) of destination
+	// pixels (0, 0) and (1, 0), and the destination dimensions.
+	tests := []struct {
+		orientation int
+		w, h        int
+		p00, p10    [2]uint8
+	}{
+		{1, 3, 2, [2]uint8{0, 0}, [2]uint8{1, 0}},
+		{2, 3, 2, [2]uint8{2, 0}, [2]uint8{1, 0}},
+		{3, 3, 2, [2]uint8{2, 1}, [2]uint8{1, 1}},
+		{4, 3, 2, [2]uint8{0, 1}, [2]uint8{1, 1}},
+		{5, 2, 3, [2]uint8{0, 0}, [2]uint8{0, 1}},
+		{6, 2, 3, [2]uint8{0, 1}, [2]uint8{0, 0}},
+		{7, 2, 3, [2]uint8{2, 1}, [2]uint8{2, 0}},
+		{8, 2, 3, [2]uint8{2, 0}, [2]uint8{2, 1}},
+	}
+	for _, tt := range tests {
+		img := orient(newTestImage(3, 2), tt.orientation)
+		if img.Rect.Dx() != tt.w || img.Rect.Dy() != tt.h {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, img.Rect.Dx(), img.Rect.Dy(), tt.w, tt.h)
+			continue
+		}
+		for i, want := range [][2]uint8{tt.p00, tt.p10} {
+			c := img.RGBAAt(i, 0)
+			if c.R != want[0] || c.G != want[1] {
+				t.Errorf("orientation %d: pixel (%d, 0) = (%d, %d), want (%d, %d)", tt.orientation, i, c.R, c.G, want[0], want[1])
+			}
+		}
+	}
+}
+
+func TestWeightsSumToOne(t *testing.T) {
+	for _, tt := range [][2]int{{10, 3}, {4000, 256}, {7, 7}, {5, 4}} {
+		for i, cs := range weights(tt[0], tt[1]) {
+			var sum float64
+			for _, c := range cs {
+				sum += float64(c.weight)
+			}
+			if math.Abs(sum-1) > 1e-4 {
+				t.Errorf("weights(%d, %d)[%d] sum = %v, want 1", tt[0], tt[1], i, sum)
+			}
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// A uniform image stays uniform.
+	img := image.NewRGBA(image.Rect(0, 0, 101, 53))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 200, 100, 50, 255
+	}
+	dst := resize(img, 10, 5)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		if dst.Pix[i] != 200 || dst.Pix[i+1] != 100 || dst.Pix[i+2] != 50 || dst.Pix[i+3] != 255 {
+			t.Fatalf("resize() pixel %d = %v, want [200 100 50 255]", i/4, dst.Pix[i:i+4])
+		}
+	}
+	// Halving averages pairs of pixels.
+	dst = resize(newTestImage(4, 2), 2, 1)
+	if c := dst.RGBAAt(0, 0); c.R != 1 || c.G != 1 {
+		t.Errorf("resize() pixel (0, 0) = %v, want R=1 G=1", c)
+	}
+	if c := dst.RGBAAt(1, 0); c.R != 3 || c.G != 1 {
+		t.Errorf("resize() pixel (1, 0) = %v, want R=3 G=1", c)
+	}
+}
@@ -0,0 +1,173 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewFile is a metadata file to be created by `lbx init`.
+type NewFile struct {
+	// Path is the path of the metadata file.
+	Path string
+	// Data is the contents of the file.
+	Data []byte
+}
+
+// collectionFile is the contents of a new collection metadata file. Fields are
+// in the order in which they are written.
+type collectionFile struct {
+	Version      string `json:"version"`
+	Name         string `json:"name"`
+	Author       string `json:"author,omitempty"`
+	Language     string `json:"language,omitempty"`
+	URL          string `json:"url"`
+	Storage      string `json:"storage,omitempty"`
+	Server       string `json:"server,omitempty"`
+	S3AccessCode string `json:"s3_access_code,omitempty"`
+	S3SecretKey  string `json:"s3_secret_key,omitempty"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// albumFile is the contents of a new album metadata file.
+type albumFile struct {
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NewCollectionFile returns the contents of a new metadata file for a collection
+// in the current version of the metadata format, with the name, author, language,
+// URL, storage, server, S3 credentials and Enabled of cm. Returns an error if they are
+// invalid.
+func NewCollectionFile(cm *CollectionMetadata) ([]byte, error) {
+	data, err := marshalMetadata(collectionFile{
+		Version:      CurrentVersion,
+		Name:         cm.Name,
+		Author:       cm.Author,
+		Language:     cm.Language,
+		URL:          cm.URL,
+		Storage:      cm.Storage,
+		Server:       cm.Server,
+		S3AccessCode: cm.S3AccessCode,
+		S3SecretKey:  cm.S3SecretKey,
+		Enabled:      cm.Enabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ParseCollectionMetadata(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// NewAlbumFiles returns new metadata files, in the given version of the metadata
+// format, for the albums (directories without subdirectories) of the collection
+// rooted at root that have none, sorted by path. Existing files are never
+// replaced. The title of each album is derived from the name of its directory
+// (see albumTitle); if dates is true, it is followed by the range of capture dates
+// of its photos (see CaptureTime), if any. Intermediate directories need no
+// metadata file: they inherit the metadata of their parent, so whether the albums
+// are enabled only depends on enabled and on the collection.
+func NewAlbumFiles(root, version string, enabled, dates bool) ([]NewFile, error) {
+	files := []NewFile{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				return nil
+			}
+		}
+		fn := filepath.Join(path, "metadata.json")
+		if _, err := os.Stat(fn); err == nil || !os.IsNotExist(err) {
+			return err
+		}
+		title := albumTitle(d.Name())
+		if dates {
+			if r := captureDateRange(path, entries); r != "" {
+				title += " (" + r + ")"
+			}
+		}
+		data, err := marshalMetadata(albumFile{Version: version, Title: title, Enabled: enabled})
+		if err != nil {
+			return err
+		}
+		files = append(files, NewFile{Path: fn, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// albumTitle derives the title of an album from the name of its directory:
+// underscores separate words, and runs of spaces are collapsed (e.g.,
+// "2019_Paris__trip" becomes "2019 Paris trip").
+func albumTitle(dir string) string {
+	title := strings.Join(strings.Fields(strings.ReplaceAll(dir, "_", " ")), " ")
+	if title == "" {
+		return dir
+	}
+	return title
+}
+
+// captureDateRange returns the range of capture dates of the media files among
+// the entries of directory dir (e.g., "2019-07-14" or "2019-07-14 – 2019-07-21"),
+// or "" if none has a capture time.
+func captureDateRange(dir string, entries []fs.DirEntry) string {
+	var first, last time.Time
+	for _, e := range entries {
+		if e.IsDir() || !IsMediaFile(e.Name()) {
+			continue
+		}
+		t := CaptureTime(filepath.Join(dir, e.Name()))
+		if t.IsZero() {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if last.IsZero() || t.After(last) {
+			last = t
+		}
+	}
+	if first.IsZero() {
+		return ""
+	}
+	const layout = "2006-01-02"
+	if first.Format(layout) == last.Format(layout) {
+		return first.Format(layout)
+	}
+	return fmt.Sprintf("%s – %s", first.Format(layout), last.Format(layout))
+}
+
+// marshalMetadata returns the contents of a metadata file holding v. Characters
+// such as "&" are written as is, since metadata files are edited by hand.
+func marshalMetadata(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,145 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCollectionFile(t *testing.T) {
+	data, err := NewCollectionFile(&CollectionMetadata{
+		CommonMetadata: CommonMetadata{Enabled: true},
+		Name:           "Jane's Photos",
+		Language:       "en",
+		URL:            "https://janesmith.com/photos",
+		Storage:        "/srv/photos",
+	})
+	if err != nil {
+		t.Fatalf("NewCollectionFile() error = %v", err)
+	}
+	want := `{
+  "version": "2",
+  "name": "Jane's Photos",
+  "language": "en",
+  "url": "https://janesmith.com/photos",
+  "storage": "/srv/photos",
+  "enabled": true
+}
+`
+	if string(data) != want {
+		t.Errorf("NewCollectionFile() = %s, want %s", data, want)
+	}
+	if _, err := NewCollectionFile(&CollectionMetadata{Name: "C", Storage: "/srv/photos"}); err == nil {
+		t.Errorf("NewCollectionFile() without URL error = nil, want error")
+	}
+}
+
+func TestNewAlbumFiles(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	for _, dir := range []string{"2019_Paris  trip", "europe/rome", "europe/oslo", "empty"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	// Capture times: canon.jpg 2019-07-14, nikon.jpg 2021-12-24.
+	paris := filepath.Join(rootDir, "2019_Paris  trip")
+	for name, src := range map[string]string{"a.jpg": "canon.jpg", "b.jpg": "nikon.jpg", "c.jpg": "plain.jpg"} {
+		data, err := os.ReadFile(filepath.Join("..", "exif", "testdata", src))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		createFile(t, paris, name, string(data))
+	}
+	rome := `{"version": "1", "title": "Roma"}`
+	createFile(t, filepath.Join(rootDir, "europe", "rome"), "metadata.json", rome)
+
+	files, err := NewAlbumFiles(rootDir, "1", false, true)
+	if err != nil {
+		t.Fatalf("NewAlbumFiles() error = %v", err)
+	}
+	want := []NewFile{
+		{filepath.Join(paris, "metadata.json"), []byte("{\n  \"version\": \"1\",\n  \"title\": \"2019 Paris trip (2019-07-14 – 2021-12-24)\",\n  \"enabled\": false\n}\n")},
+		{filepath.Join(rootDir, "empty", "metadata.json"), []byte("{\n  \"version\": \"1\",\n  \"title\": \"empty\",\n  \"enabled\": false\n}\n")},
+		{filepath.Join(rootDir, "europe", "oslo", "metadata.json"), []byte("{\n  \"version\": \"1\",\n  \"title\": \"oslo\",\n  \"enabled\": false\n}\n")},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("NewAlbumFiles() = %d files, want %d", len(files), len(want))
+	}
+	for i, f := range files {
+		if f.Path != want[i].Path || string(f.Data) != string(want[i].Data) {
+			t.Errorf("NewAlbumFiles()[%d] = %s: %s, want %s: %s", i, f.Path, f.Data, want[i].Path, want[i].Data)
+		}
+		createFile(t, filepath.Dir(f.Path), "metadata.json", string(f.Data))
+	}
+
+	// The collection is now complete, and existing files are left alone.
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if len(mdList) != 4 {
+		t.Errorf("ReadMetadata() = %d albums, want 4", len(mdList))
+	}
+	if data, _ := os.ReadFile(filepath.Join(rootDir, "europe", "rome", "metadata.json")); string(data) != rome {
+		t.Errorf("Existing metadata file changed to %s", data)
+	}
+	if files, err := NewAlbumFiles(rootDir, "1", false, true); err != nil || len(files) != 0 {
+		t.Errorf("NewAlbumFiles() = %d files, %v, want none", len(files), err)
+	}
+}
+
+func TestNewAlbumFilesNested(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	for _, dir := range []string{"europe/paris", "europe/italy/rome"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for _, enabled := range []bool{true, false} {
+		files, err := NewAlbumFiles(rootDir, "1", enabled, false)
+		if err != nil {
+			t.Fatalf("NewAlbumFiles() error = %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("NewAlbumFiles() = %d files, want 2", len(files))
+		}
+		for _, f := range files {
+			createFile(t, filepath.Dir(f.Path), "metadata.json", string(f.Data))
+		}
+		mdList, err := ReadMetadata(rootDir)
+		if err != nil {
+			t.Fatalf("ReadMetadata() error = %v", err)
+		}
+		if len(mdList) != 2 {
+			t.Fatalf("ReadMetadata() = %d albums, want 2", len(mdList))
+		}
+		for _, md := range mdList {
+			if md.Enabled != enabled {
+				t.Errorf("album %s enabled = %t, want %t", md.Path, md.Enabled, enabled)
+			}
+		}
+		for _, f := range files {
+			os.Remove(f.Path)
+		}
+	}
+}
+
+func TestAlbumTitle(t *testing.T) {
+	for dir, want := range map[string]string{
+		"Paris":             "Paris",
+		"2019_Paris__trip":  "2019 Paris trip",
+		"2019-07 Rome ":     "2019-07 Rome",
+		"_":                 "_",
+		"Rock & Roll Hall ": "Rock & Roll Hall",
+	} {
+		if got := albumTitle(dir); got != want {
+			t.Errorf("albumTitle(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package metadata
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// Kinds of metadata files described by Schema.
+const (
+	// SchemaCollection is the metadata file at the root of a collection.
+	SchemaCollection = "collection"
+	// SchemaAlbum is the metadata file of an album or intermediate directory.
+	SchemaAlbum = "album"
+	// SchemaSidecar is the sidecar file of a photo (see MediaMetadata).
+	SchemaSidecar = "sidecar"
+)
+
+// schemaDialect is the version of JSON Schema used by Schema.
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// languagePattern is the shape of BCP 47 language codes. CanonicalLanguage,
+// which the parsers use, is stricter.
+const languagePattern = `^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`
+
+// mediaTextPattern is the shape of the entries of AlbumMetadata.Titles and
+// AlbumMetadata.Captions (see parseTextEntry).
+const mediaTextPattern = `^[^:]+:.*\S`
+
+// aliasPattern is the form of album aliases (see checkAlias): path components
+// that are not empty, "." or "..", separated by slashes.
+const aliasPattern = `^([^/.][^/]*|\.[^/.][^/]*|\.\.[^/]+)(/([^/.][^/]*|\.[^/.][^/]*|\.\.[^/]+))*$`
+
+// schemaKeywords holds the constraints that the schema cannot derive from the Go
+// types. Keys are "TYPE" for struct types and "TYPE.FIELD" for fields, where FIELD
+// is the JSON name. A field of an embedded struct may be overridden by the
+// embedding struct. Keywords under "items" apply to the entries of list fields.
+// Descriptions are not kept here: they are the doc comments of metadata.go (see
+// metadataDocs).
+var schemaKeywords = map[string]map[string]any{
+	"CollectionMetadata": {
+		"required": []string{"version", "name", "url"},
+		// The S3 credentials are required unless the collection is published
+		// to a directory or through a server (see ParseCollectionMetadata).
+		"if": map[string]any{
+			"not": map[string]any{
+				"anyOf": []any{
+					map[string]any{
+						"required":   []string{"server"},
+						"properties": map[string]any{"server": map[string]any{"minLength": 1}},
+					},
+					map[string]any{
+						"required": []string{"storage"},
+						"properties": map[string]any{"storage": map[string]any{
+							"minLength": 1,
+							"anyOf": []any{
+								map[string]any{"pattern": "^file://"},
+								map[string]any{"not": map[string]any{"pattern": "://"}},
+							},
+						}},
+					},
+				},
+			},
+		},
+		"then": map[string]any{
+			"required": []string{"s3_access_code", "s3_secret_key"},
+			"properties": map[string]any{
+				"s3_access_code": map[string]any{"minLength": 1},
+				"s3_secret_key":  map[string]any{"minLength": 1},
+			},
+		},
+	},
+	"Translation": {
+		"required": []string{"title"},
+	},
+	// A sidecar translation needs a title or a caption (see ParseMediaMetadata).
+	"MediaTranslation": {
+		"anyOf": []any{
+			map[string]any{"required": []string{"title"}, "properties": map[string]any{"title": map[string]any{"pattern": `\S`}}},
+			map[string]any{"required": []string{"caption"}, "properties": map[string]any{"caption": map[string]any{"pattern": `\S`}}},
+		},
+	},
+	"CommonMetadata.sort_order": {
+		"enum": sortOrders,
+	},
+	"CommonMetadata.access": {
+		"items": map[string]any{"pattern": "^[^:]*$"},
+	},
+	"AlbumMetadata.access": {
+		"items": map[string]any{"pattern": "^([^:]*|.+:[^:]+)$"},
+	},
+	"CommonMetadata.filter": {
+		"items": map[string]any{"pattern": "^(include|exclude):"},
+	},
+	"CollectionMetadata.version": {
+		"enum": knownVersions,
+	},
+	"CollectionMetadata.name": {
+		"minLength": 1,
+	},
+	"CollectionMetadata.language": {
+		"pattern": languagePattern,
+	},
+	"CollectionMetadata.url": {
+		"pattern": urlPattern,
+	},
+	"CollectionMetadata.server": {
+		"pattern": "^(https?://[^/?#]+.*)?$",
+	},
+	"CollectionMetadata.s3_access_code": {
+		"not": map[string]any{"enum": []string{envPrefix, filePrefix}},
+	},
+	"CollectionMetadata.s3_secret_key": {
+		"not": map[string]any{"enum": []string{envPrefix, filePrefix}},
+	},
+	"CollectionMetadata.max_size": {
+		"minimum": 0,
+	},
+	"AlbumMetadata.version": {
+		"enum": knownVersions,
+	},
+	"AlbumMetadata.translations": {
+		"propertyNames": map[string]any{"pattern": languagePattern},
+	},
+	"AlbumMetadata.aliases": {
+		"items": map[string]any{"pattern": aliasPattern},
+	},
+	"AlbumMetadata.titles": {
+		"items": map[string]any{"pattern": mediaTextPattern},
+	},
+	"AlbumMetadata.captions": {
+		"items": map[string]any{"pattern": mediaTextPattern},
+	},
+	"Translation.title": {
+		"pattern": `\S`,
+	},
+	"MediaMetadata.translations": {
+		"propertyNames": map[string]any{"pattern": languagePattern},
+	},
+	"MediaMetadata.tags": {
+		"items": map[string]any{"minLength": 1},
+	},
+	"MediaMetadata.access": {
+		"items": map[string]any{"pattern": "^[^:]+$"},
+	},
+}
+
+//go:embed metadata.go
+var metadataSource string
+
+// metadataDocs returns the doc comments of the types declared in metadata.go and
+// of their fields, which describe them in the schema. Keys are "TYPE" and
+// "TYPE.FIELD", where FIELD is the Go name; comments are joined into one line.
+func metadataDocs() (map[string]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "metadata.go", metadataSource, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]string{}
+	text := func(cg *ast.CommentGroup) string {
+		return strings.Join(strings.Fields(cg.Text()), " ")
+	}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			// A single type declaration documents its type with the
+			// declaration's comment.
+			if ts.Doc != nil {
+				docs[ts.Name.Name] = text(ts.Doc)
+			} else if gd.Doc != nil {
+				docs[ts.Name.Name] = text(gd.Doc)
+			}
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					if field.Doc != nil {
+						docs[ts.Name.Name+"."+name.Name] = text(field.Doc)
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+// Schema returns the JSON Schema of metadata files of the given kind, or of any
+// metadata file if kind is empty. The schema is generated from the types that
+// metadata files decode into and their doc comments, so that editors can
+// complete, describe and check them.
+func Schema(kind string) ([]byte, error) {
+	docs, err := metadataDocs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata docs: %v", err)
+	}
+	g := &schemaGenerator{docs: docs, defs: map[string]any{}, used: map[string]bool{}}
+	collection := g.ref(reflect.TypeOf(CollectionMetadata{}))
+	album := g.ref(reflect.TypeOf(AlbumMetadata{}))
+	sidecar := g.ref(reflect.TypeOf(MediaMetadata{}))
+	s := map[string]any{"$schema": schemaDialect, "$defs": g.defs}
+	switch kind {
+	case SchemaCollection:
+		s["title"] = "LBX collection metadata"
+		mergeKeywords(s, collection)
+	case SchemaAlbum:
+		s["title"] = "LBX album metadata"
+		mergeKeywords(s, album)
+	case SchemaSidecar:
+		s["title"] = "LBX photo sidecar metadata"
+		mergeKeywords(s, sidecar)
+	case "":
+		s["title"] = "LBX metadata"
+		s["anyOf"] = []any{collection, album, sidecar}
+	default:
+		return nil, fmt.Errorf("unknown metadata kind %q (want %s, %s or %s)", kind, SchemaCollection, SchemaAlbum, SchemaSidecar)
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaGenerator generates the schema of Go types.
+type schemaGenerator struct {
+	// docs are the doc comments of metadata types and fields (see metadataDocs).
+	docs map[string]string
+	// defs are the schemas of struct types by name.
+	defs map[string]any
+	// used is the set of keys of schemaKeywords used so far.
+	used map[string]bool
+}
+
+// ref returns a reference to the schema of struct type t, which it defines if needed.
+func (g *schemaGenerator) ref(t reflect.Type) map[string]any {
+	if _, ok := g.defs[t.Name()]; !ok {
+		// Defined before its fields, in case of recursive types.
+		def := map[string]any{"type": "object", "additionalProperties": false}
+		g.defs[t.Name()] = def
+		props := map[string]any{}
+		g.properties(t, t, props)
+		def["properties"] = props
+		g.describe(def, t.Name())
+		g.apply(def, t.Name())
+	}
+	return map[string]any{"$ref": "#/$defs/" + t.Name()}
+}
+
+// properties adds the schemas of the fields of struct type st, which is t or is
+// embedded in t, to props.
+func (g *schemaGenerator) properties(t, st reflect.Type, props map[string]any) {
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			g.properties(t, f.Type, props)
+			continue
+		}
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		s := g.schema(f.Type)
+		g.describe(s, st.Name()+"."+f.Name)
+		if _, ok := schemaKeywords[t.Name()+"."+name]; ok {
+			g.apply(s, t.Name()+"."+name)
+		} else {
+			g.apply(s, st.Name()+"."+name)
+		}
+		props[name] = s
+	}
+}
+
+// schema returns the schema of a value of type t.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+	panic(fmt.Sprintf("no schema for metadata type %s", t))
+}
+
+// describe sets the description of schema s to the doc comment docs[key], if any.
+func (g *schemaGenerator) describe(s map[string]any, key string) {
+	if doc, ok := g.docs[key]; ok {
+		s["description"] = doc
+	}
+}
+
+// apply adds the keywords of schemaKeywords[key] to schema s.
+func (g *schemaGenerator) apply(s map[string]any, key string) {
+	g.used[key] = true
+	mergeKeywords(s, schemaKeywords[key])
+}
+
+// mergeKeywords adds the keywords of src to dst, merging subschemas present in both.
+func mergeKeywords(dst, src map[string]any) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if dsub, ok := dst[k].(map[string]any); ok {
+				mergeKeywords(dsub, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
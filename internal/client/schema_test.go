@@ -0,0 +1,287 @@
+package metadata
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// validate reports whether the JSON value v is valid according to schema s. It
+// supports the keywords that Schema uses; root holds the definitions of "$ref"s.
+func validate(t *testing.T, root, s map[string]any, v any) bool {
+	t.Helper()
+	for k, kv := range s {
+		switch k {
+		case "$schema", "$defs", "title", "description", "then":
+		case "$ref":
+			name := strings.TrimPrefix(kv.(string), "#/$defs/")
+			if !validate(t, root, root["$defs"].(map[string]any)[name].(map[string]any), v) {
+				return false
+			}
+		case "type":
+			ok := false
+			switch kv {
+			case "object":
+				_, ok = v.(map[string]any)
+			case "array":
+				_, ok = v.([]any)
+			case "string":
+				_, ok = v.(string)
+			case "boolean":
+				_, ok = v.(bool)
+			case "integer":
+				f, isNum := v.(float64)
+				ok = isNum && f == math.Trunc(f)
+			default:
+				t.Fatalf("unsupported type %v", kv)
+			}
+			if !ok {
+				return false
+			}
+		case "properties":
+			obj, _ := v.(map[string]any)
+			for name, ps := range kv.(map[string]any) {
+				if pv, ok := obj[name]; ok && !validate(t, root, ps.(map[string]any), pv) {
+					return false
+				}
+			}
+		case "additionalProperties":
+			obj, _ := v.(map[string]any)
+			props, _ := s["properties"].(map[string]any)
+			for name, pv := range obj {
+				if _, ok := props[name]; ok {
+					continue
+				}
+				if as, ok := kv.(map[string]any); ok {
+					if !validate(t, root, as, pv) {
+						return false
+					}
+				} else if kv == false {
+					return false
+				}
+			}
+		case "propertyNames":
+			obj, _ := v.(map[string]any)
+			for name := range obj {
+				if !validate(t, root, kv.(map[string]any), name) {
+					return false
+				}
+			}
+		case "required":
+			obj, ok := v.(map[string]any)
+			for _, name := range kv.([]any) {
+				if _, present := obj[name.(string)]; ok && !present {
+					return false
+				}
+			}
+		case "items":
+			arr, _ := v.([]any)
+			for _, item := range arr {
+				if !validate(t, root, kv.(map[string]any), item) {
+					return false
+				}
+			}
+		case "enum":
+			if !slices.ContainsFunc(kv.([]any), func(e any) bool { return reflect.DeepEqual(e, v) }) {
+				return false
+			}
+		case "pattern":
+			if str, ok := v.(string); ok && !regexp.MustCompile(kv.(string)).MatchString(str) {
+				return false
+			}
+		case "minLength":
+			if str, ok := v.(string); ok && float64(len([]rune(str))) < kv.(float64) {
+				return false
+			}
+		case "minimum":
+			if f, ok := v.(float64); ok && f < kv.(float64) {
+				return false
+			}
+		case "not":
+			if validate(t, root, kv.(map[string]any), v) {
+				return false
+			}
+		case "anyOf":
+			if !slices.ContainsFunc(kv.([]any), func(sub any) bool { return validate(t, root, sub.(map[string]any), v) }) {
+				return false
+			}
+		case "if":
+			if validate(t, root, kv.(map[string]any), v) {
+				if then, ok := s["then"].(map[string]any); ok && !validate(t, root, then, v) {
+					return false
+				}
+			}
+		default:
+			t.Fatalf("unsupported keyword %s", k)
+		}
+	}
+	return true
+}
+
+func loadSchema(t *testing.T, kind string) map[string]any {
+	t.Helper()
+	data, err := Schema(kind)
+	if err != nil {
+		t.Fatalf("Schema(%q) error = %v", kind, err)
+	}
+	var s map[string]any
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("Schema(%q) is not JSON: %v", kind, err)
+	}
+	return s
+}
+
+func TestSchemaParity(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  string
+		data  string
+		valid bool
+	}{
+		{"Minimal collection", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv/photos"}`, true},
+		{"Full collection", SchemaCollection, `{
+			"version": "2", "name": "C", "author": "A", "url": "https://example.com/photos",
+			"s3_access_code": "env:S3_CODE", "s3_secret_key": "file:/etc/lbx/key", "max_size": 2048,
+			"enabled": true, "tags": ["t"], "sort_order": "name:reverse", "access": ["family"],
+			"filter": ["include:.*", "exclude:IMG_1.jpg"]
+		}`, true},
+		{"Collection published through a server", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "server": "https://photos.example.com"}`, true},
+		{"Collection with file URL storage", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "file:///srv/photos"}`, true},
+		{"Collection with S3 storage", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "s3://bucket", "s3_access_code": "env:A", "s3_secret_key": "env:B"}`, true},
+		{"Missing S3 credentials", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "s3://bucket", "s3_access_code": "env:A"}`, false},
+		{"Empty S3 credentials", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "s3_access_code": "", "s3_secret_key": ""}`, false},
+		{"Incomplete credential reference", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "s3_access_code": "env:", "s3_secret_key": "env:B"}`, false},
+		{"Missing version", SchemaCollection, `{"name": "C", "url": "https://example.com", "storage": "/srv"}`, false},
+		{"Unknown version", SchemaCollection, `{"version": "99", "name": "C", "url": "https://example.com", "storage": "/srv"}`, false},
+		{"Missing name", SchemaCollection, `{"version": "2", "url": "https://example.com", "storage": "/srv"}`, false},
+		{"Invalid URL", SchemaCollection, `{"version": "2", "name": "C", "url": "example.com", "storage": "/srv"}`, false},
+		{"Invalid server", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "server": "ftp://example.com"}`, false},
+		{"Negative max size", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv", "max_size": -1}`, false},
+		{"Fractional max size", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv", "max_size": 1.5}`, false},
+		{"Per-photo access in collection", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv", "access": ["a.jpg:family"]}`, false},
+		{"Album field in collection", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv", "title": "T"}`, false},
+		{"Misspelled collection field", SchemaCollection, `{"version": "2", "name": "C", "url": "https://example.com", "storage": "/srv", "sort-order": "name"}`, false},
+
+		{"Minimal album", SchemaAlbum, `{"version": "2", "title": "T"}`, true},
+		{"Full album", SchemaAlbum, `{
+			"version": "2", "title": "T", "blurb": "B",
+			"translations": {"fr": {"title": "T", "blurb": "B"}, "pt-BR": {"title": "T"}},
+			"title_photo": "a.jpg", "highlight_photo": "b.jpg", "aliases": ["old/t", ".hidden/..t"],
+			"titles": ["a.jpg:Tower", "a.jpg:fr:Tour", "b.jpg::Re: tower"], "captions": ["a.jpg:At night"],
+			"enabled": true, "tags": ["a.jpg:night"], "sort_order": "mtime", "access": ["family", "IMG_.*:friends"],
+			"filter": ["exclude:.*\\.mov"]
+		}`, true},
+		{"Invalid sort order", SchemaAlbum, `{"version": "2", "title": "T", "sort_order": "size"}`, false},
+		{"Invalid filter", SchemaAlbum, `{"version": "2", "title": "T", "filter": ["keep:.*"]}`, false},
+		{"Invalid title entry", SchemaAlbum, `{"version": "2", "title": "T", "titles": ["a.jpg"]}`, false},
+		{"Empty caption", SchemaAlbum, `{"version": "2", "title": "T", "captions": ["a.jpg: "]}`, false},
+		{"Invalid access entry", SchemaAlbum, `{"version": "2", "title": "T", "access": ["a.jpg:"]}`, false},
+		{"Invalid language", SchemaAlbum, `{"version": "2", "title": "T", "translations": {"english": {"title": "T"}}}`, false},
+		{"Translation without title", SchemaAlbum, `{"version": "2", "title": "T", "translations": {"fr": {"blurb": "B"}}}`, false},
+		{"Blank translation title", SchemaAlbum, `{"version": "2", "title": "T", "translations": {"fr": {"title": " "}}}`, false},
+		{"Misspelled album field", SchemaAlbum, `{"version": "2", "title": "T", "translations": {"fr": {"title": "T", "blrub": "B"}}}`, false},
+		{"Wrong type", SchemaAlbum, `{"version": "2", "title": "T", "tags": "night"}`, false},
+		{"Invalid alias", SchemaAlbum, `{"version": "2", "title": "T", "aliases": ["europe/../paris"]}`, false},
+		{"Alias with leading slash", SchemaAlbum, `{"version": "2", "title": "T", "aliases": ["/paris"]}`, false},
+		{"Alias with trailing slash", SchemaAlbum, `{"version": "2", "title": "T", "aliases": ["paris/"]}`, false},
+		{"Collection field in album", SchemaAlbum, `{"version": "2", "title": "T", "name": "C"}`, false},
+
+		{"Empty sidecar", SchemaSidecar, `{}`, true},
+		{"Full sidecar", SchemaSidecar, `{
+			"title": "T", "caption": "C", "translations": {"fr": {"title": "T"}, "pt-BR": {"caption": "C"}},
+			"tags": ["night"], "access": ["family"]
+		}`, true},
+		{"Sidecar translation without texts", SchemaSidecar, `{"translations": {"fr": {"title": " "}}}`, false},
+		{"Invalid sidecar language", SchemaSidecar, `{"translations": {"french": {"title": "T"}}}`, false},
+		{"Empty sidecar tag", SchemaSidecar, `{"tags": [""]}`, false},
+		{"Per-photo access in sidecar", SchemaSidecar, `{"access": ["a.jpg:family"]}`, false},
+		{"Album field in sidecar", SchemaSidecar, `{"title": "T", "blurb": "B"}`, false},
+	}
+	schemas := map[string]map[string]any{
+		SchemaCollection: loadSchema(t, SchemaCollection),
+		SchemaAlbum:      loadSchema(t, SchemaAlbum),
+		SchemaSidecar:    loadSchema(t, SchemaSidecar),
+	}
+	anySchema := loadSchema(t, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			switch tt.kind {
+			case SchemaCollection:
+				_, err = ParseCollectionMetadata([]byte(tt.data))
+			case SchemaAlbum:
+				_, err = ParseAlbumMetadata([]byte(tt.data), true)
+			case SchemaSidecar:
+				_, err = ParseMediaMetadata([]byte(tt.data))
+			}
+			if (err == nil) != tt.valid {
+				t.Errorf("parser: error = %v, want valid %t", err, tt.valid)
+			}
+			var v any
+			if err := json.Unmarshal([]byte(tt.data), &v); err != nil {
+				t.Fatalf("invalid fixture: %v", err)
+			}
+			if got := validate(t, schemas[tt.kind], schemas[tt.kind], v); got != tt.valid {
+				t.Errorf("schema: valid = %t, want %t", got, tt.valid)
+			}
+			if tt.valid && !validate(t, anySchema, anySchema, v) {
+				t.Errorf("schema of any metadata file: valid = false, want true")
+			}
+		})
+	}
+}
+
+func TestSchemaDescriptions(t *testing.T) {
+	s := loadSchema(t, "")
+	for name, def := range s["$defs"].(map[string]any) {
+		def := def.(map[string]any)
+		if def["description"] == nil {
+			t.Errorf("%s has no description", name)
+		}
+		for field, fs := range def["properties"].(map[string]any) {
+			if fs.(map[string]any)["description"] == nil {
+				t.Errorf("%s.%s has no description", name, field)
+			}
+		}
+	}
+	// Descriptions are the doc comments of metadata.go.
+	docs, err := metadataDocs()
+	if err != nil {
+		t.Fatalf("metadataDocs() error = %v", err)
+	}
+	defs := s["$defs"].(map[string]any)
+	for _, tt := range []struct{ def, field, doc string }{
+		{"CollectionMetadata", "", "CollectionMetadata"},
+		{"CollectionMetadata", "max_size", "CollectionMetadata.MaxSize"},
+		{"AlbumMetadata", "access", "CommonMetadata.Access"},
+		{"MediaMetadata", "caption", "MediaMetadata.Caption"},
+	} {
+		got := defs[tt.def].(map[string]any)
+		if tt.field != "" {
+			got = got["properties"].(map[string]any)[tt.field].(map[string]any)
+		}
+		if got["description"] != docs[tt.doc] || docs[tt.doc] == "" {
+			t.Errorf("%s.%s description = %q, want doc of %s %q", tt.def, tt.field, got["description"], tt.doc, docs[tt.doc])
+		}
+	}
+	// Every entry of schemaKeywords names a type or field, and holds constraints only.
+	g := &schemaGenerator{defs: map[string]any{}, used: map[string]bool{}}
+	g.ref(reflect.TypeOf(CollectionMetadata{}))
+	g.ref(reflect.TypeOf(AlbumMetadata{}))
+	g.ref(reflect.TypeOf(MediaMetadata{}))
+	for key, kw := range schemaKeywords {
+		if !g.used[key] {
+			t.Errorf("schemaKeywords[%q] is unused", key)
+		}
+		if _, ok := kw["description"]; ok {
+			t.Errorf("schemaKeywords[%q] has a description; document the Go type or field instead", key)
+		}
+	}
+	if _, err := Schema("photo"); err == nil {
+		t.Errorf("Schema(photo) error = nil, want error")
+	}
+}
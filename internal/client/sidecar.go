@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sidecarExt is the extension of sidecar files, which is appended to the
+// filename of the photo they describe.
+const sidecarExt = ".json"
+
+// sidecarTarget returns the media file described by name, a file in an album
+// directory whose files are in files, if name is a sidecar file: the name of a
+// media file in the album followed by sidecarExt. Other JSON files are not
+// sidecars and are ignored.
+func sidecarTarget(name string, files map[string]bool) (string, bool) {
+	if !strings.EqualFold(filepath.Ext(name), sidecarExt) {
+		return "", false
+	}
+	target := name[:len(name)-len(sidecarExt)]
+	return target, files[target] && IsMediaFile(target)
+}
+
+// ParseMediaMetadata parses a photo sidecar file. If the metadata is invalid, the
+// error is an Errors listing every problem found.
+func ParseMediaMetadata(data []byte) (*MediaMetadata, error) {
+	var mm MediaMetadata
+	p, ok := decode(data, &mm)
+	if !ok {
+		return nil, p.errs
+	}
+	texts := []MediaText{}
+	if t := (MediaText{Title: strings.TrimSpace(mm.Title), Caption: strings.TrimSpace(mm.Caption)}); t.Title != "" || t.Caption != "" {
+		texts = append(texts, t)
+	}
+	seen := map[string]string{}
+	for _, lang := range sortedKeys(mm.Translations) {
+		tr := mm.Translations[lang]
+		field := "translations." + lang
+		canonical, err := CanonicalLanguage(lang)
+		if err != nil {
+			p.add(field, err)
+			continue
+		}
+		if other, ok := seen[canonical]; ok {
+			p.addf(field, "%q and %q are the same language", other, lang)
+			continue
+		}
+		seen[canonical] = lang
+		t := MediaText{Language: canonical, Title: strings.TrimSpace(tr.Title), Caption: strings.TrimSpace(tr.Caption)}
+		if t.Title == "" && t.Caption == "" {
+			p.addf(field, "missing title and caption for %s", lang)
+			continue
+		}
+		texts = append(texts, t)
+	}
+	sort.Slice(texts, func(i, j int) bool { return texts[i].Language < texts[j].Language })
+	mm.texts = texts
+	for i, tag := range mm.Tags {
+		if tag == "" {
+			p.addf(fmt.Sprintf("tags[%d]", i), "empty tag")
+		}
+	}
+	for i, key := range mm.Access {
+		if key == "" || isMediaAccessEntry(key) {
+			p.addf(fmt.Sprintf("access[%d]", i), "invalid access key %q", key)
+		}
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return &mm, nil
+}
+
+// readSidecars reads and parses the sidecar files of the album in directory dir,
+// whose files are in files. Returns the problems with every sidecar, including
+// conflicts with the album metadata; valid sidecars are used regardless.
+func (m *AlbumMetadata) readSidecars(dir string, files map[string]bool) Errors {
+	m.sidecars = map[string]*MediaMetadata{}
+	names := []string{}
+	for name := range files {
+		if _, ok := sidecarTarget(name, files); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var errs Errors
+	for _, name := range names {
+		fn := filepath.Join(dir, name)
+		target, _ := sidecarTarget(name, files)
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			errs = append(errs, &Error{Path: fn, Err: fmt.Errorf("failed to read sidecar file: %v", err)})
+			continue
+		}
+		mm, err := ParseMediaMetadata(data)
+		if err != nil {
+			errs = append(errs, err.(Errors).withPath(fn)...)
+			continue
+		}
+		errs = append(errs, m.sidecarConflicts(target, mm).withPath(fn)...)
+		for _, key := range mm.Access {
+			if err := m.checkMediaKey(key, "sidecar", target); err != nil {
+				err.Path = fn
+				errs = append(errs, err)
+			}
+		}
+		m.sidecars[target] = mm
+	}
+	return errs
+}
+
+// sidecarConflicts returns the problems with the fields of mm, the sidecar of the
+// photo name, that are also set for the photo in the album metadata.
+func (m *AlbumMetadata) sidecarConflicts(name string, mm *MediaMetadata) Errors {
+	var errs Errors
+	conflict := func(field, what string) {
+		errs = append(errs, fieldErrorf(field, "%s of %s already set in the album metadata", what, name))
+	}
+	// Invalid album texts and access entries are reported with the album metadata.
+	if _, err := m.MediaTexts(""); err == nil {
+		album := map[string]MediaText{}
+		for _, t := range m.mediaTexts[name] {
+			album[t.Language] = t
+		}
+		fields := map[string]string{"": ""}
+		for lang := range mm.Translations {
+			if canonical, err := CanonicalLanguage(lang); err == nil {
+				fields[canonical] = "translations." + lang + "."
+			}
+		}
+		for _, t := range mm.texts {
+			a, ok := album[t.Language]
+			if !ok {
+				continue
+			}
+			in := ""
+			if t.Language != "" {
+				in = " in " + t.Language
+			}
+			if t.Title != "" && a.Title != "" {
+				conflict(fields[t.Language]+"title", "title"+in)
+			}
+			if t.Caption != "" && a.Caption != "" {
+				conflict(fields[t.Language]+"caption", "caption"+in)
+			}
+		}
+	}
+	if len(mm.Tags) > 0 && len(m.MediaTags(name)) > 0 {
+		conflict("tags", "tags")
+	}
+	if access, err := m.MediaAccess(name); err == nil && len(mm.Access) > 0 && len(access) > 0 {
+		conflict("access", "access")
+	}
+	return errs
+}
+
+// mergeMediaTexts merges two lists of texts of a photo (e.g., from the album
+// metadata and from a sidecar) into one text per language. A title or caption
+// set in both, which sidecarConflicts reports, is taken from override.
+func mergeMediaTexts(base, override []MediaText) []MediaText {
+	byLang := map[string]*MediaText{}
+	for _, l := range [][]MediaText{base, override} {
+		for _, t := range l {
+			cur := byLang[t.Language]
+			if cur == nil {
+				cur = &MediaText{Language: t.Language}
+				byLang[t.Language] = cur
+			}
+			if t.Title != "" {
+				cur.Title = t.Title
+			}
+			if t.Caption != "" {
+				cur.Caption = t.Caption
+			}
+		}
+	}
+	texts := []MediaText{}
+	for _, t := range byLang {
+		texts = append(texts, *t)
+	}
+	sort.Slice(texts, func(i, j int) bool { return texts[i].Language < texts[j].Language })
+	return texts
+}
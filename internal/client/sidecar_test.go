@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMediaMetadata(t *testing.T) {
+	tests := []struct {
+		data      string
+		wantTexts []MediaText
+		wantErr   bool
+	}{
+		{`{}`, []MediaText{}, false},
+		{`{"title": " The tower ", "translations": {"fr": {"caption": "De Trocadéro"}, "pt-br": {"title": "A torre"}}}`,
+			[]MediaText{{"", "The tower", ""}, {"fr", "", "De Trocadéro"}, {"pt-BR", "A torre", ""}}, false},
+		{`{"translations": {"fr": {}}}`, nil, true},
+		{`{"translations": {"french": {"title": "La tour"}}}`, nil, true},
+		{`{"translations": {"en-us": {"title": "T"}, "en-US": {"title": "T"}}}`, nil, true},
+		{`{"tags": [""]}`, nil, true},
+		{`{"access": ["a.jpg:family"]}`, nil, true},
+		{`{"title": 1}`, nil, true},
+	}
+	for _, tt := range tests {
+		mm, err := ParseMediaMetadata([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMediaMetadata(%s) error = %v, wantErr %t", tt.data, err, tt.wantErr)
+		} else if err == nil && !reflect.DeepEqual(mm.texts, tt.wantTexts) {
+			t.Errorf("ParseMediaMetadata(%s) texts = %+v, want %+v", tt.data, mm.texts, tt.wantTexts)
+		}
+	}
+}
+
+func TestMergeMediaTexts(t *testing.T) {
+	album := []MediaText{{"", "The tower", "From Trocadéro"}, {"fr", "La tour", ""}}
+	sidecar := []MediaText{{"", "Eiffel Tower", ""}, {"de", "Der Turm", ""}, {"fr", "", "Du Trocadéro"}}
+	want := []MediaText{{"", "Eiffel Tower", "From Trocadéro"}, {"de", "Der Turm", ""}, {"fr", "La tour", "Du Trocadéro"}}
+	if got := mergeMediaTexts(album, sidecar); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeMediaTexts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadSidecars(t *testing.T) {
+	rootDir := createTempDir(t)
+	defer os.RemoveAll(rootDir)
+
+	initCollection(t, rootDir)
+	initAlbum(t, rootDir, "paris", `{
+		"enabled": true,
+		"title": "Paris",
+		"translations": {"de": {"title": "Paris"}},
+		"titles": ["a.jpg:de:Der Turm", "b.jpg:The river"],
+		"tags": ["b.jpg:river"],
+		"access": ["family", "friends", "b.jpg:family"]
+	}`)
+	album := filepath.Join(rootDir, "paris")
+	createFile(t, album, "a.jpg", "")
+	createFile(t, album, "b.jpg", "")
+	createFile(t, album, "notes.json", `["not", "a", "sidecar"]`)
+	createFile(t, album, "c.jpg.json", `{"title": 1}`)
+	createFile(t, album, "a.jpg.json", `{
+		"title": "Eiffel Tower",
+		"caption": "From Trocadéro",
+		"translations": {"fr": {"title": "La tour Eiffel"}},
+		"tags": ["night"],
+		"access": ["friends"]
+	}`)
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	md := mdList[0]
+	texts, err := md.MediaTexts("a.jpg")
+	want := []MediaText{{"", "Eiffel Tower", "From Trocadéro"}, {"de", "Der Turm", ""}, {"fr", "La tour Eiffel", ""}}
+	if err != nil || !reflect.DeepEqual(texts, want) {
+		t.Errorf("MediaTexts(a.jpg) = %+v, %v, want %+v", texts, err, want)
+	}
+	if texts, err := md.MediaTexts("b.jpg"); err != nil || len(texts) != 1 || texts[0].Title != "The river" {
+		t.Errorf("MediaTexts(b.jpg) = %+v, %v", texts, err)
+	}
+	if got := md.MediaTags("a.jpg"); !reflect.DeepEqual(got, []string{"night"}) {
+		t.Errorf("MediaTags(a.jpg) = %v, want [night]", got)
+	}
+	if got, err := md.MediaAccess("a.jpg"); err != nil || !reflect.DeepEqual(got, []string{"friends"}) {
+		t.Errorf("MediaAccess(a.jpg) = %v, %v, want [friends]", got, err)
+	}
+
+	// Sidecars must be valid and not set what the album metadata sets for the photo.
+	for _, tt := range []struct{ name, content, wantErr string }{
+		{"b.jpg.json", `{"translations": {"fr": {"title": "T"}, "FR": {"title": "T"}}}`, "b.jpg.json:1:25: translations.fr: \"FR\" and \"fr\" are the same language"},
+		{"b.jpg.json", `{"title": "River"}`, "b.jpg.json: title: title of b.jpg already set in the album metadata"},
+		{"b.jpg.json", `{"caption": "At dusk"}`, ""},
+		{"a.jpg.json", `{"translations": {"DE": {"title": "Turm"}}}`, "a.jpg.json: translations.DE.title: title in de of a.jpg already set in the album metadata"},
+		{"a.jpg.json", `{"translations": {"de": {"caption": "Am Abend"}}}`, ""},
+		{"b.jpg.json", `{"tags": ["seine"]}`, "b.jpg.json: tags: tags of b.jpg already set in the album metadata"},
+		{"b.jpg.json", `{"access": ["friends"]}`, "b.jpg.json: access: access of b.jpg already set in the album metadata"},
+		// Photos can only be restricted to keys that have access to the album.
+		{"a.jpg.json", `{"access": ["strangers"]}`, "a.jpg.json: access: sidecar restricts a.jpg to key strangers, which has no access to the album"},
+	} {
+		saved, _ := os.ReadFile(filepath.Join(album, tt.name))
+		createFile(t, album, tt.name, tt.content)
+		_, err := ReadMetadata(rootDir)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("ReadMetadata() with %s %s error = %v, want nil", tt.name, tt.content, err)
+		} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), filepath.Join(album, tt.wantErr))) {
+			t.Errorf("ReadMetadata() with %s %s error = %v, want %q", tt.name, tt.content, err, tt.wantErr)
+		}
+		if saved != nil {
+			createFile(t, album, tt.name, string(saved))
+		} else {
+			os.Remove(filepath.Join(album, tt.name))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// HashFile returns the hex SHA-256 hash of the contents of a file.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheEntry is the cached hash of a file, valid as long as the size and
+// modification time of the file do not change.
+type cacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+}
+
+// HashCache caches file hashes across runs, so that unchanged files of a large
+// collection do not have to be read again. A nil *HashCache hashes every file.
+type HashCache struct {
+	path    string
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// LoadHashCache loads the cache stored in the file at path. A missing or
+// unreadable cache file yields an empty cache.
+func LoadHashCache(path string) *HashCache {
+	hc := &HashCache{path: path, entries: map[string]cacheEntry{}}
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, &hc.entries) != nil {
+			hc.entries = map[string]cacheEntry{}
+		}
+	}
+	return hc
+}
+
+// Hash returns the hash of a media file, using the cache if possible.
+func (hc *HashCache) Hash(f metadata.MediaFile) (string, error) {
+	if hc == nil {
+		return HashFile(f.Path)
+	}
+	if e, ok := hc.entries[f.Path]; ok && e.Size == f.Size && e.ModTime.Equal(f.ModTime) {
+		return e.Hash, nil
+	}
+	hash, err := HashFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	hc.entries[f.Path] = cacheEntry{Size: f.Size, ModTime: f.ModTime, Hash: hash}
+	hc.dirty = true
+	return hash, nil
+}
+
+// Save writes the cache back to its file if it changed.
+func (hc *HashCache) Save() error {
+	if hc == nil || !hc.dirty {
+		return nil
+	}
+	data, err := json.Marshal(hc.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(hc.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(hc.path, data, 0644); err != nil {
+		return err
+	}
+	hc.dirty = false
+	return nil
+}
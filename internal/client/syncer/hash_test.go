@@ -0,0 +1,52 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+func TestHashCache(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "a.jpg")
+	writeFile(t, fn, "hello")
+	const helloHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := metadata.MediaFile{Name: "a.jpg", Path: fn, Size: 5, ModTime: mtime}
+
+	cachePath := filepath.Join(dir, "cache", "hashes.json")
+	hc := LoadHashCache(cachePath)
+	if h, err := hc.Hash(f); err != nil || h != helloHash {
+		t.Fatalf("Hash() = %s, %v, want %s", h, err, helloHash)
+	}
+	if err := hc.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Change the contents but not the size and mtime: the cached hash is used.
+	writeFile(t, fn, "HELLO")
+	hc = LoadHashCache(cachePath)
+	if h, _ := hc.Hash(f); h != helloHash {
+		t.Errorf("Hash() = %s, want cached %s", h, helloHash)
+	}
+	// A new mtime invalidates the entry.
+	f.ModTime = mtime.Add(time.Second)
+	if h, _ := hc.Hash(f); h == helloHash {
+		t.Errorf("Hash() returned stale cached hash")
+	}
+	// So does a nil cache.
+	var nilCache *HashCache
+	if h, _ := nilCache.Hash(f); h == helloHash {
+		t.Errorf("Hash() with nil cache returned stale hash")
+	}
+
+	// A corrupt cache file is ignored.
+	writeFile(t, cachePath, "{")
+	if hc := LoadHashCache(cachePath); len(hc.entries) != 0 {
+		t.Errorf("Expected empty cache, got %v", hc.entries)
+	}
+	os.Remove(cachePath)
+}
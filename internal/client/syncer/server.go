@@ -0,0 +1,340 @@
+package syncer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+	"github.com/maxpoletto/lbx/internal/client/rendition"
+	"github.com/maxpoletto/lbx/internal/exif"
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/xmp"
+)
+
+// ServerRemote publishes media to an lbxd server through its ingest API. Instead
+// of the source files of photos, it uploads their renditions and metadata, so the
+// server never needs access to the collection. Apply renders photos concurrently.
+// Videos are uploaded as is, in resumable chunks. The server keeps the source hashes and metadata digests of
+// published items, so comparing against it does not require an index.
+type ServerRemote struct {
+	c      *ingest.Client
+	albums []*metadata.AlbumMetadata
+	byPath map[string]*metadata.AlbumMetadata
+	sizes  []int
+	// language is the code of the default language of the collection, or "".
+	language string
+	// put is the set of albums already created in this sync.
+	put map[string]bool
+}
+
+// NewServerRemote returns a remote publishing through c the albums of a collection
+// (as returned by metadata.ReadMetadata) with renditions of at most maxSize pixels
+// (CollectionMetadata.MaxSize). Their default texts are in language
+// (CollectionMetadata.Language), if not empty.
+func NewServerRemote(c *ingest.Client, albums []*metadata.AlbumMetadata, maxSize int, language string) *ServerRemote {
+	r := &ServerRemote{
+		c:        c,
+		albums:   albums,
+		byPath:   map[string]*metadata.AlbumMetadata{},
+		sizes:    rendition.Ladder(rendition.DefaultSizes, maxSize),
+		language: language,
+		put:      map[string]bool{},
+	}
+	for _, md := range albums {
+		r.byPath[md.Path] = md
+	}
+	return r
+}
+
+// Accepts reports whether name is a video or a photo in a format that can be
+// rendered. The server has no other way of showing photos.
+func (r *ServerRemote) Accepts(name string) bool {
+	return metadata.IsVideoFile(name) || rendition.CanRender(name)
+}
+
+func (r *ServerRemote) List() ([]Item, error) {
+	sources, err := r.c.Sources()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(sources))
+	for _, s := range sources {
+		items = append(items, Item{Album: s.Album, Name: s.Filename, Hash: s.Hash, Size: s.Size, Digest: s.MetadataDigest})
+	}
+	return items, nil
+}
+
+// putAlbum creates or updates an album from its metadata.
+func (r *ServerRemote) putAlbum(md *metadata.AlbumMetadata) error {
+	texts, err := md.AlbumTexts()
+	if err != nil {
+		return err
+	}
+	a := &ingest.Album{
+		Language:       r.language,
+		Texts:          []ingest.AlbumText{},
+		SortOrder:      md.SortOrder,
+		Tags:           md.AlbumTags(),
+		Aliases:        md.Aliases,
+		Access:         md.AlbumAccess(),
+		TitlePhoto:     md.TitlePhoto,
+		HighlightPhoto: md.HighlightPhoto,
+	}
+	for _, t := range texts {
+		a.Texts = append(a.Texts, ingest.AlbumText{Language: t.Language, Title: t.Title, Blurb: t.Blurb})
+	}
+	if err := r.c.PutAlbum(filepath.ToSlash(md.Path), a); err != nil {
+		return err
+	}
+	r.put[md.Path] = true
+	return nil
+}
+
+// mediaDigest is the metadata of a media item that Digest covers: everything
+// that is published with the item but does not come from its source file.
+type mediaDigest struct {
+	Texts  []metadata.MediaText `json:"texts"`
+	Tags   []string             `json:"tags"`
+	Access []string             `json:"access"`
+	// Sizes are the rendition sizes of photos.
+	Sizes []int `json:"sizes,omitempty"`
+	// Sidecar is the hex SHA-256 hash of the XMP sidecar of the item, if any.
+	Sidecar string `json:"sidecar,omitempty"`
+}
+
+func (r *ServerRemote) Digest(md *metadata.AlbumMetadata, name, path string) (string, error) {
+	texts, err := md.MediaTexts(name)
+	if err != nil {
+		return "", err
+	}
+	access, err := md.MediaAccess(name)
+	if err != nil {
+		return "", err
+	}
+	d := mediaDigest{Texts: texts, Tags: md.MediaTags(name), Access: access}
+	if !metadata.IsVideoFile(name) {
+		d.Sizes = r.sizes
+	}
+	_, sidecar, err := xmp.ReadSidecar(path)
+	if err != nil {
+		return "", err
+	}
+	if sidecar != nil {
+		sum := sha256.Sum256(sidecar)
+		d.Sidecar = hex.EncodeToString(sum[:])
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (r *ServerRemote) Upload(item Item, path string) error {
+	m, err := r.media(item, path)
+	if err != nil {
+		return err
+	}
+	if m.Type == ingest.Video {
+		return r.putVideo(item, path, m)
+	}
+	renditions, err := rendition.Render(path, r.sizes, rendition.DefaultQuality)
+	return r.putPhoto(item, m, renditions, err)
+}
+
+// uploadAll uploads the items of changes like Upload, rendering photos
+// concurrently with rendition.RenderAll.
+func (r *ServerRemote) uploadAll(changes []Change, progress func(Change)) error {
+	var photos []Change
+	for _, c := range changes {
+		if !metadata.IsVideoFile(c.Item.Name) {
+			photos = append(photos, c)
+			continue
+		}
+		if progress != nil {
+			progress(c)
+		}
+		if err := r.Upload(c.Item, c.Path); err != nil {
+			return changeError(c, err)
+		}
+	}
+	paths := make([]string, len(photos))
+	for i, c := range photos {
+		paths[i] = c.Path
+	}
+	opts := rendition.Options{Sizes: r.sizes, Quality: rendition.DefaultQuality}
+	return rendition.RenderAll(paths, opts, func(res rendition.Result) error {
+		c := photos[res.Index]
+		if progress != nil {
+			progress(c)
+		}
+		m, err := r.media(c.Item, c.Path)
+		if err == nil {
+			err = r.putPhoto(c.Item, m, res.Renditions, res.Err)
+		}
+		if err != nil {
+			return changeError(c, err)
+		}
+		return nil
+	})
+}
+
+// media returns the media item to publish for item, from its source file at
+// path and the metadata of its album, without renditions. Creates the album if
+// it was not created yet in this sync.
+func (r *ServerRemote) media(item Item, path string) (*ingest.Media, error) {
+	md, ok := r.byPath[item.Album]
+	if !ok {
+		return nil, fmt.Errorf("unknown album %s", item.Album)
+	}
+	if !r.put[md.Path] {
+		if err := r.putAlbum(md); err != nil {
+			return nil, err
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	access, err := md.MediaAccess(item.Name)
+	if err != nil {
+		return nil, err
+	}
+	x, err := xmp.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	texts, tags, err := md.MediaDescription(item.Name, x)
+	if err != nil {
+		return nil, err
+	}
+	m := &ingest.Media{
+		Type:           ingest.Photo,
+		DisplayName:    strings.TrimSuffix(item.Name, filepath.Ext(item.Name)),
+		SourceHash:     item.Hash,
+		SourceSize:     item.Size,
+		MetadataDigest: item.Digest,
+		MTime:          fi.ModTime().UTC(),
+		Texts:          []ingest.MediaText{},
+		Tags:           tags,
+		Access:         access,
+		Renditions:     []ingest.Rendition{},
+	}
+	for _, t := range texts {
+		m.Texts = append(m.Texts, ingest.MediaText{Language: t.Language, Title: t.Title, Caption: t.Caption})
+	}
+	if metadata.IsVideoFile(item.Name) {
+		m.Type = ingest.Video
+	}
+	if e, err := exif.ReadFile(path); err == nil {
+		setExif(m, e)
+	}
+	return m, nil
+}
+
+// putVideo uploads a video as is, as a rendition without dimensions, and publishes it as m.
+func (r *ServerRemote) putVideo(item Item, path string, m *ingest.Media) error {
+	if err := r.uploadFile(item, path); err != nil {
+		return err
+	}
+	m.Renditions = append(m.Renditions, ingest.Rendition{ContentHash: item.Hash})
+	return r.c.PutMedia(filepath.ToSlash(item.Album), item.Name, m)
+}
+
+// putPhoto uploads the renditions of a photo, rendered with error err, and
+// publishes it as m.
+func (r *ServerRemote) putPhoto(item Item, m *ingest.Media, renditions []rendition.Rendition, err error) error {
+	// Files that cannot be decoded despite their extension are published without renditions.
+	if err != nil && !errors.Is(err, rendition.ErrUnsupported) {
+		return err
+	}
+	for _, rd := range renditions {
+		if err := r.uploadBlob(rd.ContentHash, rd.Data); err != nil {
+			return err
+		}
+		m.Renditions = append(m.Renditions, ingest.Rendition{
+			ContentHash: rd.ContentHash, Width: rd.Width, Height: rd.Height, MaxDim: rd.MaxDim,
+		})
+	}
+	return r.c.PutMedia(filepath.ToSlash(item.Album), item.Name, m)
+}
+
+// uploadBlob uploads a rendition unless the server already has it. Large
+// renditions are uploaded in chunks.
+func (r *ServerRemote) uploadBlob(hash string, data []byte) error {
+	if len(data) > ingest.ChunkSize {
+		return r.c.Upload(hash, bytes.NewReader(data), int64(len(data)))
+	}
+	ok, err := r.c.HasBlob(hash)
+	if err != nil || ok {
+		return err
+	}
+	return r.c.PutBlob(hash, data)
+}
+
+// uploadFile uploads the source file of an item in chunks, resuming any
+// interrupted upload of the same file (e.g., from a previous sync).
+func (r *ServerRemote) uploadFile(item Item, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.c.Upload(item.Hash, f, item.Size)
+}
+
+// setExif copies the EXIF fields of a photo to m.
+func setExif(m *ingest.Media, e *exif.Exif) {
+	// The same time as metadata.CaptureTime, so that the server sorts media like SortMedia.
+	if t := e.CaptureTime(); !t.IsZero() {
+		t = t.UTC()
+		m.ExifTime = &t
+	}
+	m.Latitude, m.Longitude = e.Latitude, e.Longitude
+	m.Camera, m.Lens = e.Camera(), e.LensModel
+	m.FocalLength, m.ExposureTime, m.Aperture = e.FocalLength, e.ExposureTime, e.FNumber
+	m.ISO, m.Flash = e.ISO, e.Flash
+	m.Portrait = e.Portrait()
+}
+
+func (r *ServerRemote) Delete(item Item) error {
+	return r.c.DeleteMedia(item.Album, item.Name)
+}
+
+// Flush creates or updates all enabled albums, so that album metadata changes are
+// published even if no media changed, and title and highlight photos uploaded in
+// this sync are resolved. It then deletes the albums of the server that are
+// disabled or missing in the collection.
+func (r *ServerRemote) Flush() error {
+	enabled := map[string]bool{}
+	for _, md := range r.albums {
+		if !md.Enabled {
+			continue
+		}
+		if err := r.putAlbum(md); err != nil {
+			return err
+		}
+		enabled[filepath.ToSlash(md.Path)] = true
+	}
+	paths, err := r.c.Albums()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if enabled[p] {
+			continue
+		}
+		if err := r.c.DeleteAlbum(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
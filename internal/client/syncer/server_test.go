@@ -0,0 +1,251 @@
+package syncer
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+	"github.com/maxpoletto/lbx/internal/exif"
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/server/api"
+	"github.com/maxpoletto/lbx/internal/server/db"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// newServer starts an lbxd API server over a temporary database and storage, and
+// returns its repository and an ingest client authenticated as admin.
+func newServer(t *testing.T) (*repo.Repo, *ingest.Client) {
+	t.Helper()
+	dir := t.TempDir()
+	conn, err := db.Open(filepath.Join(dir, "lbx.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, _, err := db.Migrate(conn); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	store, err := storage.NewLocal(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	r := repo.New(conn)
+	hs := httptest.NewServer(api.New(r, store))
+	t.Cleanup(hs.Close)
+	token, err := r.CreateAdminToken("test")
+	if err != nil {
+		t.Fatalf("CreateAdminToken() error = %v", err)
+	}
+	c, err := ingest.NewClient(hs.URL, token, hs.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return r, c
+}
+
+// writeJPEG writes a w x h JPEG image.
+func writeJPEG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	writeFile(t, path, buf.String())
+}
+
+func TestServerRemote(t *testing.T) {
+	root := initTree(t)
+	writeJPEG(t, filepath.Join(root, "paris/a.jpg"), 400, 300)
+	writeFile(t, filepath.Join(root, "paris/metadata.json"), `{
+		"enabled": true,
+		"title": "Paris",
+		"blurb": "Spring 2019",
+		"translations": {"fr": {"title": "Paris", "blurb": "Printemps 2019"}},
+		"title_photo": "a.jpg",
+		"aliases": ["paris-2019"],
+		"tags": ["travel", "a.jpg:tower"],
+		"access": ["family", "parents", "b.jpg:parents"],
+		"titles": ["a.jpg:The tower", "a.jpg:fr:La tour"],
+		"filter": ["exclude:.*\\.png", "include:.*"]
+	}`)
+	// XMP titles and captions fill in what metadata.json leaves unset.
+	writeFile(t, filepath.Join(root, "paris/a.xmp"), `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Eiffel Tower</rdf:li></rdf:Alt></dc:title>
+			<dc:description><rdf:Alt><rdf:li xml:lang="x-default">From Trocadéro</rdf:li></rdf:Alt></dc:description>
+			<dc:subject><rdf:Bag><rdf:li>night</rdf:li></rdf:Bag></dc:subject>
+		</rdf:Description>
+	</rdf:RDF>`)
+	writeFile(t, filepath.Join(root, "rome/v.mp4"), "video")
+	// The server cannot render HEIC photos.
+	writeFile(t, filepath.Join(root, "rome/f.heic"), "heic")
+	rp, c := newServer(t)
+	newRemote := func() *ServerRemote {
+		albums, err := metadata.ReadMetadata(root)
+		if err != nil {
+			t.Fatalf("ReadMetadata() error = %v", err)
+		}
+		return NewServerRemote(c, albums, 300, "en")
+	}
+
+	want := []string{"add paris/a.jpg", "add paris/b.jpg", "add rome/d.jpg", "add rome/v.mp4"}
+	if got := sync(t, root, newRemote(), false); !reflect.DeepEqual(got, want) {
+		t.Errorf("first sync = %v, want %v", got, want)
+	}
+	if got := sync(t, root, newRemote(), false); len(got) != 0 {
+		t.Errorf("second sync = %v, want no changes", got)
+	}
+	albums, err := metadata.ReadMetadata(root)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	plan, err := MakePlan(root, albums, newRemote(), nil)
+	if err != nil {
+		t.Fatalf("MakePlan() error = %v", err)
+	}
+	if want := []string{"rome/f.heic"}; !reflect.DeepEqual(plan.Skipped, want) {
+		t.Errorf("MakePlan().Skipped = %v, want %v", plan.Skipped, want)
+	}
+
+	paris, err := rp.AlbumByPath("paris")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	a, err := rp.MediaBySource(paris.ID, "a.jpg")
+	if err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if paris.TitlePhotoID != a.ID || paris.Language != "en" || !reflect.DeepEqual(paris.Texts, []repo.AlbumText{{Title: "Paris", Blurb: "Spring 2019"}, {Language: "fr", Title: "Paris", Blurb: "Printemps 2019"}}) ||
+		!reflect.DeepEqual(paris.Aliases, []string{"paris-2019"}) || !reflect.DeepEqual(paris.Tags, []string{"travel"}) ||
+		!reflect.DeepEqual(paris.Access, []string{"family", "parents"}) {
+		t.Errorf("AlbumByPath() = %+v", paris)
+	}
+	if a.DisplayName != "a" || a.Type != repo.Photo || !reflect.DeepEqual(a.Tags, []string{"tower"}) || len(a.Access) != 0 {
+		t.Errorf("MediaBySource(a.jpg) = %+v", a)
+	}
+	if want := []repo.MediaText{{Title: "The tower", Caption: "From Trocadéro"}, {Language: "fr", Title: "La tour"}}; !reflect.DeepEqual(a.Texts, want) {
+		t.Errorf("MediaBySource(a.jpg).Texts = %+v, want %+v", a.Texts, want)
+	}
+	// The photo is smaller than the default sizes but larger than the maximum size.
+	blobs, err := rp.Blobs(a.ID)
+	if err != nil {
+		t.Fatalf("Blobs() error = %v", err)
+	}
+	dims := [][2]int{}
+	for _, b := range blobs {
+		dims = append(dims, [2]int{b.Width, b.Height})
+	}
+	if want := [][2]int{{256, 192}, {300, 225}}; !reflect.DeepEqual(dims, want) {
+		t.Errorf("rendition sizes = %v, want %v", dims, want)
+	}
+	// b.jpg cannot be decoded, so it has no renditions.
+	b, err := rp.MediaBySource(paris.ID, "b.jpg")
+	if err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if !reflect.DeepEqual(b.Access, []string{"parents"}) {
+		t.Errorf("MediaBySource(b.jpg).Access = %v, want [parents]", b.Access)
+	}
+	if blobs, _ := rp.Blobs(b.ID); len(blobs) != 0 {
+		t.Errorf("Blobs(b.jpg) = %+v, want none", blobs)
+	}
+
+	// Videos are uploaded as is.
+	rome, err := rp.AlbumByPath("rome")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	v, err := rp.MediaBySource(rome.ID, "v.mp4")
+	if err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if blobs, err := rp.Blobs(v.ID); v.Type != repo.Video || err != nil || len(blobs) != 1 || blobs[0].ContentHash != v.SourceHash {
+		t.Errorf("MediaBySource(v.mp4) = %+v with blobs %+v, %v", v, blobs, err)
+	}
+
+	// Metadata changes are published even if the photos did not change.
+	writeFile(t, filepath.Join(root, "paris/metadata.json"), `{
+		"enabled": true,
+		"title": "Paris",
+		"title_photo": "a.jpg",
+		"tags": ["travel", "a.jpg:tower"],
+		"access": ["family", "parents", "a.jpg:parents"],
+		"titles": ["a.jpg:The tower"],
+		"captions": ["a.jpg:By night"],
+		"filter": ["exclude:.*\\.png", "include:.*"]
+	}`)
+	want = []string{"update paris/a.jpg", "update paris/b.jpg"}
+	if got := sync(t, root, newRemote(), false); !reflect.DeepEqual(got, want) {
+		t.Errorf("sync after metadata change = %v, want %v", got, want)
+	}
+	if a, err = rp.MediaBySource(paris.ID, "a.jpg"); err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if want := []repo.MediaText{{Title: "The tower", Caption: "By night"}}; !reflect.DeepEqual(a.Texts, want) {
+		t.Errorf("MediaBySource(a.jpg).Texts = %+v, want %+v", a.Texts, want)
+	}
+	if !reflect.DeepEqual(a.Access, []string{"parents"}) {
+		t.Errorf("MediaBySource(a.jpg).Access = %v, want [parents]", a.Access)
+	}
+	if b, err = rp.MediaBySource(paris.ID, "b.jpg"); err != nil || len(b.Access) != 0 {
+		t.Errorf("MediaBySource(b.jpg) = %+v, %v, want no access keys", b, err)
+	}
+
+	// So are changes of XMP sidecars.
+	writeFile(t, filepath.Join(root, "paris/a.xmp"), `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<dc:description><rdf:Alt><rdf:li xml:lang="fr">De nuit</rdf:li></rdf:Alt></dc:description>
+		</rdf:Description>
+	</rdf:RDF>`)
+	want = []string{"update paris/a.jpg"}
+	if got := sync(t, root, newRemote(), false); !reflect.DeepEqual(got, want) {
+		t.Errorf("sync after sidecar change = %v, want %v", got, want)
+	}
+	if a, err = rp.MediaBySource(paris.ID, "a.jpg"); err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if want := []repo.MediaText{{Title: "The tower", Caption: "By night"}, {Language: "fr", Caption: "De nuit"}}; !reflect.DeepEqual(a.Texts, want) {
+		t.Errorf("MediaBySource(a.jpg).Texts = %+v, want %+v", a.Texts, want)
+	}
+
+	// Albums disabled or missing in the collection are deleted.
+	if err := c.PutAlbum("london", &ingest.Album{}); err != nil {
+		t.Fatalf("PutAlbum() error = %v", err)
+	}
+	os.Remove(filepath.Join(root, "paris/b.jpg"))
+	writeFile(t, filepath.Join(root, "rome/metadata.json"), `{"enabled": false, "title": "Rome"}`)
+	want = []string{"remove paris/b.jpg", "remove rome/d.jpg", "remove rome/v.mp4"}
+	if got := sync(t, root, newRemote(), false); !reflect.DeepEqual(got, want) {
+		t.Errorf("third sync = %v, want %v", got, want)
+	}
+	if _, err := rp.MediaBySource(paris.ID, "b.jpg"); err == nil {
+		t.Errorf("MediaBySource(b.jpg) after removal error = nil, want error")
+	}
+	if paths, err := rp.AlbumPaths(); err != nil || !reflect.DeepEqual(paths, []string{"paris"}) {
+		t.Errorf("AlbumPaths() = %v, %v, want [paris]", paths, err)
+	}
+}
+
+func TestSetExif(t *testing.T) {
+	// Photos without DateTimeOriginal are sorted by DateTimeDigitized, like
+	// metadata.SortMedia does.
+	digitized := time.Date(1998, 5, 30, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*3600))
+	m := &ingest.Media{}
+	setExif(m, &exif.Exif{DateTimeDigitized: digitized})
+	if m.ExifTime == nil || !m.ExifTime.Equal(digitized) || m.ExifTime.Location() != time.UTC {
+		t.Errorf("setExif() ExifTime = %v, want %v in UTC", m.ExifTime, digitized)
+	}
+	m = &ingest.Media{}
+	setExif(m, &exif.Exif{})
+	if m.ExifTime != nil {
+		t.Errorf("setExif() ExifTime = %v, want nil", m.ExifTime)
+	}
+}
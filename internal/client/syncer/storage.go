@@ -0,0 +1,111 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+const (
+	// mediaPrefix prefixes the keys of media objects.
+	mediaPrefix = "media/"
+	// indexKey is the key of the index of published items.
+	indexKey = "lbx/index.json"
+)
+
+// StorageRemote publishes media directly to a storage backend. Media are stored
+// under "media/ALBUM/FILENAME", and the list of published items with their hashes
+// is kept in an index object, so that comparing against the remote does not
+// require reading media back.
+type StorageRemote struct {
+	s     storage.Storage
+	index map[string]Item
+	dirty bool
+}
+
+// NewStorageRemote returns a remote backed by s, loading its index.
+func NewStorageRemote(s storage.Storage) (*StorageRemote, error) {
+	r := &StorageRemote{s: s, index: map[string]Item{}}
+	rc, err := s.Get(indexKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	items := []Item{}
+	if err := json.NewDecoder(rc).Decode(&items); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %v", indexKey, err)
+	}
+	for _, it := range items {
+		r.index[it.Key()] = it
+	}
+	return r, nil
+}
+
+func (r *StorageRemote) List() ([]Item, error) {
+	items := make([]Item, 0, len(r.index))
+	for _, it := range r.index {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key() < items[j].Key()
+	})
+	return items, nil
+}
+
+// Digest returns "": files are published as is.
+func (r *StorageRemote) Digest(md *metadata.AlbumMetadata, name, path string) (string, error) {
+	return "", nil
+}
+
+// Accepts returns true: files are published as is, whatever their format.
+func (r *StorageRemote) Accepts(name string) bool {
+	return true
+}
+
+func (r *StorageRemote) Upload(item Item, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := r.s.Put(mediaPrefix+item.Key(), f, item.Size); err != nil {
+		return err
+	}
+	r.index[item.Key()] = item
+	r.dirty = true
+	return nil
+}
+
+func (r *StorageRemote) Delete(item Item) error {
+	if err := r.s.Delete(mediaPrefix + item.Key()); err != nil {
+		return err
+	}
+	delete(r.index, item.Key())
+	r.dirty = true
+	return nil
+}
+
+// Flush writes the index if it changed.
+func (r *StorageRemote) Flush() error {
+	if !r.dirty {
+		return nil
+	}
+	items, _ := r.List()
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := r.s.Put(indexKey, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
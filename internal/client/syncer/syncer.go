@@ -0,0 +1,221 @@
+// Package syncer publishes the media of an LBX collection, uploading only new or
+// changed files and removing files that are no longer selected.
+package syncer
+
+import (
+	"fmt"
+	"sort"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+)
+
+// Item is a published media file.
+type Item struct {
+	// Album is the album path, relative to the collection root.
+	Album string `json:"album"`
+	// Name is the filename of the media file.
+	Name string `json:"name"`
+	// Hash is the hex SHA-256 hash of the file contents.
+	Hash string `json:"hash"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// Digest is the digest of the metadata published with the file (see
+	// Remote.Digest), or empty if the remote publishes files as is.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Key returns the path of the item relative to the collection root.
+func (it Item) Key() string {
+	return it.Album + "/" + it.Name
+}
+
+// Remote is the destination of a sync.
+type Remote interface {
+	// List returns all published items.
+	List() ([]Item, error)
+	// Accepts reports whether the remote can publish the media file name.
+	Accepts(name string) bool
+	// Digest returns a digest of the metadata that Upload publishes with the media
+	// file name of album md, at path, so that items whose metadata changed are
+	// published again. Remotes that publish files as is return "".
+	Digest(md *metadata.AlbumMetadata, name, path string) (string, error)
+	// Upload publishes the file at path as item, replacing any item with the same key.
+	Upload(item Item, path string) error
+	// Delete unpublishes an item.
+	Delete(item Item) error
+	// Flush persists any state buffered by Upload and Delete.
+	Flush() error
+}
+
+// Action is the kind of a change.
+type Action int
+
+const (
+	Add Action = iota
+	Update
+	Remove
+)
+
+func (a Action) String() string {
+	switch a {
+	case Add:
+		return "add"
+	case Update:
+		return "update"
+	case Remove:
+		return "remove"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Change is a single step of a sync.
+type Change struct {
+	Action Action
+	// Item is the item to add or update (with its new hash), or the item to remove.
+	Item Item
+	// Path is the local file to upload. Empty for Remove.
+	Path string
+}
+
+// Plan is the list of changes required to bring a remote up to date.
+type Plan struct {
+	// Changes is the list of changes: additions and updates first, then removals,
+	// each sorted by key.
+	Changes []Change
+	// Unchanged is the number of items that are already up to date.
+	Unchanged int
+	// Skipped lists the keys of the selected media files that the remote does
+	// not accept (see Remote.Accepts), sorted. They are not published.
+	Skipped []string
+}
+
+// Counts returns the number of additions, updates and removals in the plan.
+func (p *Plan) Counts() (added, updated, removed int) {
+	for _, c := range p.Changes {
+		switch c.Action {
+		case Add:
+			added++
+		case Update:
+			updated++
+		case Remove:
+			removed++
+		}
+	}
+	return
+}
+
+// MakePlan compares the media selected in albums (as returned by metadata.ReadMetadata
+// for the collection at root) against the items published to r. Media of disabled
+// albums and files that r does not accept are not published. Items are updated if
+// their contents or their metadata changed. Hashes are computed with hc, which may
+// be nil.
+func MakePlan(root string, albums []*metadata.AlbumMetadata, r Remote, hc *HashCache) (*Plan, error) {
+	published, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	remote := make(map[string]Item, len(published))
+	for _, it := range published {
+		remote[it.Key()] = it
+	}
+	plan := &Plan{}
+	for _, md := range albums {
+		if !md.Enabled {
+			continue
+		}
+		sel, err := metadata.SelectMedia(root, md)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range sel.Selected {
+			if !r.Accepts(f.Name) {
+				plan.Skipped = append(plan.Skipped, Item{Album: md.Path, Name: f.Name}.Key())
+				continue
+			}
+			hash, err := hc.Hash(f)
+			if err != nil {
+				return nil, err
+			}
+			digest, err := r.Digest(md, f.Name, f.Path)
+			if err != nil {
+				return nil, err
+			}
+			it := Item{Album: md.Path, Name: f.Name, Hash: hash, Size: f.Size, Digest: digest}
+			old, ok := remote[it.Key()]
+			delete(remote, it.Key())
+			switch {
+			case !ok:
+				plan.Changes = append(plan.Changes, Change{Action: Add, Item: it, Path: f.Path})
+			case old.Hash != it.Hash || old.Digest != it.Digest:
+				plan.Changes = append(plan.Changes, Change{Action: Update, Item: it, Path: f.Path})
+			default:
+				plan.Unchanged++
+			}
+		}
+	}
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Item.Key() < plan.Changes[j].Item.Key()
+	})
+	sort.Strings(plan.Skipped)
+	// Whatever is left was deleted, filtered out, or disabled.
+	removed := make([]Change, 0, len(remote))
+	for _, it := range remote {
+		removed = append(removed, Change{Action: Remove, Item: it})
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].Item.Key() < removed[j].Item.Key()
+	})
+	plan.Changes = append(plan.Changes, removed...)
+	return plan, nil
+}
+
+// batchUploader is implemented by remotes that upload many items faster
+// together than one at a time.
+type batchUploader interface {
+	// uploadAll uploads the items of changes (additions and updates) like
+	// Remote.Upload, in any order, calling progress (if not nil) before each.
+	// Returns the first error, as returned by changeError.
+	uploadAll(changes []Change, progress func(Change)) error
+}
+
+// changeError returns the error of a failed change.
+func changeError(c Change, err error) error {
+	return fmt.Errorf("failed to %s %s: %v", c.Action, c.Item.Key(), err)
+}
+
+// Apply executes a plan against a remote, calling progress (if not nil) before each change.
+// The remote is flushed even if a change fails, so that completed changes are not lost.
+func Apply(r Remote, plan *Plan, progress func(Change)) error {
+	changes := plan.Changes
+	var err error
+	if b, ok := r.(batchUploader); ok {
+		// Additions and updates come first.
+		n := 0
+		for n < len(changes) && changes[n].Action != Remove {
+			n++
+		}
+		err = b.uploadAll(changes[:n], progress)
+		changes = changes[n:]
+	}
+	for _, c := range changes {
+		if err != nil {
+			break
+		}
+		if progress != nil {
+			progress(c)
+		}
+		switch c.Action {
+		case Add, Update:
+			err = r.Upload(c.Item, c.Path)
+		case Remove:
+			err = r.Delete(c.Item)
+		}
+		if err != nil {
+			err = changeError(c, err)
+		}
+	}
+	if ferr := r.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
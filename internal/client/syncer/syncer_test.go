@@ -0,0 +1,138 @@
+package syncer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metadata "github.com/maxpoletto/lbx/internal/client"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// initTree creates a collection with two enabled albums and one disabled album.
+func initTree(t *testing.T) string {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "metadata.json"), `{
+		"version": "1",
+		"enabled": true,
+		"name": "Test Collection",
+		"url": "https://example.com",
+		"s3_access_code": "access",
+		"s3_secret_key": "secret"
+	}`)
+	writeFile(t, filepath.Join(root, "paris/metadata.json"), `{
+		"enabled": true,
+		"title": "Paris",
+		"filter": ["exclude:.*\\.png", "include:.*"]
+	}`)
+	writeFile(t, filepath.Join(root, "paris/a.jpg"), "a")
+	writeFile(t, filepath.Join(root, "paris/b.jpg"), "b")
+	writeFile(t, filepath.Join(root, "paris/c.png"), "c")
+	writeFile(t, filepath.Join(root, "rome/metadata.json"), `{"enabled": true, "title": "Rome"}`)
+	writeFile(t, filepath.Join(root, "rome/d.jpg"), "d")
+	writeFile(t, filepath.Join(root, "private/metadata.json"), `{"enabled": false, "title": "Private"}`)
+	writeFile(t, filepath.Join(root, "private/e.jpg"), "e")
+	return root
+}
+
+// sync runs a sync of root to r, returning the list of "action key" strings.
+func sync(t *testing.T, root string, r Remote, dryRun bool) []string {
+	t.Helper()
+	albums, err := metadata.ReadMetadata(root)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	plan, err := MakePlan(root, albums, r, nil)
+	if err != nil {
+		t.Fatalf("MakePlan() error = %v", err)
+	}
+	changes := []string{}
+	for _, c := range plan.Changes {
+		changes = append(changes, c.Action.String()+" "+c.Item.Key())
+	}
+	if !dryRun {
+		if err := Apply(r, plan, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	return changes
+}
+
+func TestSync(t *testing.T) {
+	root := initTree(t)
+	s, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	r, err := NewStorageRemote(s)
+	if err != nil {
+		t.Fatalf("NewStorageRemote() error = %v", err)
+	}
+
+	// A dry run changes nothing.
+	want := []string{"add paris/a.jpg", "add paris/b.jpg", "add rome/d.jpg"}
+	if got := sync(t, root, r, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("dry run = %v, want %v", got, want)
+	}
+	if got := sync(t, root, r, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("first sync = %v, want %v", got, want)
+	}
+	if got := sync(t, root, r, false); len(got) != 0 {
+		t.Errorf("second sync = %v, want no changes", got)
+	}
+
+	// Modify, delete, filter out and add files; disable an album.
+	writeFile(t, filepath.Join(root, "paris/a.jpg"), "a2")
+	os.Remove(filepath.Join(root, "paris/b.jpg"))
+	writeFile(t, filepath.Join(root, "paris/f.jpg"), "f")
+	writeFile(t, filepath.Join(root, "rome/metadata.json"), `{"enabled": false, "title": "Rome"}`)
+
+	// Reload the remote from storage to check that the index was persisted.
+	r, err = NewStorageRemote(s)
+	if err != nil {
+		t.Fatalf("NewStorageRemote() error = %v", err)
+	}
+	want = []string{"update paris/a.jpg", "add paris/f.jpg", "remove paris/b.jpg", "remove rome/d.jpg"}
+	if got := sync(t, root, r, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("third sync = %v, want %v", got, want)
+	}
+
+	objects, err := s.List(mediaPrefix)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	keys := []string{}
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+	if want := []string{"media/paris/a.jpg", "media/paris/f.jpg"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("stored objects = %v, want %v", keys, want)
+	}
+	rc, err := s.Get("media/paris/a.jpg")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "a2" {
+		t.Errorf("media/paris/a.jpg = %q, want %q", data, "a2")
+	}
+}
+
+func TestPlanCounts(t *testing.T) {
+	p := &Plan{Changes: []Change{{Action: Add}, {Action: Add}, {Action: Update}, {Action: Remove}}}
+	if a, u, r := p.Counts(); a != 2 || u != 1 || r != 1 {
+		t.Errorf("Counts() = %d, %d, %d, want 2, 1, 1", a, u, r)
+	}
+}
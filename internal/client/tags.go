@@ -0,0 +1,31 @@
+package metadata
+
+import "strings"
+
+// AlbumTags returns the tags that apply to the whole album: the entries of Tags
+// that are not per-photo entries "FILENAME:TAG".
+func (m *AlbumMetadata) AlbumTags() []string {
+	tags := []string{}
+	for _, t := range m.Tags {
+		if !strings.Contains(t, ":") {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// MediaTags returns the tags of the photo with the given filename: the tags of
+// all per-photo entries "FILENAME:TAG" naming it and of its sidecar, if any. TAG
+// follows the last colon, so FILENAME may itself contain colons.
+func (m *AlbumMetadata) MediaTags(name string) []string {
+	tags := []string{}
+	for _, t := range m.Tags {
+		if i := strings.LastIndex(t, ":"); i >= 0 && t[:i] == name {
+			tags = append(tags, t[i+1:])
+		}
+	}
+	if sc := m.sidecars[name]; sc != nil {
+		tags = append(tags, sc.Tags...)
+	}
+	return mergeLists(tags, nil)
+}
@@ -0,0 +1,29 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMediaTags(t *testing.T) {
+	am := &AlbumMetadata{CommonMetadata: CommonMetadata{
+		Tags: []string{"travel", "IMG_1.jpg:tower", "IMG_1.jpg:night", "IMG_2.jpg:river", "a:b.jpg:odd"},
+	}}
+	if got := am.AlbumTags(); !reflect.DeepEqual(got, []string{"travel"}) {
+		t.Errorf("AlbumTags() = %v, want [travel]", got)
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"IMG_1.jpg", []string{"night", "tower"}},
+		{"IMG_2.jpg", []string{"river"}},
+		{"IMG_3.jpg", []string{}},
+		{"a:b.jpg", []string{"odd"}},
+	}
+	for _, tt := range tests {
+		if got := am.MediaTags(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MediaTags(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MediaText is the title and caption of a photo in one language (a row of media_text).
+type MediaText struct {
+	// Language is a canonical BCP 47 language code (see CanonicalLanguage), or ""
+	// for the default language of the collection.
+	Language string
+	Title    string
+	Caption  string
+}
+
+// AlbumText is the title and blurb of an album in one language (a row of album_text).
+type AlbumText struct {
+	// Language is a canonical BCP 47 language code (see CanonicalLanguage), or ""
+	// for the default language of the collection.
+	Language string
+	Title    string
+	Blurb    string
+}
+
+// AlbumTexts returns the title and blurb of an album in the default language,
+// followed by its translations sorted by language. Translations must have a title
+// and distinct language codes; otherwise, the error is an Errors listing every
+// invalid translation.
+func (m *AlbumMetadata) AlbumTexts() ([]AlbumText, error) {
+	texts := []AlbumText{}
+	if m.Title != "" || m.Blurb != "" {
+		texts = append(texts, AlbumText{Title: m.Title, Blurb: m.Blurb})
+	}
+	var errs Errors
+	seen := map[string]string{}
+	for _, lang := range sortedKeys(m.Translations) {
+		t := m.Translations[lang]
+		field := "translations." + lang
+		canonical, err := CanonicalLanguage(lang)
+		if err != nil {
+			errs = append(errs, fieldErrorf(field, "%v", err))
+			continue
+		}
+		if other, ok := seen[canonical]; ok {
+			errs = append(errs, fieldErrorf(field, "%q and %q are the same language", other, lang))
+			continue
+		}
+		seen[canonical] = lang
+		if strings.TrimSpace(t.Title) == "" {
+			errs = append(errs, fieldErrorf(field, "missing title for %s", lang))
+			continue
+		}
+		texts = append(texts, AlbumText{Language: canonical, Title: t.Title, Blurb: t.Blurb})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	sort.Slice(texts, func(i, j int) bool { return texts[i].Language < texts[j].Language })
+	return texts, nil
+}
+
+// translationLanguages returns the canonical codes of the valid languages of the
+// album's translations, which are the languages that photo texts may be in.
+func (m *AlbumMetadata) translationLanguages() map[string]bool {
+	languages := map[string]bool{}
+	for lang := range m.Translations {
+		if canonical, err := CanonicalLanguage(lang); err == nil {
+			languages[canonical] = true
+		}
+	}
+	return languages
+}
+
+// sortedKeys returns the keys of a map in increasing order, so that problems
+// with its entries are reported in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// textEntry is a parsed entry of AlbumMetadata.Titles or AlbumMetadata.Captions.
+type textEntry struct {
+	filename string
+	language string
+	text     string
+}
+
+// parseTextEntry parses an entry "FILENAME:[LANG:]TEXT" of an album whose
+// translations are in languages (canonical codes). FILENAME ends at the first
+// colon, so it cannot contain colons. What follows is LANG if it is one of
+// languages followed by a colon. A text in the default language that starts with
+// another well-formed language code and a colon is ambiguous and rejected: it
+// must be written with an empty LANG (e.g., "IMG_1.jpg::Re: the tower"). TEXT may
+// contain colons; surrounding whitespace is removed.
+func parseTextEntry(entry string, languages map[string]bool) (textEntry, error) {
+	filename, rest, ok := strings.Cut(entry, ":")
+	if !ok || filename == "" {
+		return textEntry{}, fmt.Errorf("invalid entry %q: want FILENAME:[LANG:]TEXT", entry)
+	}
+	e := textEntry{filename: filename, text: rest}
+	if lang, text, ok := strings.Cut(rest, ":"); ok {
+		if lang == "" {
+			e.text = text
+		} else if canonical, err := CanonicalLanguage(lang); err == nil {
+			if !languages[canonical] {
+				return textEntry{}, fmt.Errorf("ambiguous entry %q: %s is not a language of the album translations "+
+					"(for a text in the default language, write %s::%s)", entry, lang, filename, rest)
+			}
+			e.language, e.text = canonical, text
+		}
+	}
+	e.text = strings.TrimSpace(e.text)
+	if e.text == "" {
+		return textEntry{}, fmt.Errorf("invalid entry %q: empty text", entry)
+	}
+	return e, nil
+}
+
+// parseMediaTexts parses the Titles and Captions of an album whose translations
+// are in languages into the texts of each photo, sorted by language. There may be
+// at most one title and one caption per photo and language; otherwise, the error
+// is an Errors listing every invalid entry.
+func parseMediaTexts(titles, captions []string, languages map[string]bool) (map[string][]MediaText, error) {
+	type key struct{ filename, language string }
+	texts := map[key]*MediaText{}
+	var errs Errors
+	for _, field := range []struct {
+		name    string
+		entries []string
+	}{{"title", titles}, {"caption", captions}} {
+		for i, entry := range field.entries {
+			name := fmt.Sprintf("%ss[%d]", field.name, i)
+			e, err := parseTextEntry(entry, languages)
+			if err != nil {
+				errs = append(errs, fieldErrorf(name, "%v", err))
+				continue
+			}
+			k := key{e.filename, e.language}
+			t := texts[k]
+			if t == nil {
+				t = &MediaText{Language: e.language}
+				texts[k] = t
+			}
+			dst := &t.Title
+			if field.name == "caption" {
+				dst = &t.Caption
+			}
+			if *dst != "" {
+				lang := e.language
+				if lang == "" {
+					lang = "default language"
+				}
+				errs = append(errs, fieldErrorf(name, "duplicate %s for %s (%s)", field.name, e.filename, lang))
+				continue
+			}
+			*dst = e.text
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	byFile := map[string][]MediaText{}
+	for k, t := range texts {
+		byFile[k.filename] = append(byFile[k.filename], *t)
+	}
+	for _, l := range byFile {
+		sort.Slice(l, func(i, j int) bool { return l[i].Language < l[j].Language })
+	}
+	return byFile, nil
+}
+
+// MediaTexts returns the titles and captions of the photo with the given filename,
+// sorted by language, or an empty list if it has none: those from Titles and
+// Captions together with those from the sidecar of the photo, if any.
+func (m *AlbumMetadata) MediaTexts(name string) ([]MediaText, error) {
+	if m.mediaTexts == nil {
+		texts, err := parseMediaTexts(m.Titles, m.Captions, m.translationLanguages())
+		if err != nil {
+			return nil, err
+		}
+		m.mediaTexts = texts
+	}
+	if sc := m.sidecars[name]; sc != nil {
+		return mergeMediaTexts(m.mediaTexts[name], sc.texts), nil
+	}
+	if texts := m.mediaTexts[name]; texts != nil {
+		return texts, nil
+	}
+	return []MediaText{}, nil
+}
+
+// checkMediaTexts checks that the titles and captions of an album refer to files
+// in files, the set of filenames in the album directory.
+func (m *AlbumMetadata) checkMediaTexts(files map[string]bool) error {
+	if _, err := m.MediaTexts(""); err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range m.mediaTexts {
+		if !files[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("titles or captions for missing files: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTextEntry(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    textEntry
+		wantErr bool
+	}{
+		{"IMG_1.jpg:The tower", textEntry{"IMG_1.jpg", "", "The tower"}, false},
+		{"IMG_1.jpg:fr:La tour", textEntry{"IMG_1.jpg", "fr", "La tour"}, false},
+		{"IMG_1.jpg:pt-br: A torre ", textEntry{"IMG_1.jpg", "pt-BR", "A torre"}, false},
+		{"IMG_1.jpg:Paris: the tower", textEntry{"IMG_1.jpg", "", "Paris: the tower"}, false},
+		{"IMG_1.jpg:en:Paris: the tower", textEntry{"IMG_1.jpg", "en", "Paris: the tower"}, false},
+		{"IMG_1.jpg::Re: the tower", textEntry{"IMG_1.jpg", "", "Re: the tower"}, false},
+		{"IMG_1.jpg:10:30 at night", textEntry{"IMG_1.jpg", "", "10:30 at night"}, false},
+		{"IMG_1.jpg:Re: the tower", textEntry{}, true},
+		{"IMG_1.jpg:de:Der Turm", textEntry{}, true},
+		{"IMG_1.jpg", textEntry{}, true},
+		{":The tower", textEntry{}, true},
+		{"IMG_1.jpg:", textEntry{}, true},
+		{"IMG_1.jpg:en: ", textEntry{}, true},
+	}
+	languages := map[string]bool{"en": true, "fr": true, "pt-BR": true}
+	for _, tt := range tests {
+		got, err := parseTextEntry(tt.entry, languages)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTextEntry(%q) error = %v, wantErr %t", tt.entry, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("parseTextEntry(%q) = %+v, want %+v", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestMediaTexts(t *testing.T) {
+	am, err := ParseAlbumMetadata([]byte(`{
+		"title": "Paris",
+		"translations": {"fr": {"title": "Paris"}, "en-GB": {"title": "Paris"}, "de": {"title": "Paris"}},
+		"titles": ["a.jpg:The tower", "a.jpg:fr:La tour", "b.jpg:en-gb:The river"],
+		"captions": ["a.jpg:From Trocadéro", "c.jpg:de:Nachts"]
+	}`), true)
+	if err != nil {
+		t.Fatalf("ParseAlbumMetadata() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		want []MediaText
+	}{
+		{"a.jpg", []MediaText{{"", "The tower", "From Trocadéro"}, {"fr", "La tour", ""}}},
+		{"b.jpg", []MediaText{{"en-GB", "The river", ""}}},
+		{"c.jpg", []MediaText{{"de", "", "Nachts"}}},
+		{"d.jpg", []MediaText{}},
+	}
+	for _, tt := range tests {
+		got, err := am.MediaTexts(tt.name)
+		if err != nil {
+			t.Fatalf("MediaTexts(%s) error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MediaTexts(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if err := am.checkMediaTexts(map[string]bool{"a.jpg": true, "b.jpg": true}); err == nil || !strings.Contains(err.Error(), "c.jpg") {
+		t.Errorf("checkMediaTexts() error = %v, want error about c.jpg", err)
+	}
+	if err := am.checkMediaTexts(map[string]bool{"a.jpg": true, "b.jpg": true, "c.jpg": true}); err != nil {
+		t.Errorf("checkMediaTexts() error = %v", err)
+	}
+
+	for _, data := range []string{
+		`{"title": "T", "titles": ["a.jpg:One", "a.jpg:Two"]}`,
+		`{"title": "T", "translations": {"en": {"title": "T"}}, "titles": ["a.jpg:en:One", "a.jpg:EN:Two"]}`,
+		`{"title": "T", "translations": {"fr": {"title": "T"}}, "captions": ["a.jpg:fr:Un", "a.jpg:fr:Deux"]}`,
+		`{"title": "T", "titles": ["a.jpg:fr:Un"]}`,
+		`{"title": "T", "captions": ["a.jpg"]}`,
+	} {
+		if _, err := ParseAlbumMetadata([]byte(data), true); err == nil {
+			t.Errorf("ParseAlbumMetadata(%s) error = nil, want error", data)
+		}
+	}
+}
+
+func TestAlbumTexts(t *testing.T) {
+	am, err := ParseAlbumMetadata([]byte(`{
+		"title": "Paris",
+		"blurb": "Spring 2019",
+		"translations": {
+			"pt-br": {"title": "Paris", "blurb": "Primavera de 2019"},
+			"fr": {"title": "Paris", "blurb": "Printemps 2019"}
+		}
+	}`), true)
+	if err != nil {
+		t.Fatalf("ParseAlbumMetadata() error = %v", err)
+	}
+	got, err := am.AlbumTexts()
+	if err != nil {
+		t.Fatalf("AlbumTexts() error = %v", err)
+	}
+	want := []AlbumText{
+		{"", "Paris", "Spring 2019"},
+		{"fr", "Paris", "Printemps 2019"},
+		{"pt-BR", "Paris", "Primavera de 2019"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AlbumTexts() = %+v, want %+v", got, want)
+	}
+
+	for _, data := range []string{
+		`{"title": "T", "translations": {"french": {"title": "T"}}}`,
+		`{"title": "T", "translations": {"fr": {"blurb": "B"}}}`,
+		`{"title": "T", "translations": {"en-us": {"title": "T"}, "en-US": {"title": "T"}}}`,
+	} {
+		if _, err := ParseAlbumMetadata([]byte(data), true); err == nil {
+			t.Errorf("ParseAlbumMetadata(%s) error = nil, want error", data)
+		}
+	}
+	for _, data := range []string{`{"blurb": "B"}`, `{"translations": {"fr": {"title": "T"}}}`} {
+		if _, err := ParseAlbumMetadata([]byte(data), false); err == nil {
+			t.Errorf("ParseAlbumMetadata(%s) for non-album error = nil, want error", data)
+		}
+	}
+}
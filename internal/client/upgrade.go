@@ -0,0 +1,135 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileUpgrade is the upgrade of a metadata file to the current version.
+type FileUpgrade struct {
+	// Path is the path of the metadata file.
+	Path string
+	// Collection is true for the metadata file of the collection root.
+	Collection bool
+	// Old and New are the contents of the file before and after the upgrade.
+	Old, New []byte
+}
+
+// upgradeSteps[i] upgrades a metadata file from knownVersions[i] to knownVersions[i+1].
+// collection is true for the metadata file of the collection root. Steps edit the
+// JSON text in place, so that the order of fields and formatting are preserved.
+var upgradeSteps = []func(data []byte, collection bool) ([]byte, error){
+	upgradeTo2,
+}
+
+// upgradeTo2 adds the version to album and intermediate directory metadata files.
+func upgradeTo2(data []byte, collection bool) ([]byte, error) {
+	return setField(data, "version", `"2"`)
+}
+
+// Upgrade computes the upgrade of the metadata files of the collection rooted at
+// root from its version to CurrentVersion, without writing them. Returns the
+// version of the collection and the files that change, sorted by path, with the
+// collection metadata file last. There are none if the collection is current.
+func Upgrade(root string) (string, []FileUpgrade, error) {
+	// Only the version of the collection is read: the rest of the metadata may
+	// only be valid once upgraded.
+	rootFile := filepath.Join(root, "metadata.json")
+	data, err := os.ReadFile(rootFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read metadata file: %v", err)
+	}
+	var cm struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &cm); err != nil {
+		return "", nil, fmt.Errorf("%s: %v", rootFile, err)
+	}
+	if err := checkVersion(cm.Version); err != nil {
+		return "", nil, fmt.Errorf("%s: %v", rootFile, err)
+	}
+	from := versionIndex(cm.Version)
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == "metadata.json" && path != rootFile {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(paths)
+	paths = append(paths, rootFile)
+	files := []FileUpgrade{}
+	for _, path := range paths {
+		old, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		f := FileUpgrade{Path: path, Collection: path == rootFile, Old: old, New: old}
+		for _, step := range upgradeSteps[from:] {
+			if f.New, err = step(f.New, f.Collection); err != nil {
+				return "", nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		if !bytes.Equal(f.Old, f.New) {
+			files = append(files, f)
+		}
+	}
+	return cm.Version, files, nil
+}
+
+// setField sets the value of the top-level field key of a JSON object to the JSON
+// value. An existing value is replaced; otherwise the field is inserted first,
+// with the same indentation as the fields that follow.
+func setField(data []byte, key, value string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("metadata is not a JSON object")
+	}
+	open := int(dec.InputOffset())
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		// The value starts after the colon that follows the key.
+		start := int(dec.InputOffset())
+		start += bytes.IndexByte(data[start:], ':') + 1
+		start += len(data[start:]) - len(bytes.TrimLeft(data[start:], " \t\r\n"))
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if tok == key {
+			end := start + len(v)
+			return append(append(append([]byte{}, data[:start]...), value...), data[end:]...), nil
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	rest := data[open:]
+	first := open + len(rest) - len(bytes.TrimLeft(rest, " \t\r\n"))
+	field := fmt.Sprintf("%q: %s", key, value)
+	if data[first] == '}' {
+		// Empty object.
+		return append(append(append([]byte{}, data[:open]...), field...), data[open:]...), nil
+	}
+	sep := data[open:first]
+	if len(sep) == 0 {
+		sep = []byte(" ")
+	}
+	out := append([]byte{}, data[:first]...)
+	out = append(append(append(out, field...), ','), sep...)
+	return append(out, data[first:]...), nil
+}
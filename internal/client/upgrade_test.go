@@ -0,0 +1,86 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetField(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{`{"version": "1", "name": "C"}`, `{"version": "2", "name": "C"}`},
+		{`{"name": "C", "version":"1"}`, `{"name": "C", "version":"2"}`},
+		{"{\n\t\"title\": \"T\",\n\t\"tags\": {\"version\": 1}\n}", "{\n\t\"version\": \"2\",\n\t\"title\": \"T\",\n\t\"tags\": {\"version\": 1}\n}"},
+		{`{"title":"T"}`, `{"version": "2", "title":"T"}`},
+		{`{}`, `{"version": "2"}`},
+		{"{ }\n", "{\"version\": \"2\" }\n"},
+	}
+	for _, tt := range tests {
+		got, err := setField([]byte(tt.data), "version", `"2"`)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("setField(%q) = %q, %v, want %q", tt.data, got, err, tt.want)
+		}
+	}
+	for _, data := range []string{``, `[]`, `{"a": }`, `{"a": 1`} {
+		if _, err := setField([]byte(data), "version", `"2"`); err == nil {
+			t.Errorf("setField(%q) error = nil, want error", data)
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	rootDir := t.TempDir()
+	initCollection(t, rootDir)
+	initAlbum(t, rootDir, "europe", `{"tags": ["travel"]}`)
+	initAlbum(t, rootDir, "europe/paris", "{\n  \"title\": \"Paris\",\n  \"enabled\": true\n}\n")
+	initAlbum(t, rootDir, "rome", `{"version": "1", "title": "Rome"}`)
+
+	// Version 2 requires versions in all metadata files.
+	collection := filepath.Join(rootDir, "metadata.json")
+	v1, err := os.ReadFile(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := setField(v1, "version", `"2"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, rootDir, "metadata.json", string(v2))
+	if _, err := ReadMetadata(rootDir); err == nil {
+		t.Fatalf("ReadMetadata() of version 2 collection without album versions error = nil, want error")
+	}
+	createFile(t, rootDir, "metadata.json", string(v1))
+
+	from, files, err := Upgrade(rootDir)
+	if err != nil {
+		t.Fatalf("Upgrade() error = %v", err)
+	}
+	if from != "1" || len(files) != 4 || !files[3].Collection || files[3].Path != collection {
+		t.Fatalf("Upgrade() = %q, %+v", from, files)
+	}
+	if got, want := string(files[1].New), "{\n  \"version\": \"2\",\n  \"title\": \"Paris\",\n  \"enabled\": true\n}\n"; got != want {
+		t.Errorf("Upgrade() europe/paris = %q, want %q", got, want)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.Path, f.New, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mdList, err := ReadMetadata(rootDir)
+	if err != nil {
+		t.Fatalf("ReadMetadata() after upgrade error = %v", err)
+	}
+	if len(mdList) != 2 || mdList[0].Version != CurrentVersion {
+		t.Errorf("ReadMetadata() after upgrade = %+v", mdList)
+	}
+	if from, files, err := Upgrade(rootDir); err != nil || from != CurrentVersion || len(files) != 0 {
+		t.Errorf("Upgrade() of current collection = %q, %+v, %v, want no changes", from, files, err)
+	}
+
+	createFile(t, rootDir, "metadata.json", `{"version": "99"}`)
+	if _, _, err := Upgrade(rootDir); err == nil {
+		t.Errorf("Upgrade() of unknown version error = nil, want error")
+	}
+}
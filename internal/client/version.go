@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the version of the metadata format written by this release
+// of LBX. Metadata in older known versions is still read; `lbx metadata upgrade`
+// rewrites it in the current version.
+//
+// Version history:
+//
+//	1: initial format.
+//	2: every metadata file, not only the collection's, states its version.
+const CurrentVersion = "2"
+
+// knownVersions lists the versions of the metadata format, oldest first.
+var knownVersions = []string{"1", "2"}
+
+// versionIndex returns the index of version v in knownVersions, or -1 if unknown.
+func versionIndex(v string) int {
+	for i, known := range knownVersions {
+		if v == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkVersion checks that the version of a collection is known.
+func checkVersion(v string) error {
+	if v == "" {
+		return fmt.Errorf("require metadata version (current version is %s)", CurrentVersion)
+	}
+	if versionIndex(v) < 0 {
+		return fmt.Errorf("unknown metadata version %q: this release of LBX supports versions %s; upgrade LBX",
+			v, strings.Join(knownVersions, ", "))
+	}
+	return nil
+}
+
+// checkFileVersion checks the version v of an album or intermediate directory
+// metadata file in a collection with version collection. From version 2, it must
+// be set; in any version, if set, it must be the version of the collection.
+func checkFileVersion(v, collection string) error {
+	switch {
+	case v == "" && versionIndex(collection) >= versionIndex("2"):
+		return fmt.Errorf("require metadata version %s", collection)
+	case v != "" && v != collection:
+		return fmt.Errorf("metadata version %q differs from collection version %q; run `lbx metadata upgrade`", v, collection)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package metadata
+
+import "testing"
+
+func TestCheckVersion(t *testing.T) {
+	for _, v := range knownVersions {
+		if err := checkVersion(v); err != nil {
+			t.Errorf("checkVersion(%q) error = %v", v, err)
+		}
+	}
+	if knownVersions[len(knownVersions)-1] != CurrentVersion {
+		t.Errorf("last known version = %q, want CurrentVersion %q", knownVersions[len(knownVersions)-1], CurrentVersion)
+	}
+	if len(upgradeSteps) != len(knownVersions)-1 {
+		t.Errorf("%d upgrade steps for %d versions", len(upgradeSteps), len(knownVersions))
+	}
+	for _, v := range []string{"", "0", "3", "1.0"} {
+		if err := checkVersion(v); err == nil {
+			t.Errorf("checkVersion(%q) error = nil, want error", v)
+		}
+	}
+}
+
+func TestCheckFileVersion(t *testing.T) {
+	tests := []struct {
+		v, collection string
+		wantErr       bool
+	}{
+		{"", "1", false},
+		{"1", "1", false},
+		{"2", "1", true},
+		{"", "2", true},
+		{"2", "2", false},
+		{"1", "2", true},
+	}
+	for _, tt := range tests {
+		if err := checkFileVersion(tt.v, tt.collection); (err != nil) != tt.wantErr {
+			t.Errorf("checkFileVersion(%q, %q) error = %v, wantErr %t", tt.v, tt.collection, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+// Package exif extracts EXIF metadata from JPEG and TIFF files.
+package exif
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exif holds the EXIF fields of a photo that LBX stores in the media table
+// (see internal/server/db/migrations). Optional numeric fields are nil when absent,
+// optional strings are empty, and optional times are zero.
+type Exif struct {
+	// Width and Height are the pixel dimensions of the image, as stored
+	// (i.e., before applying Orientation).
+	Width  int
+	Height int
+	// DateTimeOriginal is the time the photo was taken (see CaptureTime). Times
+	// without an offset in the EXIF data are in the local time zone.
+	DateTimeOriginal time.Time
+	// DateTimeDigitized is the time the image was digitized.
+	DateTimeDigitized time.Time
+	// Latitude and Longitude are signed decimal degrees (media.latitude, media.longitude).
+	// South latitudes and west longitudes are negative.
+	Latitude  *float64
+	Longitude *float64
+	// Make and Model identify the camera (media.camera, see Camera).
+	Make  string
+	Model string
+	// LensModel identifies the lens (media.lens).
+	LensModel string
+	// FocalLength is the focal length in millimeters (media.focal_length).
+	FocalLength *float64
+	// ExposureTime is the exposure time in seconds (media.exposure_time).
+	ExposureTime *float64
+	// FNumber is the aperture f-number (media.aperture).
+	FNumber *float64
+	// ISO is the ISO speed rating (media.iso).
+	ISO *int
+	// Flash is the raw EXIF flash bit field (media.flash).
+	Flash *int
+	// Orientation is the EXIF orientation, 1-8, or 0 if absent (see Portrait).
+	Orientation int
+	// ImageDescription is the image description.
+	ImageDescription string
+}
+
+// CaptureTime returns the capture time stored in media.exif_time:
+// DateTimeOriginal, or DateTimeDigitized if the former is absent, or the zero
+// time if neither is.
+func (e *Exif) CaptureTime() time.Time {
+	if !e.DateTimeOriginal.IsZero() {
+		return e.DateTimeOriginal
+	}
+	return e.DateTimeDigitized
+}
+
+// Camera returns the camera description stored in media.camera: the model,
+// prefixed by the make unless the model already includes it.
+func (e *Exif) Camera() string {
+	if e.Make == "" || strings.HasPrefix(strings.ToLower(e.Model), strings.ToLower(e.Make)) {
+		return e.Model
+	}
+	if e.Model == "" {
+		return e.Make
+	}
+	return e.Make + " " + e.Model
+}
+
+// Rotated returns true if the orientation requires rotating the image by 90 degrees
+// (i.e., displayed width and height are swapped relative to stored ones).
+func (e *Exif) Rotated() bool {
+	return e.Orientation >= 5 && e.Orientation <= 8
+}
+
+// Portrait returns true if the image, as displayed, is taller than it is wide
+// (media.orientation: 0 for landscape, 1 for portrait).
+func (e *Exif) Portrait() bool {
+	if e.Rotated() {
+		return e.Width > e.Height
+	}
+	return e.Height > e.Width
+}
+
+// ErrUnsupported is returned for files that are neither JPEG nor TIFF.
+var ErrUnsupported = errors.New("exif: unsupported file format")
+
+// ReadFile reads the EXIF metadata of a JPEG or TIFF file.
+func ReadFile(path string) (*Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// Decode reads the EXIF metadata of a JPEG or TIFF image. An image without
+// EXIF data is not an error: the result then only carries the image dimensions.
+func Decode(r io.ReaderAt) (*Exif, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, ErrUnsupported
+	}
+	e := &Exif{}
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		if err := decodeJPEG(r, e); err != nil {
+			return nil, err
+		}
+	case string(magic[:]) == "II*\x00" || string(magic[:]) == "MM\x00*":
+		if err := decodeTIFF(r, e, true); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+	return e, nil
+}
+
+// JPEG markers.
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP1 = 0xE1
+)
+
+// exifHeader prefixes the TIFF structure in a JPEG APP1 segment.
+const exifHeader = "Exif\x00\x00"
+
+// decodeJPEG walks the JPEG segments up to the start of scan, reading the image
+// dimensions from the frame header and EXIF data from the APP1 segment.
+func decodeJPEG(r io.ReaderAt, e *Exif) error {
+	off := int64(2)
+	var hdr [4]byte
+	for {
+		if _, err := r.ReadAt(hdr[:2], off); err != nil {
+			return fmt.Errorf("exif: truncated JPEG: %v", err)
+		}
+		if hdr[0] != 0xFF {
+			return fmt.Errorf("exif: invalid JPEG marker at offset %d", off)
+		}
+		marker := hdr[1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte.
+			off++
+			continue
+		case marker == markerSOS || marker == markerEOI:
+			return nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= markerSOI):
+			// Standalone markers have no length.
+			off += 2
+			continue
+		}
+		if _, err := r.ReadAt(hdr[2:4], off+2); err != nil {
+			return fmt.Errorf("exif: truncated JPEG: %v", err)
+		}
+		length := int64(binary.BigEndian.Uint16(hdr[2:4]))
+		if length < 2 {
+			return fmt.Errorf("exif: invalid JPEG segment length at offset %d", off)
+		}
+		payload := off + 4
+		switch {
+		case marker == markerAPP1 && length >= 2+int64(len(exifHeader))+8:
+			var id [len(exifHeader)]byte
+			if _, err := r.ReadAt(id[:], payload); err != nil {
+				return fmt.Errorf("exif: truncated JPEG: %v", err)
+			}
+			if string(id[:]) == exifHeader {
+				start := payload + int64(len(exifHeader))
+				tiff := io.NewSectionReader(r, start, length-2-int64(len(exifHeader)))
+				if err := decodeTIFF(tiff, e, false); err != nil {
+					return err
+				}
+			}
+		case isSOF(marker):
+			// Frame header: precision (1), height (2), width (2), ...
+			var dims [5]byte
+			if _, err := r.ReadAt(dims[:], payload); err != nil {
+				return fmt.Errorf("exif: truncated JPEG: %v", err)
+			}
+			e.Height = int(binary.BigEndian.Uint16(dims[1:3]))
+			e.Width = int(binary.BigEndian.Uint16(dims[3:5]))
+		}
+		off += 2 + length
+	}
+}
+
+// isSOF returns true for start-of-frame markers (SOF0-SOF15, excluding DHT, JPG and DAC).
+func isSOF(m byte) bool {
+	return m >= 0xC0 && m <= 0xCF && m != 0xC4 && m != 0xC8 && m != 0xCC
+}
+
+// TIFF tags.
+const (
+	tagImageWidth          = 0x0100
+	tagImageLength         = 0x0101
+	tagImageDescription    = 0x010E
+	tagMake                = 0x010F
+	tagModel               = 0x0110
+	tagOrientation         = 0x0112
+	tagExifIFD             = 0x8769
+	tagGPSIFD              = 0x8825
+	tagExposureTime        = 0x829A
+	tagFNumber             = 0x829D
+	tagISO                 = 0x8827
+	tagDateTimeOriginal    = 0x9003
+	tagDateTimeDigitized   = 0x9004
+	tagOffsetTimeOriginal  = 0x9011
+	tagOffsetTimeDigitized = 0x9012
+	tagFlash               = 0x9209
+	tagFocalLength         = 0x920A
+	tagLensModel           = 0xA434
+	tagGPSLatitudeRef      = 0x0001
+	tagGPSLatitude         = 0x0002
+	tagGPSLongitudeRef     = 0x0003
+	tagGPSLongitude        = 0x0004
+)
+
+// TIFF field types.
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]int64{
+	typeByte: 1, typeASCII: 1, typeShort: 2, typeLong: 4, typeRational: 8,
+	typeUndefined: 1, typeSLong: 4, typeSRational: 8,
+}
+
+// Limits that protect against corrupt or malicious files.
+const (
+	maxIFDEntries = 1000
+	maxValueSize  = 1 << 20
+)
+
+// field is a decoded IFD entry.
+type field struct {
+	typ   uint16
+	count int64
+	data  []byte
+}
+
+// tiffReader reads IFDs from a TIFF structure.
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// decodeTIFF decodes the TIFF structure in r. If dims is true, the image
+// dimensions are taken from IFD0 (for TIFF files; JPEG files use the frame header).
+func decodeTIFF(r io.ReaderAt, e *Exif, dims bool) error {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return fmt.Errorf("exif: truncated TIFF header: %v", err)
+	}
+	t := &tiffReader{r: r}
+	switch string(hdr[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return fmt.Errorf("exif: invalid TIFF byte order")
+	}
+	if t.order.Uint16(hdr[2:4]) != 42 {
+		return fmt.Errorf("exif: invalid TIFF magic number")
+	}
+	ifd0, err := t.readIFD(int64(t.order.Uint32(hdr[4:8])))
+	if err != nil {
+		return err
+	}
+	if dims {
+		e.Width = t.intValue(ifd0[tagImageWidth])
+		e.Height = t.intValue(ifd0[tagImageLength])
+	}
+	e.ImageDescription = stringValue(ifd0[tagImageDescription])
+	e.Make = stringValue(ifd0[tagMake])
+	e.Model = stringValue(ifd0[tagModel])
+	e.Orientation = t.intValue(ifd0[tagOrientation])
+
+	if f := ifd0[tagExifIFD]; f != nil {
+		sub, err := t.readIFD(int64(t.intValue(f)))
+		if err != nil {
+			return err
+		}
+		e.ExposureTime = t.rationalPtr(sub[tagExposureTime])
+		e.FNumber = t.rationalPtr(sub[tagFNumber])
+		e.FocalLength = t.rationalPtr(sub[tagFocalLength])
+		e.ISO = t.intPtr(sub[tagISO])
+		e.Flash = t.intPtr(sub[tagFlash])
+		e.LensModel = stringValue(sub[tagLensModel])
+		e.DateTimeOriginal = parseTime(stringValue(sub[tagDateTimeOriginal]), stringValue(sub[tagOffsetTimeOriginal]))
+		e.DateTimeDigitized = parseTime(stringValue(sub[tagDateTimeDigitized]), stringValue(sub[tagOffsetTimeDigitized]))
+	}
+	if f := ifd0[tagGPSIFD]; f != nil {
+		gps, err := t.readIFD(int64(t.intValue(f)))
+		if err != nil {
+			return err
+		}
+		e.Latitude = t.coordinate(gps[tagGPSLatitude], stringValue(gps[tagGPSLatitudeRef]), "S")
+		e.Longitude = t.coordinate(gps[tagGPSLongitude], stringValue(gps[tagGPSLongitudeRef]), "W")
+	}
+	return nil
+}
+
+// readIFD reads the IFD at offset off, returning its fields indexed by tag.
+// Fields of unknown type are skipped.
+func (t *tiffReader) readIFD(off int64) (map[uint16]*field, error) {
+	var buf [12]byte
+	if _, err := t.r.ReadAt(buf[:2], off); err != nil {
+		return nil, fmt.Errorf("exif: truncated IFD at offset %d", off)
+	}
+	n := int(t.order.Uint16(buf[:2]))
+	if n > maxIFDEntries {
+		return nil, fmt.Errorf("exif: too many IFD entries at offset %d", off)
+	}
+	fields := make(map[uint16]*field, n)
+	for i := 0; i < n; i++ {
+		if _, err := t.r.ReadAt(buf[:], off+2+int64(i)*12); err != nil {
+			return nil, fmt.Errorf("exif: truncated IFD at offset %d", off)
+		}
+		tag := t.order.Uint16(buf[0:2])
+		typ := t.order.Uint16(buf[2:4])
+		count := int64(t.order.Uint32(buf[4:8]))
+		size, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+		size *= count
+		if size > maxValueSize {
+			return nil, fmt.Errorf("exif: IFD value too large for tag 0x%04x", tag)
+		}
+		data := make([]byte, size)
+		if size <= 4 {
+			copy(data, buf[8:8+size])
+		} else if _, err := t.r.ReadAt(data, int64(t.order.Uint32(buf[8:12]))); err != nil {
+			return nil, fmt.Errorf("exif: truncated value for tag 0x%04x", tag)
+		}
+		fields[tag] = &field{typ: typ, count: count, data: data}
+	}
+	return fields, nil
+}
+
+// stringValue returns the value of an ASCII field, without trailing NULs and spaces.
+func stringValue(f *field) string {
+	if f == nil || (f.typ != typeASCII && f.typ != typeUndefined) {
+		return ""
+	}
+	return strings.TrimRight(string(f.data), "\x00 ")
+}
+
+// intValue returns the first value of an integer field, or 0 if absent.
+func (t *tiffReader) intValue(f *field) int {
+	v, _ := t.int(f)
+	return v
+}
+
+// intPtr returns the first value of an integer field, or nil if absent.
+func (t *tiffReader) intPtr(f *field) *int {
+	if v, ok := t.int(f); ok {
+		return &v
+	}
+	return nil
+}
+
+func (t *tiffReader) int(f *field) (int, bool) {
+	if f == nil || f.count < 1 {
+		return 0, false
+	}
+	switch f.typ {
+	case typeByte:
+		return int(f.data[0]), true
+	case typeShort:
+		return int(t.order.Uint16(f.data)), true
+	case typeLong:
+		return int(t.order.Uint32(f.data)), true
+	case typeSLong:
+		return int(int32(t.order.Uint32(f.data))), true
+	}
+	return 0, false
+}
+
+// rational returns the i-th value of a rational field.
+func (t *tiffReader) rational(f *field, i int64) (float64, bool) {
+	if f == nil || i >= f.count {
+		return 0, false
+	}
+	d := f.data[8*i:]
+	var num, den float64
+	switch f.typ {
+	case typeRational:
+		num, den = float64(t.order.Uint32(d[0:4])), float64(t.order.Uint32(d[4:8]))
+	case typeSRational:
+		num, den = float64(int32(t.order.Uint32(d[0:4]))), float64(int32(t.order.Uint32(d[4:8])))
+	default:
+		return 0, false
+	}
+	if den == 0 {
+		return 0, false
+	}
+	return num / den, true
+}
+
+// rationalPtr returns the first value of a rational field, or nil if absent.
+func (t *tiffReader) rationalPtr(f *field) *float64 {
+	if v, ok := t.rational(f, 0); ok {
+		return &v
+	}
+	return nil
+}
+
+// coordinate converts a GPS degrees/minutes/seconds field to signed decimal degrees.
+// The result is negative if ref equals neg.
+func (t *tiffReader) coordinate(f *field, ref, neg string) *float64 {
+	if f == nil || f.count < 3 {
+		return nil
+	}
+	var dms [3]float64
+	for i := range dms {
+		v, ok := t.rational(f, int64(i))
+		if !ok {
+			return nil
+		}
+		dms[i] = v
+	}
+	deg := dms[0] + dms[1]/60 + dms[2]/3600
+	if math.IsNaN(deg) || math.IsInf(deg, 0) {
+		return nil
+	}
+	if strings.EqualFold(ref, neg) {
+		deg = -deg
+	}
+	return &deg
+}
+
+// exifTimeLayout is the layout of EXIF date/time fields.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// parseTime parses an EXIF date/time with an optional "+HH:MM" offset. Times
+// without an offset are wall-clock times of unknown zone; they are read in the
+// local time zone, like the modification times of files, which they are compared
+// with when sorting (the camera and the computer that imports its photos are
+// usually set to the same zone). Returns the zero time if s is empty or invalid.
+func parseTime(s, offset string) time.Time {
+	s = strings.TrimSpace(s)
+	t, err := time.ParseInLocation(exifTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	if offset == "" {
+		return t
+	}
+	if zt, err := time.Parse(exifTimeLayout+"-07:00", s+offset); err == nil {
+		return zt
+	}
+	return t
+}
@@ -0,0 +1,199 @@
+package exif
+
+import (
+	"bytes"
+	"image/jpeg"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		file             string
+		width, height    int
+		taken, digitized time.Time
+		lat, long        float64 // NaN if absent
+		camera, lens     string
+		focal, exp, fnum float64 // NaN if absent
+		iso, flash       int     // -1 if absent
+		orientation      int
+		portrait         bool
+		description      string
+	}{
+		{
+			file: "testdata/canon.jpg", width: 16, height: 8,
+			taken:     time.Date(2019, 7, 14, 16, 32, 5, 0, time.UTC),
+			digitized: time.Date(2019, 7, 14, 16, 32, 6, 0, time.UTC),
+			lat:       46.51, long: 6.63,
+			camera: "Canon EOS 5D Mark IV", lens: "EF50mm f/1.8 STM",
+			focal: 50, exp: 0.004, fnum: 2.8, iso: 400, flash: 16,
+			orientation: 6, portrait: true,
+			description: "Sunset over the lake",
+		},
+		{
+			file: "testdata/nikon.jpg", width: 8, height: 16,
+			taken: time.Date(2021, 12, 24, 9, 15, 0, 0, time.Local),
+			lat:   -33.85875, long: -70.65,
+			camera: "NIKON CORPORATION NIKON D850",
+			focal:  24, exp: 1.0 / 60, fnum: 4, iso: 64, flash: 1,
+			orientation: 1, portrait: true,
+		},
+		{
+			file: "testdata/plain.jpg", width: 12, height: 10,
+			lat: math.NaN(), long: math.NaN(),
+			focal: math.NaN(), exp: math.NaN(), fnum: math.NaN(), iso: -1, flash: -1,
+		},
+		{
+			file: "testdata/scan.tif", width: 4, height: 3,
+			digitized: time.Date(1998, 5, 30, 12, 0, 0, 0, time.Local),
+			lat:       math.NaN(), long: math.NaN(),
+			camera: "EPSON Perfection V600",
+			focal:  math.NaN(), exp: math.NaN(), fnum: math.NaN(), iso: -1, flash: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			e, err := ReadFile(tt.file)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if e.Width != tt.width || e.Height != tt.height {
+				t.Errorf("dimensions = %dx%d, want %dx%d", e.Width, e.Height, tt.width, tt.height)
+			}
+			if !e.DateTimeOriginal.Equal(tt.taken) {
+				t.Errorf("DateTimeOriginal = %v, want %v", e.DateTimeOriginal, tt.taken)
+			}
+			if !e.DateTimeDigitized.Equal(tt.digitized) {
+				t.Errorf("DateTimeDigitized = %v, want %v", e.DateTimeDigitized, tt.digitized)
+			}
+			checkFloat(t, "Latitude", e.Latitude, tt.lat)
+			checkFloat(t, "Longitude", e.Longitude, tt.long)
+			checkFloat(t, "FocalLength", e.FocalLength, tt.focal)
+			checkFloat(t, "ExposureTime", e.ExposureTime, tt.exp)
+			checkFloat(t, "FNumber", e.FNumber, tt.fnum)
+			checkInt(t, "ISO", e.ISO, tt.iso)
+			checkInt(t, "Flash", e.Flash, tt.flash)
+			if e.Camera() != tt.camera {
+				t.Errorf("Camera() = %q, want %q", e.Camera(), tt.camera)
+			}
+			if e.LensModel != tt.lens {
+				t.Errorf("LensModel = %q, want %q", e.LensModel, tt.lens)
+			}
+			if e.Orientation != tt.orientation {
+				t.Errorf("Orientation = %d, want %d", e.Orientation, tt.orientation)
+			}
+			if e.Portrait() != tt.portrait {
+				t.Errorf("Portrait() = %v, want %v", e.Portrait(), tt.portrait)
+			}
+			if e.ImageDescription != tt.description {
+				t.Errorf("ImageDescription = %q, want %q", e.ImageDescription, tt.description)
+			}
+		})
+	}
+}
+
+func checkFloat(t *testing.T, name string, got *float64, want float64) {
+	t.Helper()
+	if math.IsNaN(want) {
+		if got != nil {
+			t.Errorf("%s = %v, want nil", name, *got)
+		}
+		return
+	}
+	if got == nil {
+		t.Errorf("%s = nil, want %v", name, want)
+	} else if math.Abs(*got-want) > 1e-6 {
+		t.Errorf("%s = %v, want %v", name, *got, want)
+	}
+}
+
+func checkInt(t *testing.T, name string, got *int, want int) {
+	t.Helper()
+	if want < 0 {
+		if got != nil {
+			t.Errorf("%s = %v, want nil", name, *got)
+		}
+		return
+	}
+	if got == nil {
+		t.Errorf("%s = nil, want %v", name, want)
+	} else if *got != want {
+		t.Errorf("%s = %v, want %v", name, *got, want)
+	}
+}
+
+func TestFixturesAreValidJPEG(t *testing.T) {
+	for _, fn := range []string{"testdata/canon.jpg", "testdata/nikon.jpg", "testdata/plain.jpg"} {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+			t.Errorf("%s: jpeg.Decode() error = %v", fn, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	canon, err := os.ReadFile("testdata/canon.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not an image", []byte("GIF89a......")},
+		{"truncated JPEG", canon[:40]},
+		{"bad TIFF magic", []byte("II\x2b\x00\x08\x00\x00\x00")},
+		{"truncated IFD", []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x05")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader(tt.data)); err == nil {
+				t.Errorf("Decode() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("UTC-5", -5*3600)
+	tests := []struct {
+		s, offset string
+		want      time.Time
+	}{
+		{"2019:07:14 16:32:05", "", time.Date(2019, 7, 14, 21, 32, 5, 0, time.UTC)},
+		{"2019:07:14 16:32:05", "+02:00", time.Date(2019, 7, 14, 14, 32, 5, 0, time.UTC)},
+		{" 2019:07:14 16:32:05 ", "bogus", time.Date(2019, 7, 14, 21, 32, 5, 0, time.UTC)},
+		{"2019:07:14", "", time.Time{}},
+		{"", "", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseTime(tt.s, tt.offset); !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q, %q) = %v, want %v", tt.s, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestCaptureTime(t *testing.T) {
+	original := time.Date(2019, 7, 14, 16, 32, 5, 0, time.UTC)
+	digitized := time.Date(2019, 7, 15, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		e    Exif
+		want time.Time
+	}{
+		{Exif{DateTimeOriginal: original, DateTimeDigitized: digitized}, original},
+		{Exif{DateTimeDigitized: digitized}, digitized},
+		{Exif{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := tt.e.CaptureTime(); !got.Equal(tt.want) {
+			t.Errorf("CaptureTime() of %+v = %v, want %v", tt.e, got, tt.want)
+		}
+	}
+}
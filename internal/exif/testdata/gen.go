@@ -0,0 +1,226 @@
+//go:build ignore
+
+// gen writes the EXIF test fixtures in this directory.
+// Run with `go run gen.go` from internal/exif/testdata.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"os"
+)
+
+// entry is an IFD entry to be encoded.
+type entry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte  // Encoded value.
+	sub   []entry // Sub-IFD (for LONG pointer entries), resolved at encoding time.
+}
+
+type builder struct {
+	order binary.ByteOrder
+}
+
+func (b builder) ascii(tag uint16, s string) entry {
+	d := append([]byte(s), 0)
+	return entry{tag: tag, typ: 2, count: uint32(len(d)), data: d}
+}
+
+func (b builder) short(tag uint16, v uint16) entry {
+	d := make([]byte, 2)
+	b.order.PutUint16(d, v)
+	return entry{tag: tag, typ: 3, count: 1, data: d}
+}
+
+func (b builder) long(tag uint16, v uint32) entry {
+	d := make([]byte, 4)
+	b.order.PutUint32(d, v)
+	return entry{tag: tag, typ: 4, count: 1, data: d}
+}
+
+func (b builder) rational(tag uint16, v ...uint32) entry {
+	d := make([]byte, 4*len(v))
+	for i, x := range v {
+		b.order.PutUint32(d[4*i:], x)
+	}
+	return entry{tag: tag, typ: 5, count: uint32(len(v) / 2), data: d}
+}
+
+func (b builder) undefined(tag uint16, d []byte) entry {
+	return entry{tag: tag, typ: 7, count: uint32(len(d)), data: d}
+}
+
+func (b builder) ifd(tag uint16, sub ...entry) entry {
+	return entry{tag: tag, typ: 4, count: 1, sub: sub}
+}
+
+// tiff encodes a TIFF structure with the given IFD0 entries. Strip data, if any,
+// is stored right after the header, at offset 8.
+func (b builder) tiff(ifd0 []entry, strip []byte) []byte {
+	var buf bytes.Buffer
+	if b.order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, b.order, uint16(42))
+	if len(strip)%2 == 1 {
+		strip = append(strip, 0)
+	}
+	base := uint32(8 + len(strip))
+	binary.Write(&buf, b.order, base)
+	buf.Write(strip)
+	buf.Write(b.encodeIFD(ifd0, base))
+	return buf.Bytes()
+}
+
+// encodeIFD encodes an IFD (and its sub-IFDs and out-of-line values) at offset base.
+func (b builder) encodeIFD(entries []entry, base uint32) []byte {
+	// Entries must be sorted by tag.
+	for i := 1; i < len(entries); i++ {
+		for j := i; j > 0 && entries[j].tag < entries[j-1].tag; j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+		}
+	}
+	size := uint32(2 + 12*len(entries) + 4)
+	var head, tail bytes.Buffer
+	binary.Write(&head, b.order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&head, b.order, e.tag)
+		binary.Write(&head, b.order, e.typ)
+		binary.Write(&head, b.order, e.count)
+		off := base + size + uint32(tail.Len())
+		switch {
+		case e.sub != nil:
+			binary.Write(&head, b.order, off)
+			tail.Write(b.encodeIFD(e.sub, off))
+		case len(e.data) <= 4:
+			v := make([]byte, 4)
+			copy(v, e.data)
+			head.Write(v)
+		default:
+			binary.Write(&head, b.order, off)
+			tail.Write(e.data)
+			if tail.Len()%2 == 1 {
+				tail.WriteByte(0)
+			}
+		}
+	}
+	binary.Write(&head, b.order, uint32(0)) // No next IFD.
+	return append(head.Bytes(), tail.Bytes()...)
+}
+
+// jpegWithExif encodes a small w x h JPEG and inserts an APP1 EXIF segment after SOI.
+func jpegWithExif(w, h int, tiff []byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(255 * x / w), uint8(255 * y / h), 128, 255})
+		}
+	}
+	var enc bytes.Buffer
+	if err := jpeg.Encode(&enc, img, &jpeg.Options{Quality: 50}); err != nil {
+		log.Fatal(err)
+	}
+	data := enc.Bytes()
+	if tiff == nil {
+		return data
+	}
+	var out bytes.Buffer
+	out.Write(data[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+6+len(tiff)))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	// canon.jpg: little-endian, all supported tags, rotated 90 degrees.
+	le := builder{binary.LittleEndian}
+	write("canon.jpg", jpegWithExif(16, 8, le.tiff([]entry{
+		le.ascii(0x010E, "Sunset over the lake"),
+		le.ascii(0x010F, "Canon"),
+		le.ascii(0x0110, "Canon EOS 5D Mark IV"),
+		le.short(0x0112, 6),
+		le.ifd(0x8769,
+			le.rational(0x829A, 1, 250),
+			le.rational(0x829D, 28, 10),
+			le.short(0x8827, 400),
+			le.ascii(0x9003, "2019:07:14 18:32:05"),
+			le.ascii(0x9004, "2019:07:14 18:32:06"),
+			le.ascii(0x9011, "+02:00"),
+			le.ascii(0x9012, "+02:00"),
+			le.short(0x9209, 16),
+			le.rational(0x920A, 50, 1),
+			le.ascii(0xA434, "EF50mm f/1.8 STM"),
+		),
+		le.ifd(0x8825,
+			le.ascii(0x0001, "N"),
+			le.rational(0x0002, 46, 1, 30, 1, 36, 1),
+			le.ascii(0x0003, "E"),
+			le.rational(0x0004, 6, 1, 37, 1, 4800, 100),
+		),
+	}, nil)))
+
+	// nikon.jpg: big-endian, southern/western hemisphere, no time offset.
+	be := builder{binary.BigEndian}
+	write("nikon.jpg", jpegWithExif(8, 16, be.tiff([]entry{
+		be.ascii(0x010F, "NIKON CORPORATION"),
+		be.ascii(0x0110, "NIKON D850"),
+		be.short(0x0112, 1),
+		be.ifd(0x8769,
+			be.rational(0x829A, 1, 60),
+			be.rational(0x829D, 4, 1),
+			be.short(0x8827, 64),
+			be.ascii(0x9003, "2021:12:24 09:15:00"),
+			be.short(0x9209, 1),
+			be.rational(0x920A, 240, 10),
+			be.undefined(0x9000, []byte("0231")),
+		),
+		be.ifd(0x8825,
+			be.ascii(0x0001, "S"),
+			be.rational(0x0002, 33, 1, 51, 1, 3150, 100),
+			be.ascii(0x0003, "W"),
+			be.rational(0x0004, 70, 1, 39, 1, 0, 1),
+		),
+	}, nil)))
+
+	// plain.jpg: no EXIF data.
+	write("plain.jpg", jpegWithExif(12, 10, nil))
+
+	// scan.tif: uncompressed 4x3 grayscale TIFF with only a digitized time.
+	pixels := make([]byte, 12)
+	for i := range pixels {
+		pixels[i] = byte(20 * i)
+	}
+	write("scan.tif", le.tiff([]entry{
+		le.long(0x0100, 4),
+		le.long(0x0101, 3),
+		le.short(0x0102, 8),
+		le.short(0x0103, 1),
+		le.short(0x0106, 1),
+		le.ascii(0x010F, "EPSON"),
+		le.ascii(0x0110, "Perfection V600"),
+		le.long(0x0111, 8),
+		le.short(0x0115, 1),
+		le.long(0x0116, 3),
+		le.long(0x0117, uint32(len(pixels))),
+		le.ifd(0x8769,
+			le.ascii(0x9004, "1998:05:30 12:00:00"),
+		),
+	}, pixels))
+}
@@ -0,0 +1,250 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client is a client of the ingest API.
+type Client struct {
+	base  *url.URL
+	token string
+	http  *http.Client
+	// retryDelay is the delay before retrying a failed chunk, multiplied by the
+	// number of consecutive failures.
+	retryDelay time.Duration
+}
+
+// maxChunkRetries is the number of consecutive failures after which Upload gives up.
+const maxChunkRetries = 5
+
+// NewClient returns a client of the ingest API of the lbxd server at baseURL
+// (e.g., "https://photos.example.com"), authenticated with an admin token.
+// If hc is nil, http.DefaultClient is used.
+func NewClient(baseURL, token string, hc *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %s", baseURL)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("missing admin token")
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{base: u, token: token, http: hc, retryDelay: time.Second}, nil
+}
+
+// escapePath escapes each component of a slash-separated path.
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// send sends a request to the ingest API.
+func (c *Client) send(method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base.String()+Prefix+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.http.Do(req)
+}
+
+// responseError returns the error reported by a failed response.
+func responseError(method, path string, resp *http.Response) error {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+		e.Error = resp.Status
+	}
+	return fmt.Errorf("%s %s: %s", method, Prefix+path, e.Error)
+}
+
+// do sends a request to the ingest API. If out is non-nil, the JSON response is
+// decoded into it. Returns the response status.
+func (c *Client) do(method, path string, body io.Reader, contentType string, out any) (int, error) {
+	resp, err := c.send(method, path, body, contentType)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		if method == http.MethodHead {
+			return resp.StatusCode, nil
+		}
+		return resp.StatusCode, responseError(method, path, resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s %s: invalid response: %v", method, Prefix+path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// putJSON sends v as the JSON body of a PUT request.
+func (c *Client) putJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodPut, path, bytes.NewReader(data), "application/json", nil)
+	return err
+}
+
+// Sources returns the published source files.
+func (c *Client) Sources() ([]Source, error) {
+	var sources []Source
+	_, err := c.do(http.MethodGet, "sources", nil, "", &sources)
+	return sources, err
+}
+
+// PutAlbum creates or updates the album at path.
+func (c *Client) PutAlbum(path string, a *Album) error {
+	return c.putJSON("albums/"+escapePath(path), a)
+}
+
+// Albums returns the paths of all albums.
+func (c *Client) Albums() ([]string, error) {
+	var paths []string
+	_, err := c.do(http.MethodGet, "albums", nil, "", &paths)
+	return paths, err
+}
+
+// DeleteAlbum deletes the album at path with its media items.
+func (c *Client) DeleteAlbum(path string) error {
+	_, err := c.do(http.MethodDelete, "albums/"+escapePath(path), nil, "", nil)
+	return err
+}
+
+// HasBlob reports whether the rendition with the given hash was uploaded.
+func (c *Client) HasBlob(hash string) (bool, error) {
+	status, err := c.do(http.MethodHead, "blobs/"+hash, nil, "", nil)
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusOK:
+		return true, nil
+	case status == http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("HEAD %sblobs/%s: status %d", Prefix, hash, status)
+}
+
+// PutBlob uploads a rendition whose hex SHA-256 hash is hash.
+func (c *Client) PutBlob(hash string, data []byte) error {
+	_, err := c.do(http.MethodPut, "blobs/"+hash, bytes.NewReader(data), "image/jpeg", nil)
+	return err
+}
+
+// PutMedia creates or updates the media item with the given source filename in an album.
+func (c *Client) PutMedia(album, filename string, m *Media) error {
+	return c.putJSON("media/"+escapePath(album+"/"+filename), m)
+}
+
+// DeleteMedia deletes the media item with the given source filename from an album.
+func (c *Client) DeleteMedia(album, filename string) error {
+	_, err := c.do(http.MethodDelete, "media/"+escapePath(album+"/"+filename), nil, "", nil)
+	return err
+}
+
+// StartUpload starts or resumes a resumable upload of a blob.
+func (c *Client) StartUpload(hash string, size int64) (*UploadStatus, error) {
+	data, err := json.Marshal(UploadRequest{Hash: hash, Size: size})
+	if err != nil {
+		return nil, err
+	}
+	var st UploadStatus
+	_, err = c.do(http.MethodPost, "uploads", bytes.NewReader(data), "application/json", &st)
+	return &st, err
+}
+
+// UploadStatus returns the status of an upload session.
+func (c *Client) UploadStatus(id string) (*UploadStatus, error) {
+	var st UploadStatus
+	_, err := c.do(http.MethodGet, "uploads/"+url.PathEscape(id), nil, "", &st)
+	return &st, err
+}
+
+// PutChunk uploads the chunk of an upload session starting at offset and returns
+// the new status of the session. If offset is not the number of bytes received,
+// the chunk is ignored and the current status is returned.
+func (c *Client) PutChunk(id string, offset int64, data []byte) (*UploadStatus, error) {
+	path := fmt.Sprintf("uploads/%s?offset=%d", url.PathEscape(id), offset)
+	resp, err := c.send(http.MethodPut, path, bytes.NewReader(data), "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return nil, responseError(http.MethodPut, path, resp)
+	}
+	var st UploadStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("PUT %s%s: invalid response: %v", Prefix, path, err)
+	}
+	return &st, nil
+}
+
+// FinalizeUpload completes an upload session whose data was all received.
+func (c *Client) FinalizeUpload(id string) error {
+	_, err := c.do(http.MethodPost, "uploads/"+url.PathEscape(id)+"/finalize", nil, "", nil)
+	return err
+}
+
+// AbortUpload deletes an upload session.
+func (c *Client) AbortUpload(id string) error {
+	_, err := c.do(http.MethodDelete, "uploads/"+url.PathEscape(id), nil, "", nil)
+	return err
+}
+
+// Upload uploads the blob of the given hash and size read from r in chunks of
+// ChunkSize, resuming any interrupted upload of the same blob, even by another
+// process. Failed chunks are retried.
+func (c *Client) Upload(hash string, r io.ReaderAt, size int64) error {
+	st, err := c.StartUpload(hash, size)
+	if err != nil {
+		return err
+	}
+	if st.Complete {
+		return nil
+	}
+	buf := make([]byte, min(size, ChunkSize))
+	for failures := 0; st.Received < st.Size; {
+		chunk := buf[:min(st.Size-st.Received, int64(len(buf)))]
+		if _, err := r.ReadAt(chunk, st.Received); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		next, err := c.PutChunk(st.ID, st.Received, chunk)
+		if err != nil {
+			if failures++; failures > maxChunkRetries {
+				return err
+			}
+			time.Sleep(time.Duration(failures) * c.retryDelay)
+			// The chunk may have been received even if the response was lost.
+			if next, err = c.UploadStatus(st.ID); err != nil {
+				continue
+			}
+		} else {
+			failures = 0
+		}
+		st = next
+	}
+	return c.FinalizeUpload(st.ID)
+}
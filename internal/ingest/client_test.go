@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// flakyUploads is an in-memory upload endpoint that loses the response to every
+// other chunk after storing it.
+type flakyUploads struct {
+	data      []byte
+	size      int64
+	puts      int
+	finalized bool
+}
+
+func (f *flakyUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := func() {
+		json.NewEncoder(w).Encode(UploadStatus{ID: "u1", Size: f.size, Received: int64(len(f.data))})
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == Prefix+"uploads":
+		var req UploadRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.size = req.Size
+		status()
+	case r.Method == http.MethodGet && r.URL.Path == Prefix+"uploads/u1":
+		status()
+	case r.Method == http.MethodPut && r.URL.Path == Prefix+"uploads/u1":
+		offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+		if offset != int64(len(f.data)) {
+			w.WriteHeader(http.StatusConflict)
+			status()
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		f.data = append(f.data, chunk...)
+		if f.puts++; f.puts%2 == 1 {
+			http.Error(w, `{"error": "lost"}`, http.StatusBadGateway)
+			return
+		}
+		status()
+	case r.Method == http.MethodPost && r.URL.Path == Prefix+"uploads/u1/finalize":
+		f.finalized = int64(len(f.data)) == f.size
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestUploadRetries(t *testing.T) {
+	f := &flakyUploads{}
+	hs := httptest.NewServer(f)
+	defer hs.Close()
+	c, err := NewClient(hs.URL, "t", hs.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	c.retryDelay = 0
+	data := bytes.Repeat([]byte("x"), 2*ChunkSize+10)
+	if err := c.Upload("h", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if !f.finalized || !bytes.Equal(f.data, data) {
+		t.Errorf("Upload() stored %d bytes (finalized %t), want %d", len(f.data), f.finalized, len(data))
+	}
+	// Each of the 3 chunks was sent once: lost responses are recovered from the status.
+	if f.puts != 3 {
+		t.Errorf("Upload() sent %d chunks, want 3", f.puts)
+	}
+}
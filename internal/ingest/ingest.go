@@ -0,0 +1,185 @@
+// Package ingest defines the lbxd ingest API, through which lbx publishes a
+// collection, and implements a client for it.
+//
+// All requests are authenticated with an admin token (see "lbxd admin") in an
+// "Authorization: Bearer TOKEN" header. Admin tokens are unrelated to access
+// keys, which only grant read access. All requests are idempotent, so they may
+// be retried safely:
+//
+//	GET    /api/ingest/sources             list published source files ([]Source)
+//	GET    /api/ingest/albums              list the paths of all albums ([]string)
+//	PUT    /api/ingest/albums/ALBUM        create or update an album (Album)
+//	DELETE /api/ingest/albums/ALBUM        delete an album with its media items and
+//	                                       their renditions
+//	HEAD   /api/ingest/blobs/HASH          check whether a rendition was uploaded
+//	PUT    /api/ingest/blobs/HASH          upload a rendition (JPEG data); HASH must
+//	                                       be the hex SHA-256 hash of the data
+//	PUT    /api/ingest/media/ALBUM/FILE    create or update a media item (Media); its
+//	                                       album and renditions must exist
+//	DELETE /api/ingest/media/ALBUM/FILE    delete a media item and its renditions
+//
+// ALBUM is the album path relative to the collection root and FILE the source
+// filename. Errors are reported as {"error": MESSAGE}.
+//
+// Blobs too large for a single request (e.g., videos) are uploaded in chunks
+// through a resumable upload session, which is identified by ID but may also be
+// looked up by the hash of the blob, so a client can resume an interrupted upload
+// without keeping any state:
+//
+//	POST   /api/ingest/uploads             start or resume the upload of a blob
+//	                                       (UploadRequest); returns UploadStatus
+//	GET    /api/ingest/uploads/ID          return the UploadStatus of a session
+//	PUT    /api/ingest/uploads/ID?offset=N upload the chunk starting at byte N, which
+//	                                       must be the number of bytes received so far;
+//	                                       returns UploadStatus, or 409 with the
+//	                                       UploadStatus if N is wrong
+//	POST   /api/ingest/uploads/ID/finalize verify the hash of the blob and store it
+//	                                       like PUT /api/ingest/blobs/HASH
+//	DELETE /api/ingest/uploads/ID          abort an upload
+//
+// Sessions that receive no data for a day (see lbxd -upload-ttl) are deleted.
+package ingest
+
+import (
+	"time"
+)
+
+// Prefix is the path prefix of the ingest API.
+const Prefix = "/api/ingest/"
+
+// MaxBlobSize is the maximum size of a rendition upload, and of a chunk of a
+// resumable upload.
+const MaxBlobSize = 32 << 20
+
+// MaxUploadSize is the maximum size of a blob uploaded in chunks.
+const MaxUploadSize = 64 << 30
+
+// ChunkSize is the size of the chunks sent by Client.Upload. Blobs larger than
+// ChunkSize should be uploaded in chunks rather than with PutBlob.
+const ChunkSize = 8 << 20
+
+// AlbumText is the title and blurb of an album in one language.
+type AlbumText struct {
+	// Language is a BCP 47 language code, or "" for the default language of the collection.
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Blurb    string `json:"blurb,omitempty"`
+}
+
+// Album is the body of PUT /api/ingest/albums/ALBUM.
+type Album struct {
+	// Language is the BCP 47 code of the default language of the collection, in
+	// which the text with Language "" is written, if known.
+	Language string      `json:"language,omitempty"`
+	Texts    []AlbumText `json:"texts"`
+	// SortOrder is a sort order name (see metadata.CommonMetadata.SortOrder).
+	// Default is "taken".
+	SortOrder string   `json:"sort_order,omitempty"`
+	Tags      []string `json:"tags"`
+	Aliases   []string `json:"aliases"`
+	// Access is the list of access keys granted access to the album. Empty means public.
+	Access []string `json:"access"`
+	// TitlePhoto and HighlightPhoto are source filenames of media items of the
+	// album. Filenames of media items not (yet) published are ignored.
+	TitlePhoto     string `json:"title_photo,omitempty"`
+	HighlightPhoto string `json:"highlight_photo,omitempty"`
+}
+
+// MediaText is the title and caption of a media item in one language.
+type MediaText struct {
+	// Language is a BCP 47 language code, or "" for the default language of the collection.
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+// Rendition describes an uploaded rendition of a media item.
+type Rendition struct {
+	// ContentHash is the hex SHA-256 hash of the rendition.
+	ContentHash string `json:"content_hash"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	MaxDim      int    `json:"max_dim"`
+}
+
+// Media types.
+const (
+	Photo = "photo"
+	Video = "video"
+)
+
+// Media is the body of PUT /api/ingest/media/ALBUM/FILE. Optional EXIF fields
+// are omitted when unknown.
+type Media struct {
+	// Type is Photo or Video.
+	Type        string `json:"type"`
+	DisplayName string `json:"display_name"`
+	// SourceHash and SourceSize are the hex SHA-256 hash and the size of the source file.
+	SourceHash string `json:"source_hash"`
+	SourceSize int64  `json:"source_size"`
+	// MetadataDigest is an opaque digest of the metadata the client published
+	// with the media item (e.g., its texts, tags and access), which Source returns
+	// so that the client can tell whether the metadata changed.
+	MetadataDigest string      `json:"metadata_digest,omitempty"`
+	MTime          time.Time   `json:"mtime"`
+	ExifTime       *time.Time  `json:"exif_time,omitempty"`
+	Latitude       *float64    `json:"latitude,omitempty"`
+	Longitude      *float64    `json:"longitude,omitempty"`
+	Camera         string      `json:"camera,omitempty"`
+	Lens           string      `json:"lens,omitempty"`
+	FocalLength    *float64    `json:"focal_length,omitempty"`
+	ExposureTime   *float64    `json:"exposure_time,omitempty"`
+	Aperture       *float64    `json:"aperture,omitempty"`
+	ISO            *int        `json:"iso,omitempty"`
+	Flash          *int        `json:"flash,omitempty"`
+	Portrait       bool        `json:"portrait"`
+	Texts          []MediaText `json:"texts"`
+	Tags           []string    `json:"tags"`
+	// Access is the list of access keys to which the media item is restricted.
+	// Empty means the access of its album.
+	Access     []string    `json:"access"`
+	Renditions []Rendition `json:"renditions"`
+}
+
+// UploadRequest is the body of POST /api/ingest/uploads.
+type UploadRequest struct {
+	// Hash is the hex SHA-256 hash of the blob.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// UploadStatus describes a resumable upload session.
+type UploadStatus struct {
+	// ID identifies the session. Empty if Complete.
+	ID   string `json:"id,omitempty"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// Received is the number of bytes received so far, i.e., the offset of the next chunk.
+	Received int64 `json:"received"`
+	// Complete is true if the blob is already stored, so nothing needs to be uploaded.
+	Complete bool `json:"complete,omitempty"`
+}
+
+// Source is an element of the response of GET /api/ingest/sources.
+type Source struct {
+	Album    string `json:"album"`
+	Filename string `json:"filename"`
+	// Hash and Size are the hex SHA-256 hash and the size of the source file.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// MetadataDigest is the Media.MetadataDigest of the media item.
+	MetadataDigest string `json:"metadata_digest,omitempty"`
+}
+
+// ValidHash reports whether s is a (lowercase) hex SHA-256 hash.
+func ValidHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
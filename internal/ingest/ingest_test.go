@@ -0,0 +1,52 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidHash(t *testing.T) {
+	valid := strings.Repeat("0123456789abcdef", 4)
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{valid, true},
+		{strings.ToUpper(valid), false},
+		{valid[1:], false},
+		{valid + "0", false},
+		{strings.Repeat("g", 64), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidHash(tt.s); got != tt.want {
+			t.Errorf("ValidHash(%q) = %t, want %t", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		url, token string
+		wantErr    bool
+	}{
+		{"https://photos.example.com", "t", false},
+		{"http://localhost:8080/lbx/", "t", false},
+		{"photos.example.com", "t", true},
+		{"ftp://photos.example.com", "t", true},
+		{"https://photos.example.com", "", true},
+	}
+	for _, tt := range tests {
+		_, err := NewClient(tt.url, tt.token, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewClient(%q, %q) error = %v, wantErr %t", tt.url, tt.token, err, tt.wantErr)
+		}
+	}
+	c, _ := NewClient("http://localhost:8080/lbx/", "t", nil)
+	if got := c.base.String(); got != "http://localhost:8080/lbx" {
+		t.Errorf("base URL = %s, want http://localhost:8080/lbx", got)
+	}
+	if got := escapePath("europe/été 2019/a#1.jpg"); got != "europe/%C3%A9t%C3%A9%202019/a%231.jpg" {
+		t.Errorf("escapePath() = %s", got)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+// Access keys are presented in the "key" query parameter or in the keyCookie
+// cookie; a key given in the query is also stored in the cookie, so that it keeps
+// working as the viewer navigates. Share link tokens are presented in the "share"
+// query parameter (e.g., https://photos.example.com/?share=TOKEN). Opening a share
+// link counts as a view and starts a session (see repo.RedeemShareLink), whose ID
+// is stored in the shareCookie cookie. Requests of a viewer with a session of the
+// link, with or without the token in the query, do not count as views. The token
+// itself is never accepted in the cookie, which would bypass the view limit.
+const (
+	keyParam    = "key"
+	keyCookie   = "lbx_key"
+	shareParam  = "share"
+	shareCookie = "lbx_share"
+)
+
+// setCookie stores a credential in a cookie.
+func setCookie(w http.ResponseWriter, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// credential returns the value of a credential from the query or, failing that,
+// from a cookie, and whether it is new to the viewer: it came from the query and
+// differs from the cookie.
+func credential(r *http.Request, param, cookie string) (string, bool) {
+	var stored string
+	if c, err := r.Cookie(cookie); err == nil {
+		stored = c.Value
+	}
+	if v := r.URL.Query().Get(param); v != "" && v != stored {
+		return v, true
+	}
+	return stored, false
+}
+
+// viewer returns the viewer of a request. An unknown, expired, revoked or
+// exhausted share link or session is ignored, exactly as if none had been
+// presented.
+func (s *Server) viewer(w http.ResponseWriter, r *http.Request) (repo.Viewer, error) {
+	var v repo.Viewer
+	key, isNew := credential(r, keyParam, keyCookie)
+	if isNew {
+		setCookie(w, keyCookie, key)
+	}
+	v.Key = key
+	token := r.URL.Query().Get(shareParam)
+	if c, err := r.Cookie(shareCookie); err == nil {
+		l, err := s.repo.ShareLinkBySession(c.Value, s.now())
+		if err == nil && (token == "" || token == l.Token) {
+			v.Share = l
+			return v, nil
+		} else if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			return v, err
+		}
+	}
+	if token == "" {
+		return v, nil
+	}
+	l, session, err := s.repo.RedeemShareLink(token, s.now())
+	if errors.Is(err, repo.ErrNotFound) {
+		return v, nil
+	} else if err != nil {
+		return v, err
+	}
+	setCookie(w, shareCookie, session)
+	v.Share = l
+	return v, nil
+}
+
+// notFound writes a 404 response. Anything the viewer of a request cannot see is
+// reported as not found rather than forbidden, so that private paths do not leak.
+func notFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+// seedAccess populates the server with:
+//
+//	public:       no grants; media p1 (rendition hp1)
+//	private:      granted to alice, alias "secret"; media q1 (rendition hq1)
+//	family/xmas:  granted to fam (as if inherited from family/) and friend; media
+//	              x1, x2 (x2 restricted to friend; rendition hx2), x3 (x3 restricted
+//	              to stranger; rendition hx3)
+//
+// and returns the media IDs by name.
+func (ts *testServer) seedAccess(t *testing.T) map[string]int64 {
+	t.Helper()
+	ids := map[string]int64{}
+	for _, a := range []struct {
+		album  repo.Album
+		media  []string
+		access map[string][]string
+	}{
+		{repo.Album{Path: "public"}, []string{"p1"}, nil},
+		{repo.Album{Path: "private", Access: []string{"alice"}, Aliases: []string{"secret"}}, []string{"q1"}, nil},
+		{repo.Album{Path: "family/xmas", Access: []string{"fam", "friend"}}, []string{"x1", "x2", "x3"},
+			map[string][]string{"x2": {"friend"}, "x3": {"stranger"}}},
+	} {
+		if err := ts.repo.UpsertAlbum(&a.album); err != nil {
+			t.Fatalf("UpsertAlbum(%s) error = %v", a.album.Path, err)
+		}
+		for _, name := range a.media {
+			m := &repo.Media{AlbumID: a.album.ID, DisplayName: name, SourceFilename: name + ".jpg", MTime: time.Unix(0, 0), Access: a.access[name]}
+			if err := ts.repo.UpsertMedia(m); err != nil {
+				t.Fatalf("UpsertMedia(%s) error = %v", name, err)
+			}
+			ids[name] = m.ID
+			hash := "h" + name
+			if err := ts.store.Put(hash, strings.NewReader(name), int64(len(name))); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if err := ts.repo.ReplaceBlobs(m.ID, []repo.Blob{{ContentHash: hash, ObjectKey: hash, Width: 1, Height: 1, MaxDim: 1}}); err != nil {
+				t.Fatalf("ReplaceBlobs() error = %v", err)
+			}
+		}
+	}
+	return ids
+}
+
+func TestAccess(t *testing.T) {
+	ts := newTestServer(t)
+	ids := ts.seedAccess(t)
+	media := func(name string) string { return "/api/media/" + strconv.FormatInt(ids[name], 10) }
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		cookie     bool // Send the key as a cookie instead of a query parameter.
+		wantStatus int
+	}{
+		{"public album, no key", "/api/albums/public", "", false, http.StatusOK},
+		{"private album, no key", "/api/albums/private", "", false, http.StatusNotFound},
+		{"private alias, no key", "/api/albums/secret", "", false, http.StatusNotFound},
+		{"private album, wrong key", "/api/albums/private", "fam", false, http.StatusNotFound},
+		{"private album, key", "/api/albums/private", "alice", false, http.StatusOK},
+		{"private alias, key", "/api/albums/secret", "alice", false, http.StatusOK},
+		{"private album, cookie", "/api/albums/private", "alice", true, http.StatusOK},
+		{"inherited album, no key", "/api/albums/family/xmas", "", false, http.StatusNotFound},
+		{"inherited album, key", "/api/albums/family/xmas", "fam", false, http.StatusOK},
+		{"album, own key", "/api/albums/family/xmas", "friend", false, http.StatusOK},
+		{"album, media key", "/api/albums/family/xmas", "stranger", false, http.StatusNotFound},
+		{"private folder, no key", "/api/folders/family", "", false, http.StatusNotFound},
+		{"private folder, key", "/api/folders/family", "fam", false, http.StatusOK},
+		{"private folder, album key", "/api/folders/family", "friend", false, http.StatusOK},
+		{"private folder, media key", "/api/folders/family", "stranger", false, http.StatusNotFound},
+		{"private folder, wrong key", "/api/folders/family", "alice", false, http.StatusNotFound},
+		{"public media, no key", media("p1"), "", false, http.StatusOK},
+		{"private media, no key", media("q1"), "", false, http.StatusNotFound},
+		{"private media, key", media("q1"), "alice", false, http.StatusOK},
+		{"inherited media, key", media("x1"), "fam", false, http.StatusOK},
+		{"unrestricted media, album key", media("x1"), "friend", false, http.StatusOK},
+		{"restricted media, own key", media("x2"), "friend", false, http.StatusOK},
+		{"restricted media, other album key", media("x2"), "fam", false, http.StatusNotFound},
+		{"restricted media, media key only", media("x3"), "stranger", false, http.StatusNotFound},
+		{"public blob, no key", "/api/blobs/hp1", "", false, http.StatusOK},
+		{"private blob, no key", "/api/blobs/hq1", "", false, http.StatusNotFound},
+		{"private blob, key", "/api/blobs/hq1", "alice", true, http.StatusOK},
+		{"restricted blob, own key", "/api/blobs/hx2", "friend", true, http.StatusOK},
+		{"restricted blob, other album key", "/api/blobs/hx2", "fam", true, http.StatusNotFound},
+		{"restricted blob, media key only", "/api/blobs/hx3", "stranger", true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if tt.key != "" && !tt.cookie {
+				path += "?key=" + tt.key
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: keyCookie, Value: tt.key})
+			}
+			w := httptest.NewRecorder()
+			ts.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET %s = %d, want %d", path, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAccessListings(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedAccess(t)
+
+	tests := []struct {
+		key        string
+		wantAlbums []string // Albums in the tree.
+		wantXmas   []string // Media of family/xmas, if visible.
+	}{
+		{"", []string{"public"}, nil},
+		{"alice", []string{"private", "public"}, nil},
+		{"fam", []string{"family/xmas", "public"}, []string{"x1.jpg"}},
+		{"friend", []string{"family/xmas", "public"}, []string{"x1.jpg", "x2.jpg"}},
+		{"stranger", []string{"public"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run("key="+tt.key, func(t *testing.T) {
+			suffix := ""
+			if tt.key != "" {
+				suffix = "?key=" + tt.key
+			}
+			var tree folderResponse
+			if w := ts.get(t, "/api/tree"+suffix, &tree); w.Code != http.StatusOK {
+				t.Fatalf("GET /api/tree = %d", w.Code)
+			}
+			var got []string
+			var walk func(f *folderResponse)
+			walk = func(f *folderResponse) {
+				for _, sub := range f.Folders {
+					walk(sub)
+				}
+				for _, a := range f.Albums {
+					got = append(got, a.Path)
+				}
+			}
+			walk(&tree)
+			if strings.Join(got, ",") != strings.Join(tt.wantAlbums, ",") {
+				t.Errorf("GET /api/tree albums = %v, want %v", got, tt.wantAlbums)
+			}
+			if tt.wantXmas == nil {
+				if len(tree.Folders) != 0 {
+					t.Errorf("GET /api/tree folders = %+v, want none", tree.Folders)
+				}
+				return
+			}
+			var ar albumResponse
+			if w := ts.get(t, "/api/albums/family/xmas"+suffix, &ar); w.Code != http.StatusOK {
+				t.Fatalf("GET /api/albums/family/xmas = %d", w.Code)
+			}
+			got = nil
+			for _, m := range ar.Media {
+				got = append(got, m.Filename)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantXmas, ",") || ar.MediaCount != len(tt.wantXmas) {
+				t.Errorf("GET /api/albums/family/xmas media = %v (count %d), want %v", got, ar.MediaCount, tt.wantXmas)
+			}
+		})
+	}
+}
+
+func TestAccessKeyCookie(t *testing.T) {
+	ts := newTestServer(t)
+	w := httptest.NewRecorder()
+	ts.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tree?key=alice", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != keyCookie || cookies[0].Value != "alice" || !cookies[0].HttpOnly {
+		t.Errorf("GET /api/tree?key=alice cookies = %+v", cookies)
+	}
+}
@@ -0,0 +1,107 @@
+// Package api implements the lbxd HTTP API.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// Pagination limits of media listings.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Server serves the lbxd API.
+type Server struct {
+	repo  *repo.Repo
+	store storage.Storage
+	mux   *http.ServeMux
+	// now returns the current time, for checking share link expiry.
+	now func() time.Time
+}
+
+// New returns a server that reads metadata from r and renditions from store.
+func New(r *repo.Repo, store storage.Storage) *Server {
+	s := &Server{repo: r, store: store, mux: http.NewServeMux(), now: time.Now}
+	s.mux.HandleFunc("GET /api/tree", s.handleTree)
+	s.mux.HandleFunc("GET /api/folders/{path...}", s.handleFolder)
+	s.mux.HandleFunc("GET /api/albums/{path...}", s.handleAlbum)
+	s.mux.HandleFunc("GET /api/media/{id}", s.handleMedia)
+	s.mux.HandleFunc("GET /api/blobs/{hash}", s.handleBlob)
+	s.mux.HandleFunc("GET /api/ingest/sources", s.admin(s.handleSources))
+	s.mux.HandleFunc("GET /api/ingest/albums", s.admin(s.handleAlbums))
+	s.mux.HandleFunc("PUT /api/ingest/albums/{path...}", s.admin(s.handlePutAlbum))
+	s.mux.HandleFunc("DELETE /api/ingest/albums/{path...}", s.admin(s.handleDeleteAlbum))
+	s.mux.HandleFunc("HEAD /api/ingest/blobs/{hash}", s.admin(s.handleHasBlob))
+	s.mux.HandleFunc("PUT /api/ingest/blobs/{hash}", s.admin(s.handlePutBlob))
+	s.mux.HandleFunc("PUT /api/ingest/media/{path...}", s.admin(s.handlePutMedia))
+	s.mux.HandleFunc("DELETE /api/ingest/media/{path...}", s.admin(s.handleDeleteMedia))
+	s.mux.HandleFunc("POST /api/ingest/uploads", s.admin(s.handleCreateUpload))
+	s.mux.HandleFunc("GET /api/ingest/uploads/{id}", s.admin(s.handleUploadStatus))
+	s.mux.HandleFunc("PUT /api/ingest/uploads/{id}", s.admin(s.handlePutChunk))
+	s.mux.HandleFunc("POST /api/ingest/uploads/{id}/finalize", s.admin(s.handleFinalizeUpload))
+	s.mux.HandleFunc("DELETE /api/ingest/uploads/{id}", s.admin(s.handleAbortUpload))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError writes an error response. Not-found errors map to 404; other
+// errors are logged and map to 500 without exposing details.
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, storage.ErrNotFound) {
+		notFound(w)
+		return
+	}
+	log.Printf("internal error: %v", err)
+	writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "internal error"})
+}
+
+// badRequest writes a 400 response.
+func badRequest(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, errorResponse{Error: msg})
+}
+
+// parsePage reads the "offset" and "limit" query parameters.
+func parsePage(r *http.Request) (repo.Page, bool) {
+	page := repo.Page{Limit: defaultPageSize}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, false
+		}
+		page.Offset = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return page, false
+		}
+		page.Limit = n
+	}
+	return page, true
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/db"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// testServer is a server over a temporary database and local storage.
+type testServer struct {
+	*Server
+	repo  *repo.Repo
+	store storage.Storage
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	conn, err := db.Open(filepath.Join(dir, "lbx.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, _, err := db.Migrate(conn); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	store, err := storage.NewLocal(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	r := repo.New(conn)
+	return &testServer{Server: New(r, store), repo: r, store: store}
+}
+
+// seed populates the server with:
+//
+//	europe/paris (alias "paris-2019", sorted by name): a.jpg, b.jpg, c.jpg
+//	europe/rome: no media
+//	misc
+func (ts *testServer) seed(t *testing.T) *repo.Album {
+	t.Helper()
+	paris := &repo.Album{
+		Path:      "europe/paris",
+		SortOrder: 0,
+		Texts:     []repo.AlbumText{{Language: "en", Title: "Paris", Blurb: "Spring 2019"}},
+		Aliases:   []string{"paris-2019"},
+		Tags:      []string{"travel"},
+	}
+	for _, a := range []*repo.Album{paris, {Path: "europe/rome"}, {Path: "misc"}} {
+		if err := ts.repo.UpsertAlbum(a); err != nil {
+			t.Fatalf("UpsertAlbum(%s) error = %v", a.Path, err)
+		}
+	}
+	for _, name := range []string{"c", "a", "b"} {
+		m := &repo.Media{
+			AlbumID:        paris.ID,
+			DisplayName:    name,
+			SourceFilename: name + ".jpg",
+			MTime:          time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+			Camera:         "Canon EOS R",
+		}
+		if err := ts.repo.UpsertMedia(m); err != nil {
+			t.Fatalf("UpsertMedia(%s) error = %v", m.SourceFilename, err)
+		}
+	}
+	return paris
+}
+
+// get performs a GET request and decodes the JSON response into v, if non-nil.
+func (ts *testServer) get(t *testing.T, path string, v any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ts.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: invalid JSON %q: %v", path, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+func TestTree(t *testing.T) {
+	ts := newTestServer(t)
+
+	var empty folderResponse
+	if w := ts.get(t, "/api/tree", &empty); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/tree on empty collection = %d", w.Code)
+	}
+	if len(empty.Folders) != 0 || len(empty.Albums) != 0 {
+		t.Errorf("GET /api/tree on empty collection = %+v", empty)
+	}
+
+	ts.seed(t)
+	var tree folderResponse
+	if w := ts.get(t, "/api/tree", &tree); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/tree = %d", w.Code)
+	}
+	if len(tree.Folders) != 1 || tree.Folders[0].Path != "europe" {
+		t.Fatalf("GET /api/tree folders = %+v", tree.Folders)
+	}
+	if len(tree.Albums) != 1 || tree.Albums[0].Path != "misc" {
+		t.Errorf("GET /api/tree albums = %+v", tree.Albums)
+	}
+	europe := tree.Folders[0]
+	if len(europe.Albums) != 2 || europe.Albums[0].Path != "europe/paris" || europe.Albums[1].Path != "europe/rome" {
+		t.Errorf("GET /api/tree europe albums = %+v", europe.Albums)
+	}
+	if europe.Albums[0].Texts[0].Title != "Paris" {
+		t.Errorf("GET /api/tree paris texts = %+v", europe.Albums[0].Texts)
+	}
+}
+
+func TestFolder(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seed(t)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantPath   string
+		wantAlbums []string
+	}{
+		{"/api/folders/", http.StatusOK, "", []string{"misc"}},
+		{"/api/folders/europe", http.StatusOK, "europe", []string{"europe/paris", "europe/rome"}},
+		{"/api/folders/europe/", http.StatusOK, "europe", []string{"europe/paris", "europe/rome"}},
+		{"/api/folders/asia", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var fr folderResponse
+			w := ts.get(t, tt.path, &fr)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if fr.Path != tt.wantPath {
+				t.Errorf("GET %s path = %q, want %q", tt.path, fr.Path, tt.wantPath)
+			}
+			var got []string
+			for _, a := range fr.Albums {
+				got = append(got, a.Path)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantAlbums, ",") {
+				t.Errorf("GET %s albums = %v, want %v", tt.path, got, tt.wantAlbums)
+			}
+			// Folder listings are one level deep.
+			for _, f := range fr.Folders {
+				if f.Albums != nil || f.Folders != nil {
+					t.Errorf("GET %s subfolder %s is expanded", tt.path, f.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestAlbum(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seed(t)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantMedia  []string
+		wantCount  int
+	}{
+		{"/api/albums/europe/paris", http.StatusOK, []string{"a.jpg", "b.jpg", "c.jpg"}, 3},
+		{"/api/albums/paris-2019", http.StatusOK, []string{"a.jpg", "b.jpg", "c.jpg"}, 3},
+		{"/api/albums/europe/paris?offset=1&limit=1", http.StatusOK, []string{"b.jpg"}, 3},
+		{"/api/albums/europe/paris?offset=5", http.StatusOK, nil, 3},
+		{"/api/albums/europe/rome", http.StatusOK, nil, 0},
+		{"/api/albums/europe/paris?limit=0", http.StatusBadRequest, nil, 0},
+		{"/api/albums/europe/paris?limit=100000", http.StatusBadRequest, nil, 0},
+		{"/api/albums/europe/paris?offset=x", http.StatusBadRequest, nil, 0},
+		{"/api/albums/europe", http.StatusNotFound, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var ar albumResponse
+			w := ts.get(t, tt.path, &ar)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				var er errorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil || er.Error == "" {
+					t.Errorf("GET %s error body = %q", tt.path, w.Body.String())
+				}
+				return
+			}
+			var got []string
+			for _, m := range ar.Media {
+				got = append(got, m.Filename)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantMedia, ",") {
+				t.Errorf("GET %s media = %v, want %v", tt.path, got, tt.wantMedia)
+			}
+			if ar.MediaCount != tt.wantCount {
+				t.Errorf("GET %s media_count = %d, want %d", tt.path, ar.MediaCount, tt.wantCount)
+			}
+		})
+	}
+
+	var ar albumResponse
+	ts.get(t, "/api/albums/paris-2019", &ar)
+	if ar.Path != "europe/paris" || ar.SortOrder != "name" || ar.Limit != defaultPageSize ||
+		len(ar.Aliases) != 1 || len(ar.Tags) != 1 || ar.Texts[0].Blurb != "Spring 2019" {
+		t.Errorf("GET /api/albums/paris-2019 = %+v", ar)
+	}
+	if ar.Media[0].Exif != nil {
+		t.Errorf("album listing includes EXIF details: %+v", ar.Media[0].Exif)
+	}
+}
+
+func TestMedia(t *testing.T) {
+	ts := newTestServer(t)
+	paris := ts.seed(t)
+	media, err := ts.repo.ListMedia(paris.ID, 0, repo.Page{})
+	if err != nil {
+		t.Fatalf("ListMedia() error = %v", err)
+	}
+	m := media[0]
+	if err := ts.repo.ReplaceBlobs(m.ID, []repo.Blob{
+		{ContentHash: "h640", ObjectKey: "renditions/h640", Width: 640, Height: 480, MaxDim: 640},
+		{ContentHash: "h256", ObjectKey: "renditions/h256", Width: 256, Height: 192, MaxDim: 256},
+	}); err != nil {
+		t.Fatalf("ReplaceBlobs() error = %v", err)
+	}
+
+	var mr mediaResponse
+	path := "/api/media/" + strconv.FormatInt(m.ID, 10)
+	if w := ts.get(t, path, &mr); w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d", path, w.Code)
+	}
+	if mr.ID != m.ID || mr.Album != "europe/paris" || mr.Type != "photo" || mr.Filename != "a.jpg" {
+		t.Errorf("GET %s = %+v", path, mr)
+	}
+	if mr.Exif == nil || mr.Exif.Camera != "Canon EOS R" {
+		t.Errorf("GET %s exif = %+v", path, mr.Exif)
+	}
+	if len(mr.Renditions) != 2 || mr.Renditions[0].URL != "/api/blobs/h256" || mr.Renditions[1].MaxDim != 640 {
+		t.Errorf("GET %s renditions = %+v", path, mr.Renditions)
+	}
+
+	for _, path := range []string{"/api/media/999", "/api/media/x"} {
+		if w := ts.get(t, path, nil); w.Code == http.StatusOK {
+			t.Errorf("GET %s = %d, want error", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// handleBlob serves GET /api/blobs/HASH: the contents of a rendition, which is
+// visible if any media item using it is. Range requests and conditional requests
+// are supported. Renditions are addressed by content hash, so they never change
+// and may be cached indefinitely (only privately unless they are public).
+func (s *Server) handleBlob(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	blobs, err := s.repo.BlobsByHash(hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	viewer, err := s.viewer(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var b *repo.Blob
+	contentType, public := "", false
+	for i := range blobs {
+		m, err := s.repo.MediaByID(blobs[i].MediaID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if ok, err := s.repo.MediaVisible(m, viewer); err != nil {
+			writeError(w, err)
+			return
+		} else if !ok {
+			continue
+		}
+		if b == nil {
+			b, contentType = &blobs[i], blobContentType(m, &blobs[i])
+		}
+		// Shared caches may only keep renditions that anyone may see.
+		if public, err = s.repo.MediaVisible(m, repo.Viewer{}); err != nil {
+			writeError(w, err)
+			return
+		} else if public {
+			break
+		}
+	}
+	if b == nil {
+		notFound(w)
+		return
+	}
+	info, err := s.store.Stat(b.ObjectKey)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	obj := &objectReader{store: s.store, key: b.ObjectKey, size: info.Size}
+	defer obj.Close()
+	w.Header().Set("Content-Type", contentType)
+	if public {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	}
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", info.ModTime, obj)
+}
+
+// blobContentType returns the content type of a blob of a media item: JPEG for
+// renditions, or that of the source file for the original of a video (which has
+// no dimensions).
+func blobContentType(m *repo.Media, b *repo.Blob) string {
+	if m.Type != repo.Video || b.MaxDim != 0 {
+		return "image/jpeg"
+	}
+	ext := strings.ToLower(path.Ext(m.SourceFilename))
+	if t, ok := videoTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// videoTypes maps the video extensions accepted by lbx (see metadata.IsVideoFile)
+// to content types, which the system MIME tables do not always include.
+var videoTypes = map[string]string{
+	".mp4": "video/mp4",
+	".m4v": "video/x-m4v",
+	".mov": "video/quicktime",
+	".avi": "video/x-msvideo",
+}
+
+// objectReader is an io.ReadSeeker over a stored object. It opens the object at
+// the current offset on the first read after a seek, so that http.ServeContent
+// only fetches the requested range.
+type objectReader struct {
+	store  storage.Storage
+	key    string
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.rc == nil {
+		rc, err := o.store.GetRange(o.key, o.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		o.rc = rc
+	}
+	n, err := o.rc.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if offset != o.offset {
+		o.Close()
+		o.offset = offset
+	}
+	return offset, nil
+}
+
+// Close closes the underlying reader, if open.
+func (o *objectReader) Close() error {
+	if o.rc == nil {
+		return nil
+	}
+	err := o.rc.Close()
+	o.rc = nil
+	return err
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+func TestBlob(t *testing.T) {
+	ts := newTestServer(t)
+	paris := ts.seed(t)
+	media, err := ts.repo.ListMedia(paris.ID, 0, repo.Page{})
+	if err != nil {
+		t.Fatalf("ListMedia() error = %v", err)
+	}
+	const data = "0123456789"
+	if err := ts.store.Put("renditions/h1", strings.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := ts.repo.ReplaceBlobs(media[0].ID, []repo.Blob{
+		{ContentHash: "h1", ObjectKey: "renditions/h1", Width: 10, Height: 10, MaxDim: 10},
+		{ContentHash: "h2", ObjectKey: "renditions/h2", Width: 20, Height: 20, MaxDim: 20},
+	}); err != nil {
+		t.Fatalf("ReplaceBlobs() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     [2]string
+		wantStatus int
+		wantBody   string
+	}{
+		{"full", "/api/blobs/h1", [2]string{}, http.StatusOK, data},
+		{"range", "/api/blobs/h1", [2]string{"Range", "bytes=2-4"}, http.StatusPartialContent, "234"},
+		{"suffix range", "/api/blobs/h1", [2]string{"Range", "bytes=-3"}, http.StatusPartialContent, "789"},
+		{"not modified", "/api/blobs/h1", [2]string{"If-None-Match", `"h1"`}, http.StatusNotModified, ""},
+		{"unknown hash", "/api/blobs/nope", [2]string{}, http.StatusNotFound, ""},
+		{"missing object", "/api/blobs/h2", [2]string{}, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header[0] != "" {
+				req.Header.Set(tt.header[0], tt.header[1])
+			}
+			w := httptest.NewRecorder()
+			ts.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("GET %s body = %q, want %q", tt.path, w.Body.String(), tt.wantBody)
+			}
+			if w.Code == http.StatusOK && w.Header().Get("Content-Type") != "image/jpeg" {
+				t.Errorf("GET %s Content-Type = %q", tt.path, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestBlobContentType(t *testing.T) {
+	tests := []struct {
+		typ      repo.MediaType
+		filename string
+		maxDim   int
+		want     string
+	}{
+		{repo.Photo, "a.tif", 256, "image/jpeg"},
+		{repo.Photo, "a.tif", 0, "image/jpeg"},
+		{repo.Video, "a.mp4", 256, "image/jpeg"},
+		{repo.Video, "a.MOV", 0, "video/quicktime"},
+		{repo.Video, "a.mp4", 0, "video/mp4"},
+		{repo.Video, "a.xyz", 0, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		m := &repo.Media{Type: tt.typ, SourceFilename: tt.filename}
+		if got := blobContentType(m, &repo.Blob{MaxDim: tt.maxDim}); got != tt.want {
+			t.Errorf("blobContentType(%d, %s, %d) = %s, want %s", tt.typ, tt.filename, tt.maxDim, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+// folder returns the folder f with its subfolders and albums visible according
+// to v, with album titles in the preferred languages langs. If recursive is true,
+// subfolders are expanded too.
+func (s *Server) folder(f *repo.Folder, v *repo.Visibility, langs []string, recursive bool) (*folderResponse, error) {
+	folders, albums, err := s.repo.ListChildren(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	fr := &folderResponse{Path: f.Path, Name: f.Name, Folders: []*folderResponse{}, Albums: []albumSummary{}}
+	for _, sub := range folders {
+		if !v.Folder(sub) {
+			continue
+		}
+		child := &folderResponse{Path: sub.Path, Name: sub.Name}
+		if recursive {
+			if child, err = s.folder(sub, v, langs, true); err != nil {
+				return nil, err
+			}
+		}
+		fr.Folders = append(fr.Folders, child)
+	}
+	for _, a := range albums {
+		if v.Album(a.ID) {
+			fr.Albums = append(fr.Albums, newAlbumSummary(a, langs))
+		}
+	}
+	return fr, nil
+}
+
+// rootFolder returns the root folder. An empty collection has no root folder
+// row; it is reported as an empty folder.
+func (s *Server) rootFolder() (*repo.Folder, error) {
+	f, err := s.repo.FolderByPath("")
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// handleTree serves GET /api/tree: the whole folder and album hierarchy.
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	s.serveFolder(w, r, "", true)
+}
+
+// handleFolder serves GET /api/folders/PATH: one level of the hierarchy.
+func (s *Server) handleFolder(w http.ResponseWriter, r *http.Request) {
+	s.serveFolder(w, r, strings.Trim(r.PathValue("path"), "/"), false)
+}
+
+// serveFolder serves the folder at path, hiding whatever the viewer of the
+// request cannot see.
+func (s *Server) serveFolder(w http.ResponseWriter, r *http.Request, path string, recursive bool) {
+	viewer, err := s.viewer(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	v, err := s.repo.VisibilityFor(viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var f *repo.Folder
+	if path == "" {
+		f, err = s.rootFolder()
+		if err == nil && f == nil {
+			writeJSON(w, http.StatusOK, &folderResponse{Folders: []*folderResponse{}, Albums: []albumSummary{}})
+			return
+		}
+	} else {
+		f, err = s.repo.FolderByPath(path)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !v.Folder(f) {
+		notFound(w)
+		return
+	}
+	fr, err := s.folder(f, v, preferredLanguages(w, r), recursive)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, fr)
+}
+
+// album returns the album at path, falling back to albums with path as an alias.
+func (s *Server) album(path string) (*repo.Album, error) {
+	a, err := s.repo.AlbumByPath(path)
+	if errors.Is(err, repo.ErrNotFound) {
+		return s.repo.AlbumByAlias(path)
+	}
+	return a, err
+}
+
+// handleAlbum serves GET /api/albums/PATH: album metadata and a page of its media
+// in the album's sort order. Media items restricted to other keys are hidden.
+func (s *Server) handleAlbum(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(r)
+	if !ok {
+		badRequest(w, "invalid offset or limit")
+		return
+	}
+	viewer, err := s.viewer(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a, err := s.album(strings.Trim(r.PathValue("path"), "/"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	visible, err := s.repo.AlbumVisible(a.ID, viewer)
+	if err != nil {
+		writeError(w, err)
+		return
+	} else if !visible {
+		notFound(w)
+		return
+	}
+	count, err := s.repo.CountVisibleMedia(a.ID, visible, viewer.Key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	media, err := s.repo.ListVisibleMedia(a.ID, a.SortOrder, visible, viewer.Key, page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	text := chooseAlbumText(a, preferredLanguages(w, r))
+	ar := &albumResponse{
+		Path:           a.Path,
+		Name:           a.Name,
+		Language:       text.Language,
+		Title:          text.Title,
+		Blurb:          text.Blurb,
+		Texts:          newAlbumTexts(a.Texts),
+		Aliases:        a.Aliases,
+		Tags:           a.Tags,
+		TitlePhoto:     a.TitlePhotoID,
+		HighlightPhoto: a.HighlightPhotoID,
+		MediaCount:     count,
+		Offset:         page.Offset,
+		Limit:          page.Limit,
+		Media:          []mediaResponse{},
+	}
+	if a.SortOrder >= 0 && a.SortOrder < len(sortOrderNames) {
+		ar.SortOrder = sortOrderNames[a.SortOrder]
+	}
+	for _, m := range media {
+		blobs, err := s.repo.Blobs(m.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		ar.Media = append(ar.Media, newMediaResponse(m, blobs, false))
+	}
+	writeJSON(w, http.StatusOK, ar)
+}
+
+// visibleMedia returns the media item with the given ID if the viewer of the
+// request may see it, and repo.ErrNotFound otherwise.
+func (s *Server) visibleMedia(w http.ResponseWriter, r *http.Request, id int64) (*repo.Media, error) {
+	viewer, err := s.viewer(w, r)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.repo.MediaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.MediaVisible(m, viewer)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, repo.ErrNotFound
+	}
+	return m, nil
+}
+
+// handleMedia serves GET /api/media/ID: details of one media item.
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		badRequest(w, "invalid media id")
+		return
+	}
+	m, err := s.visibleMedia(w, r, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a, err := s.repo.AlbumByID(m.AlbumID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	blobs, err := s.repo.Blobs(m.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	mr := newMediaResponse(m, blobs, true)
+	mr.Album = a.Path
+	writeJSON(w, http.StatusOK, mr)
+}
@@ -0,0 +1,362 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// renditionPrefix prefixes the storage keys of uploaded renditions.
+const renditionPrefix = "renditions/"
+
+// maxJSONSize is the maximum size of a JSON request body.
+const maxJSONSize = 1 << 20
+
+// admin wraps an ingest API handler, requiring an admin token (see ingest).
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok {
+			_, err := s.repo.CheckAdminToken(token)
+			if err == nil {
+				h(w, r)
+				return
+			} else if !errors.Is(err, repo.ErrNotFound) {
+				writeError(w, err)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lbx"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid admin token"})
+	}
+}
+
+// readJSON decodes the JSON body of a request into v, rejecting unknown fields.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		badRequest(w, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// validPath reports whether path is a valid album path or filename: a non-empty,
+// slash-separated relative path without empty, "." or ".." components.
+func validPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteUnreferenced deletes the stored objects of blobs no longer used by any
+// media item. Failures only leak storage, so they are logged rather than reported.
+func (s *Server) deleteUnreferenced(blobs []repo.Blob) {
+	unused, err := s.repo.UnreferencedBlobs(blobs)
+	if err != nil {
+		log.Printf("failed to find unreferenced renditions: %v", err)
+		return
+	}
+	for _, b := range unused {
+		if err := s.store.Delete(b.ObjectKey); err != nil {
+			log.Printf("failed to delete rendition %s: %v", b.ObjectKey, err)
+		}
+	}
+}
+
+// handleSources serves GET /api/ingest/sources.
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := s.repo.Sources()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	l := []ingest.Source{}
+	for _, src := range sources {
+		l = append(l, ingest.Source{
+			Album: src.Album, Filename: src.Filename, Hash: src.Hash, Size: src.Size, MetadataDigest: src.MetadataDigest,
+		})
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// handleAlbums serves GET /api/ingest/albums.
+func (s *Server) handleAlbums(w http.ResponseWriter, r *http.Request) {
+	paths, err := s.repo.AlbumPaths()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, paths)
+}
+
+// handlePutAlbum serves PUT /api/ingest/albums/ALBUM.
+func (s *Server) handlePutAlbum(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	if !validPath(path) {
+		badRequest(w, "invalid album path")
+		return
+	}
+	var in ingest.Album
+	if !readJSON(w, r, &in) {
+		return
+	}
+	sortOrder := slices.Index(sortOrderNames, in.SortOrder)
+	if in.SortOrder == "" {
+		sortOrder = slices.Index(sortOrderNames, "taken")
+	} else if sortOrder < 0 {
+		badRequest(w, "invalid sort order "+in.SortOrder)
+		return
+	}
+	a := &repo.Album{
+		Path:      path,
+		SortOrder: sortOrder,
+		Language:  in.Language,
+		Texts:     []repo.AlbumText{},
+		Aliases:   in.Aliases,
+		Tags:      in.Tags,
+		Access:    in.Access,
+	}
+	for _, t := range in.Texts {
+		a.Texts = append(a.Texts, repo.AlbumText{Language: t.Language, Title: t.Title, Blurb: t.Blurb})
+	}
+	// Title and highlight photos can only refer to media of an existing album.
+	if old, err := s.repo.AlbumByPath(path); err == nil {
+		if a.TitlePhotoID, err = s.mediaID(old.ID, in.TitlePhoto); err == nil {
+			a.HighlightPhotoID, err = s.mediaID(old.ID, in.HighlightPhoto)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	if err := s.repo.UpsertAlbum(a); errors.Is(err, repo.ErrConflict) {
+		// Aliases of other albums are the client's fault.
+		badRequest(w, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteAlbum serves DELETE /api/ingest/albums/ALBUM.
+func (s *Server) handleDeleteAlbum(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	if !validPath(path) {
+		badRequest(w, "invalid album path")
+		return
+	}
+	deleted, err := s.repo.DeleteAlbum(path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.deleteUnreferenced(deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mediaID returns the ID of the media item of an album with the given source
+// filename, or 0 if filename is empty or there is no such media item.
+func (s *Server) mediaID(albumID int64, filename string) (int64, error) {
+	if filename == "" {
+		return 0, nil
+	}
+	m, err := s.repo.MediaBySource(albumID, filename)
+	if errors.Is(err, repo.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return m.ID, nil
+}
+
+// handleHasBlob serves HEAD /api/ingest/blobs/HASH.
+func (s *Server) handleHasBlob(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !ingest.ValidHash(hash) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.Stat(renditionPrefix + hash); errors.Is(err, storage.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else if err != nil {
+		log.Printf("internal error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handlePutBlob serves PUT /api/ingest/blobs/HASH.
+func (s *Server) handlePutBlob(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !ingest.ValidHash(hash) {
+		badRequest(w, "invalid content hash")
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ingest.MaxBlobSize))
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read rendition: %v", err))
+		return
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		badRequest(w, "content hash mismatch")
+		return
+	}
+	key := renditionPrefix + hash
+	// Content-addressed objects never change, so an existing object is kept.
+	if _, err := s.store.Stat(key); errors.Is(err, storage.ErrNotFound) {
+		if err := s.store.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mediaPath splits the path of a media item into album path and filename.
+func mediaPath(path string) (album, filename string, ok bool) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 || !validPath(path) {
+		return "", "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
+// handlePutMedia serves PUT /api/ingest/media/ALBUM/FILE.
+func (s *Server) handlePutMedia(w http.ResponseWriter, r *http.Request) {
+	albumPath, filename, ok := mediaPath(r.PathValue("path"))
+	if !ok {
+		badRequest(w, "invalid media path")
+		return
+	}
+	var in ingest.Media
+	if !readJSON(w, r, &in) {
+		return
+	}
+	m := &repo.Media{
+		DisplayName:    in.DisplayName,
+		SourceFilename: filename,
+		SourceHash:     in.SourceHash,
+		SourceSize:     in.SourceSize,
+		MetadataDigest: in.MetadataDigest,
+		MTime:          in.MTime,
+		Latitude:       in.Latitude,
+		Longitude:      in.Longitude,
+		Camera:         in.Camera,
+		Lens:           in.Lens,
+		FocalLength:    in.FocalLength,
+		ExposureTime:   in.ExposureTime,
+		Aperture:       in.Aperture,
+		ISO:            in.ISO,
+		Flash:          in.Flash,
+		Portrait:       in.Portrait,
+		Texts:          []repo.MediaText{},
+		Tags:           in.Tags,
+		Access:         in.Access,
+	}
+	switch in.Type {
+	case ingest.Photo:
+		m.Type = repo.Photo
+	case ingest.Video:
+		m.Type = repo.Video
+	default:
+		badRequest(w, "invalid media type "+in.Type)
+		return
+	}
+	if in.SourceHash != "" && !ingest.ValidHash(in.SourceHash) {
+		badRequest(w, "invalid source hash")
+		return
+	}
+	if in.ExifTime != nil {
+		m.ExifTime = *in.ExifTime
+	}
+	for _, t := range in.Texts {
+		m.Texts = append(m.Texts, repo.MediaText{Language: t.Language, Title: t.Title, Caption: t.Caption})
+	}
+	blobs := []repo.Blob{}
+	for _, rd := range in.Renditions {
+		if !ingest.ValidHash(rd.ContentHash) {
+			badRequest(w, "invalid content hash "+rd.ContentHash)
+			return
+		}
+		key := renditionPrefix + rd.ContentHash
+		if _, err := s.store.Stat(key); errors.Is(err, storage.ErrNotFound) {
+			writeJSON(w, http.StatusConflict, errorResponse{Error: "rendition " + rd.ContentHash + " not uploaded"})
+			return
+		} else if err != nil {
+			writeError(w, err)
+			return
+		}
+		blobs = append(blobs, repo.Blob{ContentHash: rd.ContentHash, ObjectKey: key, Width: rd.Width, Height: rd.Height, MaxDim: rd.MaxDim})
+	}
+
+	a, err := s.repo.AlbumByPath(albumPath)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "album " + albumPath + " not found"})
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	m.AlbumID = a.ID
+	old, err := s.repo.PutMedia(m, blobs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.deleteUnreferenced(old)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteMedia serves DELETE /api/ingest/media/ALBUM/FILE.
+func (s *Server) handleDeleteMedia(w http.ResponseWriter, r *http.Request) {
+	albumPath, filename, ok := mediaPath(r.PathValue("path"))
+	if !ok {
+		badRequest(w, "invalid media path")
+		return
+	}
+	a, err := s.repo.AlbumByPath(albumPath)
+	if errors.Is(err, repo.ErrNotFound) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	deleted, err := s.repo.DeleteMedia(a.ID, filename)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.deleteUnreferenced(deleted)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// newIngestClient starts an HTTP server for ts and returns an ingest client
+// authenticated with a new admin token.
+func newIngestClient(t *testing.T, ts *testServer) (*ingest.Client, *httptest.Server) {
+	t.Helper()
+	hs := httptest.NewServer(ts)
+	t.Cleanup(hs.Close)
+	token, err := ts.repo.CreateAdminToken("laptop")
+	if err != nil {
+		t.Fatalf("CreateAdminToken() error = %v", err)
+	}
+	c, err := ingest.NewClient(hs.URL, token, hs.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c, hs
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIngestAuth(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedAccess(t)
+	c, hs := newIngestClient(t, ts)
+	for _, auth := range []string{"", "Bearer ", "Bearer wrong", "Bearer family", "family"} {
+		req, _ := http.NewRequest(http.MethodGet, hs.URL+"/api/ingest/sources", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := hs.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("GET sources with %q = %d, want 401 with WWW-Authenticate", auth, resp.StatusCode)
+		}
+	}
+	// Viewer credentials don't grant access either.
+	resp, err := hs.Client().Get(hs.URL + "/api/ingest/sources?key=family")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET sources with access key = %d, want 401", resp.StatusCode)
+	}
+	// Revoked tokens are rejected.
+	if _, err := c.Sources(); err != nil {
+		t.Fatalf("Sources() error = %v", err)
+	}
+	if err := ts.repo.RevokeAdminToken("laptop"); err != nil {
+		t.Fatalf("RevokeAdminToken() error = %v", err)
+	}
+	if _, err := c.Sources(); err == nil {
+		t.Errorf("Sources() with revoked token error = nil, want error")
+	}
+}
+
+func TestIngestBlobs(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	data := []byte("rendition")
+	hash := hashOf(data)
+	if ok, err := c.HasBlob(hash); err != nil || ok {
+		t.Errorf("HasBlob() = %t, %v, want false", ok, err)
+	}
+	if err := c.PutBlob(hashOf([]byte("other")), data); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("PutBlob() with wrong hash error = %v, want mismatch", err)
+	}
+	if err := c.PutBlob("not-a-hash", data); err == nil {
+		t.Errorf("PutBlob() with invalid hash error = nil, want error")
+	}
+	// Uploads may be retried.
+	for range 2 {
+		if err := c.PutBlob(hash, data); err != nil {
+			t.Fatalf("PutBlob() error = %v", err)
+		}
+	}
+	if ok, err := c.HasBlob(hash); err != nil || !ok {
+		t.Errorf("HasBlob() = %t, %v, want true", ok, err)
+	}
+	if _, err := ts.store.Stat("renditions/" + hash); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+}
+
+func TestIngestMedia(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	small, large := []byte("small rendition"), []byte("large rendition")
+	renditions := []ingest.Rendition{
+		{ContentHash: hashOf(small), Width: 256, Height: 171, MaxDim: 256},
+		{ContentHash: hashOf(large), Width: 2048, Height: 1365, MaxDim: 2048},
+	}
+	mtime := time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)
+	m := &ingest.Media{
+		Type:           ingest.Photo,
+		DisplayName:    "IMG_1",
+		SourceHash:     hashOf([]byte("source")),
+		SourceSize:     6,
+		MetadataDigest: "d1",
+		MTime:          mtime,
+		Camera:         "Canon EOS R",
+		Texts:          []ingest.MediaText{{Language: "en", Title: "Eiffel Tower"}},
+		Tags:           []string{"tower"},
+		Renditions:     renditions,
+	}
+
+	// The album must exist.
+	if err := c.PutMedia("europe/paris", "IMG_1.jpg", m); err == nil {
+		t.Errorf("PutMedia() without album error = nil, want error")
+	}
+	album := &ingest.Album{
+		Texts:      []ingest.AlbumText{{Language: "en", Title: "Paris"}},
+		SortOrder:  "name",
+		Aliases:    []string{"paris"},
+		TitlePhoto: "IMG_1.jpg",
+	}
+	if err := c.PutAlbum("europe/paris", album); err != nil {
+		t.Fatalf("PutAlbum() error = %v", err)
+	}
+	// So must the renditions.
+	if err := c.PutMedia("europe/paris", "IMG_1.jpg", m); err == nil || !strings.Contains(err.Error(), "not uploaded") {
+		t.Errorf("PutMedia() without renditions error = %v, want not uploaded", err)
+	}
+	for _, data := range [][]byte{small, large} {
+		if err := c.PutBlob(hashOf(data), data); err != nil {
+			t.Fatalf("PutBlob() error = %v", err)
+		}
+	}
+	for range 2 {
+		if err := c.PutMedia("europe/paris", "IMG_1.jpg", m); err != nil {
+			t.Fatalf("PutMedia() error = %v", err)
+		}
+	}
+	// The title photo resolves once the media item exists.
+	if err := c.PutAlbum("europe/paris", album); err != nil {
+		t.Fatalf("PutAlbum() error = %v", err)
+	}
+	a, err := ts.repo.AlbumByPath("europe/paris")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	got, err := ts.repo.MediaBySource(a.ID, "IMG_1.jpg")
+	if err != nil {
+		t.Fatalf("MediaBySource() error = %v", err)
+	}
+	if a.TitlePhotoID != got.ID || a.SortOrder != 0 || !reflect.DeepEqual(a.Aliases, []string{"paris"}) {
+		t.Errorf("AlbumByPath() = %+v", a)
+	}
+	if got.DisplayName != "IMG_1" || got.Camera != "Canon EOS R" || !got.MTime.Equal(mtime) || got.SourceSize != 6 {
+		t.Errorf("MediaBySource() = %+v", got)
+	}
+	if blobs, err := ts.repo.Blobs(got.ID); err != nil || len(blobs) != 2 || blobs[0].ObjectKey != "renditions/"+hashOf(small) {
+		t.Errorf("Blobs() = %+v, %v", blobs, err)
+	}
+
+	sources, err := c.Sources()
+	if err != nil {
+		t.Fatalf("Sources() error = %v", err)
+	}
+	want := []ingest.Source{{Album: "europe/paris", Filename: "IMG_1.jpg", Hash: m.SourceHash, Size: 6, MetadataDigest: "d1"}}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("Sources() = %+v, want %+v", sources, want)
+	}
+
+	// Replacing the renditions deletes the unused ones.
+	m.Renditions = renditions[:1]
+	if err := c.PutMedia("europe/paris", "IMG_1.jpg", m); err != nil {
+		t.Fatalf("PutMedia() error = %v", err)
+	}
+	if _, err := ts.store.Stat("renditions/" + hashOf(large)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Stat() of replaced rendition error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting is idempotent and removes the renditions.
+	for range 2 {
+		if err := c.DeleteMedia("europe/paris", "IMG_1.jpg"); err != nil {
+			t.Fatalf("DeleteMedia() error = %v", err)
+		}
+	}
+	if _, err := ts.repo.MediaBySource(a.ID, "IMG_1.jpg"); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("MediaBySource() after delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := ts.store.Stat("renditions/" + hashOf(small)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Stat() of deleted rendition error = %v, want ErrNotFound", err)
+	}
+	if err := c.DeleteMedia("nowhere", "IMG_1.jpg"); err != nil {
+		t.Errorf("DeleteMedia() in missing album error = %v", err)
+	}
+
+	// Deleting an album is idempotent and removes its media items and their renditions.
+	if err := c.PutBlob(hashOf(small), small); err != nil {
+		t.Fatalf("PutBlob() error = %v", err)
+	}
+	if err := c.PutMedia("europe/paris", "IMG_1.jpg", m); err != nil {
+		t.Fatalf("PutMedia() error = %v", err)
+	}
+	if paths, err := c.Albums(); err != nil || !reflect.DeepEqual(paths, []string{"europe/paris"}) {
+		t.Errorf("Albums() = %v, %v, want [europe/paris]", paths, err)
+	}
+	for range 2 {
+		if err := c.DeleteAlbum("europe/paris"); err != nil {
+			t.Fatalf("DeleteAlbum() error = %v", err)
+		}
+	}
+	if paths, err := c.Albums(); err != nil || len(paths) != 0 {
+		t.Errorf("Albums() after delete = %v, %v, want none", paths, err)
+	}
+	if _, err := ts.store.Stat("renditions/" + hashOf(small)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Stat() of rendition of deleted album error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestIngestInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	if err := c.PutAlbum("paris", &ingest.Album{SortOrder: "random"}); err == nil {
+		t.Errorf("PutAlbum() with invalid sort order error = nil, want error")
+	}
+	if err := c.PutAlbum("paris", &ingest.Album{}); err != nil {
+		t.Fatalf("PutAlbum() error = %v", err)
+	}
+	// Aliases of other albums are reported to the client rather than as internal errors.
+	if err := c.PutAlbum("rome", &ingest.Album{Aliases: []string{"x"}}); err != nil {
+		t.Fatalf("PutAlbum() error = %v", err)
+	}
+	if err := c.PutAlbum("paris", &ingest.Album{Aliases: []string{"x"}}); err == nil || !strings.Contains(err.Error(), "already an alias of album rome") {
+		t.Errorf("PutAlbum() with alias of another album error = %v, want conflict", err)
+	}
+	for _, m := range []*ingest.Media{
+		{Type: "audio"},
+		{Type: ingest.Photo, SourceHash: "abc"},
+		{Type: ingest.Photo, Renditions: []ingest.Rendition{{ContentHash: "abc"}}},
+	} {
+		if err := c.PutMedia("paris", "a.jpg", m); err == nil {
+			t.Errorf("PutMedia(%+v) error = nil, want error", m)
+		}
+	}
+	if err := c.PutMedia("paris", "..", &ingest.Media{Type: ingest.Photo}); err == nil {
+		t.Errorf("PutMedia() with invalid filename error = nil, want error")
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+// preferredLanguages returns the languages requested by r, most preferred first:
+// the "lang" query parameter, if any, followed by the Accept-Language ranges in
+// decreasing order of quality. Since the response depends on the latter, it also
+// marks the response as varying with Accept-Language.
+func preferredLanguages(w http.ResponseWriter, r *http.Request) []string {
+	w.Header().Add("Vary", "Accept-Language")
+	var langs []string
+	if lang := strings.TrimSpace(r.URL.Query().Get("lang")); lang != "" {
+		langs = append(langs, lang)
+	}
+	return append(langs, parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language header
+// in decreasing order of quality. Ranges with quality 0, invalid qualities and
+// the wildcard are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var l []weighted
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.TrimSpace(lang)
+		q := 1.0
+		if params = strings.TrimSpace(params); params != "" {
+			v, ok := strings.CutPrefix(params, "q=")
+			if !ok {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		if lang == "" || lang == "*" || q == 0 {
+			continue
+		}
+		l = append(l, weighted{lang, q})
+	}
+	sort.SliceStable(l, func(i, j int) bool { return l[i].q > l[j].q })
+	langs := make([]string, len(l))
+	for i, w := range l {
+		langs[i] = w.lang
+	}
+	return langs
+}
+
+// bestLanguage returns the index in available of the language that best matches
+// prefs, or -1 if available is empty. The default language "" is matched as def,
+// its code, if known. Each preference is looked up in turn, first exactly and
+// then with trailing subtags removed (RFC 4647 lookup: "de-CH-1996" matches
+// "de-CH", then "de"). Failing that, a language with the same primary subtag as
+// any preference ("en-GB" for "en-US") is chosen. The default language is the
+// last resort, then the first available language.
+func bestLanguage(available []string, def string, prefs []string) int {
+	index := func(match func(lang string) bool) int {
+		for i, lang := range available {
+			if lang == "" {
+				lang = def
+			}
+			if match(lang) {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, pref := range prefs {
+		for p := pref; p != ""; p = truncateLanguage(p) {
+			if i := index(func(lang string) bool { return lang != "" && strings.EqualFold(lang, p) }); i >= 0 {
+				return i
+			}
+		}
+	}
+	for _, pref := range prefs {
+		primary, _, _ := strings.Cut(pref, "-")
+		if i := index(func(lang string) bool {
+			p, _, _ := strings.Cut(lang, "-")
+			return lang != "" && strings.EqualFold(p, primary)
+		}); i >= 0 {
+			return i
+		}
+	}
+	if i := slices.Index(available, ""); i >= 0 {
+		return i
+	}
+	if len(available) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// truncateLanguage removes the last subtag of a language tag, along with a
+// preceding single-character subtag (an extension or private use singleton).
+func truncateLanguage(tag string) string {
+	i := strings.LastIndex(tag, "-")
+	if i < 0 {
+		return ""
+	}
+	tag = tag[:i]
+	if i = strings.LastIndex(tag, "-"); i >= 0 && len(tag)-i == 2 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// chooseAlbumText returns the text of album a that best matches prefs, or the
+// zero text if the album has none.
+func chooseAlbumText(a *repo.Album, prefs []string) repo.AlbumText {
+	langs := make([]string, len(a.Texts))
+	for i, t := range a.Texts {
+		langs[i] = t.Language
+	}
+	if i := bestLanguage(langs, a.Language, prefs); i >= 0 {
+		return a.Texts[i]
+	}
+	return repo.AlbumText{}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"fr", []string{"fr"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de"}},
+		{"en;q=0.5, de, it;q=0.9", []string{"de", "it", "en"}},
+		{"en;q=0, de;q=x, it;level=1, pt;q=2, es", []string{"es"}},
+	}
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBestLanguage(t *testing.T) {
+	available := []string{"", "de", "en-GB", "pt-BR", "zh-Hant-TW"}
+	tests := []struct {
+		prefs []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"de"}, "de"},
+		{[]string{"DE-ch-1996"}, "de"},
+		{[]string{"en-GB"}, "en-GB"},
+		{[]string{"en-US"}, "en-GB"},
+		{[]string{"en"}, "en-GB"},
+		{[]string{"zh-Hant-TW-x-private"}, "zh-Hant-TW"},
+		{[]string{"fr", "pt"}, "pt-BR"},
+		// A lookup match on a less preferred language beats a primary subtag
+		// match on a more preferred one.
+		{[]string{"pt-PT", "de"}, "de"},
+		{[]string{"fr", "it"}, ""},
+	}
+	for _, tt := range tests {
+		if got := bestLanguage(available, "", tt.prefs); got < 0 || available[got] != tt.want {
+			t.Errorf("bestLanguage(%q) = %d, want %q", tt.prefs, got, tt.want)
+		}
+	}
+	// The default language is matched by its code, if known.
+	for _, prefs := range [][]string{{"it", "fr"}, {"fr-CH"}} {
+		if got := bestLanguage(available, "fr", prefs); got != 0 {
+			t.Errorf("bestLanguage(%q) with default fr = %d, want 0", prefs, got)
+		}
+	}
+	if got := bestLanguage(available, "fr", []string{"it", "de"}); got != 1 {
+		t.Errorf("bestLanguage() with default fr = %d, want 1", got)
+	}
+	if got := bestLanguage([]string{"de", "fr"}, "", []string{"it"}); got != 0 {
+		t.Errorf("bestLanguage() without default = %d, want 0", got)
+	}
+	if got := bestLanguage(nil, "", []string{"it"}); got != -1 {
+		t.Errorf("bestLanguage(nil) = %d, want -1", got)
+	}
+}
+
+func TestAlbumLanguage(t *testing.T) {
+	ts := newTestServer(t)
+	if err := ts.repo.UpsertAlbum(&repo.Album{
+		Path:     "paris",
+		Language: "en",
+		Texts: []repo.AlbumText{
+			{Title: "Paris", Blurb: "Spring 2019"},
+			{Language: "fr", Title: "Paris (fr)", Blurb: "Printemps 2019"},
+			{Language: "it", Title: "Parigi"},
+		},
+	}); err != nil {
+		t.Fatalf("UpsertAlbum() error = %v", err)
+	}
+
+	tests := []struct {
+		path           string
+		acceptLanguage string
+		wantLanguage   string
+		wantTitle      string
+		wantBlurb      string
+	}{
+		{"/api/albums/paris", "", "", "Paris", "Spring 2019"},
+		{"/api/albums/paris", "fr-CA, en;q=0.5", "fr", "Paris (fr)", "Printemps 2019"},
+		{"/api/albums/paris", "de, it;q=0.8", "it", "Parigi", ""},
+		{"/api/albums/paris", "ja", "", "Paris", "Spring 2019"},
+		// The default texts are in English.
+		{"/api/albums/paris", "en, fr;q=0.5", "", "Paris", "Spring 2019"},
+		{"/api/albums/paris", "it-CH, en-GB;q=0.9", "it", "Parigi", ""},
+		{"/api/albums/paris", "de, en-GB;q=0.9, fr;q=0.8", "", "Paris", "Spring 2019"},
+		{"/api/albums/paris?lang=it", "fr", "it", "Parigi", ""},
+		{"/api/albums/paris?lang=ja", "fr", "fr", "Paris (fr)", "Printemps 2019"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		ts.ServeHTTP(w, r)
+		var ar albumResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &ar); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %q", tt.path, w.Code, w.Body.String())
+		}
+		if ar.Language != tt.wantLanguage || ar.Title != tt.wantTitle || ar.Blurb != tt.wantBlurb {
+			t.Errorf("GET %s (Accept-Language %q) = %q %q %q, want %q %q %q", tt.path, tt.acceptLanguage,
+				ar.Language, ar.Title, ar.Blurb, tt.wantLanguage, tt.wantTitle, tt.wantBlurb)
+		}
+		if len(ar.Texts) != 3 {
+			t.Errorf("GET %s texts = %+v, want all 3", tt.path, ar.Texts)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("GET %s Vary = %q, want Accept-Language", tt.path, got)
+		}
+	}
+
+	var tree folderResponse
+	ts.get(t, "/api/tree?lang=it", &tree)
+	if len(tree.Albums) != 1 || tree.Albums[0].Language != "it" || tree.Albums[0].Title != "Parigi" {
+		t.Errorf("GET /api/tree?lang=it albums = %+v", tree.Albums)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+func TestShareLinks(t *testing.T) {
+	ts := newTestServer(t)
+	ids := ts.seedAccess(t)
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	share := func(l *repo.ShareLink) string {
+		t.Helper()
+		if err := ts.repo.CreateShareLink(l); err != nil {
+			t.Fatalf("CreateShareLink() error = %v", err)
+		}
+		return l.Token
+	}
+	private, err := ts.repo.AlbumByPath("private")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	family, err := ts.repo.FolderByPath("family")
+	if err != nil {
+		t.Fatalf("FolderByPath() error = %v", err)
+	}
+	week := now.Add(7 * 24 * time.Hour)
+	album := share(&repo.ShareLink{AlbumID: private.ID, Expires: week})
+	folder := share(&repo.ShareLink{FolderID: family.ID, Expires: week})
+	expired := share(&repo.ShareLink{AlbumID: private.ID, Expires: now.Add(-time.Hour)})
+	revoked := share(&repo.ShareLink{AlbumID: private.ID, Expires: week})
+	if err := ts.repo.RevokeShareLink(revoked); err != nil {
+		t.Fatalf("RevokeShareLink() error = %v", err)
+	}
+	once := share(&repo.ShareLink{AlbumID: private.ID, Expires: week, MaxViews: 1})
+	media := func(name string) string { return "/api/media/" + strconv.FormatInt(ids[name], 10) }
+
+	// Cookies are the session that opening a link in an earlier test started, or
+	// a forged cookie holding the token of the link.
+	const (
+		query   = ""
+		session = "session"
+		forged  = "forged"
+	)
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		cookie     string // Send a cookie instead of the token in the query.
+		wantStatus int
+	}{
+		{"album link", "/api/albums/private", album, query, http.StatusOK},
+		{"album link, session", "/api/albums/private", album, session, http.StatusOK},
+		{"album link, alias", "/api/albums/secret", album, session, http.StatusOK},
+		{"album link, media", media("q1"), album, session, http.StatusOK},
+		{"album link, blob", "/api/blobs/hq1", album, session, http.StatusOK},
+		{"album link, forged cookie", "/api/albums/private", album, forged, http.StatusNotFound},
+		{"album link, other album", "/api/albums/family/xmas", album, session, http.StatusNotFound},
+		{"album link, other folder", "/api/folders/family", album, session, http.StatusNotFound},
+		{"folder link", "/api/folders/family", folder, query, http.StatusOK},
+		{"folder link, album", "/api/albums/family/xmas", folder, session, http.StatusOK},
+		{"folder link, media", media("x1"), folder, session, http.StatusOK},
+		{"folder link, restricted media", media("x2"), folder, session, http.StatusNotFound},
+		{"folder link, other album", "/api/albums/private", folder, session, http.StatusNotFound},
+		{"expired link", "/api/albums/private", expired, query, http.StatusNotFound},
+		{"expired link, forged cookie", "/api/albums/private", expired, forged, http.StatusNotFound},
+		{"revoked link", "/api/albums/private", revoked, query, http.StatusNotFound},
+		{"revoked link, forged cookie", media("q1"), revoked, forged, http.StatusNotFound},
+		{"unknown link", "/api/albums/private", "nope", query, http.StatusNotFound},
+		{"single-view link, first view", "/api/albums/private", once, query, http.StatusOK},
+		{"single-view link, second view", "/api/albums/private", once, query, http.StatusNotFound},
+		{"single-view link, same viewer", "/api/albums/private", once, session, http.StatusOK},
+		{"single-view link, forged cookie", "/api/albums/private", once, forged, http.StatusNotFound},
+	}
+	sessions := map[string]*http.Cookie{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if tt.cookie == query {
+				path += "?share=" + tt.token
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			switch tt.cookie {
+			case session:
+				if sessions[tt.token] == nil {
+					t.Fatalf("no session for link %s", tt.token)
+				}
+				req.AddCookie(sessions[tt.token])
+			case forged:
+				req.AddCookie(&http.Cookie{Name: shareCookie, Value: tt.token})
+			}
+			w := httptest.NewRecorder()
+			ts.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET %s = %d, want %d", path, w.Code, tt.wantStatus)
+			}
+			for _, c := range w.Result().Cookies() {
+				if c.Name == shareCookie {
+					sessions[tt.token] = c
+				}
+			}
+		})
+	}
+
+	// Only opening a link counts as a view, not the requests of the viewer who
+	// opened it, even with the token in the query.
+	twice := share(&repo.ShareLink{AlbumID: private.ID, Expires: week, MaxViews: 2})
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		ts.ServeHTTP(w, req)
+		return w
+	}
+	w := get("/api/albums/private?share="+twice, nil)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != shareCookie || cookies[0].Value == twice {
+		t.Fatalf("GET /api/albums/private = %d with cookies %v, want 200 with session cookie", w.Code, cookies)
+	}
+	for _, path := range []string{"/api/albums/private", media("q1"), "/api/blobs/hq1"} {
+		if w := get(path+"?share="+twice, cookies[0]); w.Code != http.StatusOK || len(w.Result().Cookies()) != 0 {
+			t.Errorf("GET %s by viewer = %d with cookies %v, want 200 without new cookie", path, w.Code, w.Result().Cookies())
+		}
+	}
+	if w := get("/api/albums/private?share="+twice, nil); w.Code != http.StatusOK {
+		t.Errorf("GET /api/albums/private by second viewer = %d, want 200", w.Code)
+	}
+	// The link is now exhausted: a third viewer gets no session, and the token
+	// in a cookie is not a session.
+	w = get("/api/albums/private?share="+twice, nil)
+	if w.Code != http.StatusNotFound || len(w.Result().Cookies()) != 0 {
+		t.Errorf("GET /api/albums/private by third viewer = %d with cookies %v, want 404 without cookie", w.Code, w.Result().Cookies())
+	}
+	if w := get("/api/albums/private", &http.Cookie{Name: shareCookie, Value: twice}); w.Code != http.StatusNotFound {
+		t.Errorf("GET /api/albums/private with token cookie = %d, want 404", w.Code)
+	}
+	if w := get("/api/albums/private", cookies[0]); w.Code != http.StatusOK {
+		t.Errorf("GET /api/albums/private by first viewer = %d, want 200", w.Code)
+	}
+
+	// The folder link reveals only the shared part of the tree.
+	var tree folderResponse
+	if w := ts.get(t, "/api/tree?share="+folder, &tree); w.Code != http.StatusOK {
+		t.Fatalf("GET /api/tree = %d", w.Code)
+	}
+	if len(tree.Folders) != 1 || tree.Folders[0].Path != "family" || len(tree.Albums) != 1 || tree.Albums[0].Path != "public" {
+		t.Errorf("GET /api/tree with folder link = %+v", tree)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/server/repo"
+)
+
+// sortOrderNames maps albums.sort_order values to sort order names (see metadata.SortOrderCode).
+var sortOrderNames = []string{"name", "name:reverse", "mtime", "mtime:reverse", "taken", "taken:reverse"}
+
+type albumText struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Blurb    string `json:"blurb,omitempty"`
+}
+
+// albumSummary describes an album in folder listings. Language and Title are
+// those of the text that best matches the languages requested by the client;
+// Texts has all of them.
+type albumSummary struct {
+	Path     string      `json:"path"`
+	Name     string      `json:"name"`
+	Language string      `json:"language"`
+	Title    string      `json:"title"`
+	Texts    []albumText `json:"texts"`
+}
+
+// folderResponse is the response of GET /api/folders/PATH and (recursively) GET /api/tree.
+type folderResponse struct {
+	Path    string            `json:"path"`
+	Name    string            `json:"name"`
+	Folders []*folderResponse `json:"folders"`
+	Albums  []albumSummary    `json:"albums"`
+}
+
+// albumResponse is the response of GET /api/albums/PATH. As in albumSummary,
+// Language, Title and Blurb are those of the best-matching text.
+type albumResponse struct {
+	Path           string          `json:"path"`
+	Name           string          `json:"name"`
+	SortOrder      string          `json:"sort_order"`
+	Language       string          `json:"language"`
+	Title          string          `json:"title"`
+	Blurb          string          `json:"blurb,omitempty"`
+	Texts          []albumText     `json:"texts"`
+	Aliases        []string        `json:"aliases"`
+	Tags           []string        `json:"tags"`
+	TitlePhoto     int64           `json:"title_photo,omitempty"`
+	HighlightPhoto int64           `json:"highlight_photo,omitempty"`
+	MediaCount     int             `json:"media_count"`
+	Offset         int             `json:"offset"`
+	Limit          int             `json:"limit"`
+	Media          []mediaResponse `json:"media"`
+}
+
+type mediaText struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+type exifResponse struct {
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Camera       string   `json:"camera,omitempty"`
+	Lens         string   `json:"lens,omitempty"`
+	FocalLength  *float64 `json:"focal_length,omitempty"`
+	ExposureTime *float64 `json:"exposure_time,omitempty"`
+	Aperture     *float64 `json:"aperture,omitempty"`
+	ISO          *int     `json:"iso,omitempty"`
+	Flash        *int     `json:"flash,omitempty"`
+}
+
+type rendition struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	MaxDim int    `json:"max_dim"`
+	URL    string `json:"url"`
+}
+
+// mediaResponse describes a media item in album listings and in GET /api/media/ID.
+// EXIF fields and the album path are only included in the latter.
+type mediaResponse struct {
+	ID          int64         `json:"id"`
+	Album       string        `json:"album,omitempty"`
+	Type        string        `json:"type"`
+	DisplayName string        `json:"display_name"`
+	Filename    string        `json:"filename"`
+	MTime       time.Time     `json:"mtime"`
+	Taken       *time.Time    `json:"taken,omitempty"`
+	Portrait    bool          `json:"portrait"`
+	Texts       []mediaText   `json:"texts"`
+	Tags        []string      `json:"tags"`
+	Exif        *exifResponse `json:"exif,omitempty"`
+	Renditions  []rendition   `json:"renditions"`
+}
+
+func newAlbumTexts(texts []repo.AlbumText) []albumText {
+	l := []albumText{}
+	for _, t := range texts {
+		l = append(l, albumText{Language: t.Language, Title: t.Title, Blurb: t.Blurb})
+	}
+	return l
+}
+
+// newAlbumSummary converts an album, choosing its title according to the
+// preferred languages langs.
+func newAlbumSummary(a *repo.Album, langs []string) albumSummary {
+	t := chooseAlbumText(a, langs)
+	return albumSummary{Path: a.Path, Name: a.Name, Language: t.Language, Title: t.Title, Texts: newAlbumTexts(a.Texts)}
+}
+
+// newMediaResponse converts a media item and its renditions. If detail is true,
+// EXIF fields are included.
+func newMediaResponse(m *repo.Media, blobs []repo.Blob, detail bool) mediaResponse {
+	mr := mediaResponse{
+		ID:          m.ID,
+		Type:        "photo",
+		DisplayName: m.DisplayName,
+		Filename:    m.SourceFilename,
+		MTime:       m.MTime,
+		Portrait:    m.Portrait,
+		Texts:       []mediaText{},
+		Tags:        m.Tags,
+		Renditions:  []rendition{},
+	}
+	if m.Type == repo.Video {
+		mr.Type = "video"
+	}
+	if !m.ExifTime.IsZero() {
+		mr.Taken = &m.ExifTime
+	}
+	for _, t := range m.Texts {
+		mr.Texts = append(mr.Texts, mediaText{Language: t.Language, Title: t.Title, Caption: t.Caption})
+	}
+	for _, b := range blobs {
+		mr.Renditions = append(mr.Renditions, rendition{Width: b.Width, Height: b.Height, MaxDim: b.MaxDim, URL: blobURL(b.ContentHash)})
+	}
+	if detail {
+		mr.Exif = &exifResponse{
+			Latitude:     m.Latitude,
+			Longitude:    m.Longitude,
+			Camera:       m.Camera,
+			Lens:         m.Lens,
+			FocalLength:  m.FocalLength,
+			ExposureTime: m.ExposureTime,
+			Aperture:     m.Aperture,
+			ISO:          m.ISO,
+			Flash:        m.Flash,
+		}
+	}
+	return mr
+}
+
+// blobURL returns the URL of a rendition.
+func blobURL(hash string) string {
+	return "/api/blobs/" + hash
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+// uploadPrefix prefixes the storage keys of the chunks of resumable uploads.
+// The chunks of a session are stored under "uploads/TOKEN/OFFSET", with OFFSET
+// zero-padded so that listing them returns them in order.
+const uploadPrefix = "uploads/"
+
+func chunkKey(u *repo.Upload, offset int64) string {
+	return fmt.Sprintf("%s%s/%016x", uploadPrefix, u.Token, offset)
+}
+
+func uploadStatus(u *repo.Upload) ingest.UploadStatus {
+	return ingest.UploadStatus{ID: u.Token, Hash: u.Hash, Size: u.Size, Received: u.Received}
+}
+
+// abortUpload deletes an upload session and its chunks.
+func (s *Server) abortUpload(u *repo.Upload) error {
+	if err := s.repo.DeleteUpload(u.Token); err != nil {
+		return err
+	}
+	chunks, err := s.store.List(uploadPrefix + u.Token + "/")
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := s.store.Delete(c.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireUploads deletes the upload sessions that received no data since before,
+// and returns the number of sessions deleted.
+func (s *Server) ExpireUploads(before time.Time) (int, error) {
+	stale, err := s.repo.StaleUploads(before)
+	if err != nil {
+		return 0, err
+	}
+	for i, u := range stale {
+		if err := s.abortUpload(u); err != nil {
+			return i, err
+		}
+	}
+	return len(stale), nil
+}
+
+// upload returns the upload session named by the "id" path value.
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) (*repo.Upload, bool) {
+	u, err := s.repo.UploadByToken(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return u, true
+}
+
+// handleCreateUpload serves POST /api/ingest/uploads.
+func (s *Server) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	var in ingest.UploadRequest
+	if !readJSON(w, r, &in) {
+		return
+	}
+	if !ingest.ValidHash(in.Hash) {
+		badRequest(w, "invalid content hash")
+		return
+	}
+	if in.Size < 0 || in.Size > ingest.MaxUploadSize {
+		badRequest(w, fmt.Sprintf("invalid upload size %d", in.Size))
+		return
+	}
+	if _, err := s.store.Stat(renditionPrefix + in.Hash); err == nil {
+		writeJSON(w, http.StatusOK, ingest.UploadStatus{Hash: in.Hash, Size: in.Size, Received: in.Size, Complete: true})
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+	u, err := s.repo.CreateUpload(in.Hash, in.Size, s.now())
+	if errors.Is(err, repo.ErrConflict) {
+		// The blob cannot have two sizes, so the previous session was bogus.
+		var old *repo.Upload
+		if old, err = s.repo.UploadByHash(in.Hash); err == nil {
+			err = s.abortUpload(old)
+		}
+		if err == nil {
+			u, err = s.repo.CreateUpload(in.Hash, in.Size, s.now())
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, uploadStatus(u))
+}
+
+// handleUploadStatus serves GET /api/ingest/uploads/ID.
+func (s *Server) handleUploadStatus(w http.ResponseWriter, r *http.Request) {
+	if u, ok := s.upload(w, r); ok {
+		writeJSON(w, http.StatusOK, uploadStatus(u))
+	}
+}
+
+// handlePutChunk serves PUT /api/ingest/uploads/ID?offset=N.
+func (s *Server) handlePutChunk(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.upload(w, r)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		badRequest(w, "invalid offset")
+		return
+	}
+	if offset != u.Received {
+		writeJSON(w, http.StatusConflict, uploadStatus(u))
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ingest.MaxBlobSize))
+	if err != nil {
+		badRequest(w, fmt.Sprintf("failed to read chunk: %v", err))
+		return
+	}
+	end := offset + int64(len(data))
+	if end > u.Size {
+		badRequest(w, fmt.Sprintf("chunk ends at %d, past the upload size %d", end, u.Size))
+		return
+	}
+	// A chunk whose session was not advanced (e.g., because of a concurrent
+	// request) is overwritten by the next chunk at the same offset.
+	if err := s.store.Put(chunkKey(u, offset), bytes.NewReader(data), int64(len(data))); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.repo.AdvanceUpload(u.Token, offset, end, s.now()); errors.Is(err, repo.ErrConflict) {
+		if u, err = s.repo.UploadByToken(u.Token); err == nil {
+			writeJSON(w, http.StatusConflict, uploadStatus(u))
+			return
+		}
+		writeError(w, err)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	u.Received = end
+	writeJSON(w, http.StatusOK, uploadStatus(u))
+}
+
+// chunkReader reads the concatenation of the chunks of an upload.
+type chunkReader struct {
+	store storage.Storage
+	keys  []string
+	rc    io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.rc == nil {
+			if len(c.keys) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := c.store.Get(c.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			c.rc, c.keys = rc, c.keys[1:]
+		}
+		n, err := c.rc.Read(p)
+		if err == io.EOF {
+			c.rc.Close()
+			c.rc = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the current chunk, if open.
+func (c *chunkReader) Close() error {
+	if c.rc == nil {
+		return nil
+	}
+	return c.rc.Close()
+}
+
+// chunkKeys returns the keys of the chunks of a complete upload, in order,
+// checking that they are contiguous and add up to the upload size.
+func (s *Server) chunkKeys(u *repo.Upload) ([]string, error) {
+	chunks, err := s.store.List(uploadPrefix + u.Token + "/")
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	var offset int64
+	for _, c := range chunks {
+		if c.Key != chunkKey(u, offset) {
+			return nil, fmt.Errorf("missing chunk at offset %d", offset)
+		}
+		keys = append(keys, c.Key)
+		offset += c.Size
+	}
+	if offset != u.Size {
+		return nil, fmt.Errorf("chunks add up to %d bytes, want %d", offset, u.Size)
+	}
+	return keys, nil
+}
+
+// handleFinalizeUpload serves POST /api/ingest/uploads/ID/finalize.
+func (s *Server) handleFinalizeUpload(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.upload(w, r)
+	if !ok {
+		return
+	}
+	if u.Received != u.Size {
+		writeJSON(w, http.StatusConflict, uploadStatus(u))
+		return
+	}
+	key := renditionPrefix + u.Hash
+	if _, err := s.store.Stat(key); errors.Is(err, storage.ErrNotFound) {
+		keys, err := s.chunkKeys(u)
+		if err != nil {
+			s.failUpload(w, u, err.Error())
+			return
+		}
+		// Verify the hash before storing the blob, so that content-addressed
+		// objects are never wrong, even briefly.
+		h := sha256.New()
+		cr := &chunkReader{store: s.store, keys: keys}
+		_, err = io.Copy(h, cr)
+		cr.Close()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if hex.EncodeToString(h.Sum(nil)) != u.Hash {
+			s.failUpload(w, u, "content hash mismatch")
+			return
+		}
+		cr = &chunkReader{store: s.store, keys: keys}
+		err = s.store.Put(key, cr, u.Size)
+		cr.Close()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.abortUpload(u); err != nil {
+		log.Printf("failed to clean up upload %s: %v", u.Token, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// failUpload aborts an upload whose data is unusable and reports why.
+func (s *Server) failUpload(w http.ResponseWriter, u *repo.Upload, msg string) {
+	if err := s.abortUpload(u); err != nil {
+		log.Printf("failed to clean up upload %s: %v", u.Token, err)
+	}
+	badRequest(w, msg+"; upload aborted")
+}
+
+// handleAbortUpload serves DELETE /api/ingest/uploads/ID.
+func (s *Server) handleAbortUpload(w http.ResponseWriter, r *http.Request) {
+	u, err := s.repo.UploadByToken(r.PathValue("id"))
+	if errors.Is(err, repo.ErrNotFound) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.abortUpload(u); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
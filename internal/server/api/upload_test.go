@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxpoletto/lbx/internal/ingest"
+	"github.com/maxpoletto/lbx/internal/server/repo"
+	"github.com/maxpoletto/lbx/internal/storage"
+)
+
+func TestUploadSession(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	data := []byte("0123456789abcdefghij")
+	hash := hashOf(data)
+
+	st, err := c.StartUpload(hash, int64(len(data)))
+	if err != nil {
+		t.Fatalf("StartUpload() error = %v", err)
+	}
+	if st.ID == "" || st.Received != 0 || st.Complete {
+		t.Errorf("StartUpload() = %+v", st)
+	}
+	if st, err = c.PutChunk(st.ID, 0, data[:8]); err != nil || st.Received != 8 {
+		t.Fatalf("PutChunk(0) = %+v, %v, want 8 bytes received", st, err)
+	}
+	// A repeated chunk is ignored and reports the current status.
+	if st, err = c.PutChunk(st.ID, 0, data[:8]); err != nil || st.Received != 8 {
+		t.Errorf("PutChunk(0) again = %+v, %v, want 8 bytes received", st, err)
+	}
+	if _, err := c.PutChunk(st.ID, 8, make([]byte, 20)); err == nil {
+		t.Errorf("PutChunk() past the end error = nil, want error")
+	}
+	if err := c.FinalizeUpload(st.ID); err == nil {
+		t.Errorf("FinalizeUpload() of incomplete upload error = nil, want error")
+	}
+
+	// After a restart, the client finds the session by hash.
+	resumed, err := c.StartUpload(hash, int64(len(data)))
+	if err != nil || resumed.ID != st.ID || resumed.Received != 8 {
+		t.Fatalf("StartUpload() again = %+v, %v, want session %s with 8 bytes", resumed, err, st.ID)
+	}
+	if st, err = c.PutChunk(st.ID, 8, data[8:]); err != nil || st.Received != int64(len(data)) {
+		t.Fatalf("PutChunk(8) = %+v, %v", st, err)
+	}
+	if got, err := c.UploadStatus(st.ID); err != nil || *got != *st {
+		t.Errorf("UploadStatus() = %+v, %v, want %+v", got, err, st)
+	}
+	if err := c.FinalizeUpload(st.ID); err != nil {
+		t.Fatalf("FinalizeUpload() error = %v", err)
+	}
+	if ok, err := c.HasBlob(hash); err != nil || !ok {
+		t.Errorf("HasBlob() = %t, %v, want true", ok, err)
+	}
+	if chunks, _ := ts.store.List(uploadPrefix); len(chunks) != 0 {
+		t.Errorf("chunks after finalize = %+v, want none", chunks)
+	}
+	if _, err := c.UploadStatus(st.ID); err == nil {
+		t.Errorf("UploadStatus() after finalize error = nil, want error")
+	}
+	// Uploading a stored blob is a no-op.
+	if st, err := c.StartUpload(hash, int64(len(data))); err != nil || !st.Complete {
+		t.Errorf("StartUpload() of stored blob = %+v, %v, want complete", st, err)
+	}
+}
+
+func TestUploadHashMismatch(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	hash := hashOf([]byte("expected"))
+	st, err := c.StartUpload(hash, 8)
+	if err != nil {
+		t.Fatalf("StartUpload() error = %v", err)
+	}
+	if _, err := c.PutChunk(st.ID, 0, []byte("received")); err != nil {
+		t.Fatalf("PutChunk() error = %v", err)
+	}
+	if err := c.FinalizeUpload(st.ID); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("FinalizeUpload() error = %v, want mismatch", err)
+	}
+	// The session is aborted, so the next attempt starts over.
+	if _, err := ts.store.Stat("renditions/" + hash); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Stat() error = %v, want ErrNotFound", err)
+	}
+	if st2, err := c.StartUpload(hash, 8); err != nil || st2.ID == st.ID || st2.Received != 0 {
+		t.Errorf("StartUpload() after mismatch = %+v, %v, want new session", st2, err)
+	}
+	// So does an attempt with a different size.
+	st3, err := c.StartUpload(hash, 9)
+	if err != nil || st3.Size != 9 || st3.Received != 0 {
+		t.Errorf("StartUpload() with new size = %+v, %v", st3, err)
+	}
+	if err := c.AbortUpload(st3.ID); err != nil {
+		t.Errorf("AbortUpload() error = %v", err)
+	}
+	if err := c.AbortUpload(st3.ID); err != nil {
+		t.Errorf("AbortUpload() again error = %v", err)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	// Larger than a chunk.
+	data := bytes.Repeat([]byte("lbx"), ingest.ChunkSize/2)
+	hash := hashOf(data)
+	// Start an upload and send one chunk, as if interrupted.
+	st, err := c.StartUpload(hash, int64(len(data)))
+	if err != nil {
+		t.Fatalf("StartUpload() error = %v", err)
+	}
+	if _, err := c.PutChunk(st.ID, 0, data[:1000]); err != nil {
+		t.Fatalf("PutChunk() error = %v", err)
+	}
+	if err := c.Upload(hash, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	chunks, _ := ts.store.List(uploadPrefix)
+	if len(chunks) != 0 {
+		t.Errorf("chunks after upload = %+v, want none", chunks)
+	}
+	info, err := ts.store.Stat("renditions/" + hash)
+	if err != nil || info.Size != int64(len(data)) {
+		t.Errorf("Stat() = %+v, %v, want %d bytes", info, err, len(data))
+	}
+	// Empty blobs need no chunks.
+	if err := c.Upload(hashOf(nil), bytes.NewReader(nil), 0); err != nil {
+		t.Errorf("Upload() of empty blob error = %v", err)
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	ts := newTestServer(t)
+	c, _ := newIngestClient(t, ts)
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ts.now = func() time.Time { return t0 }
+	st, err := c.StartUpload(hashOf([]byte("abandoned")), 9)
+	if err != nil {
+		t.Fatalf("StartUpload() error = %v", err)
+	}
+	if _, err := c.PutChunk(st.ID, 0, []byte("aban")); err != nil {
+		t.Fatalf("PutChunk() error = %v", err)
+	}
+	ts.now = func() time.Time { return t0.Add(time.Hour) }
+	active, err := c.StartUpload(hashOf([]byte("active")), 6)
+	if err != nil {
+		t.Fatalf("StartUpload() error = %v", err)
+	}
+
+	if n, err := ts.ExpireUploads(t0.Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("ExpireUploads() = %d, %v, want 1", n, err)
+	}
+	if _, err := ts.repo.UploadByToken(st.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("UploadByToken() of expired session error = %v, want ErrNotFound", err)
+	}
+	if chunks, _ := ts.store.List(uploadPrefix); len(chunks) != 0 {
+		t.Errorf("chunks after expiry = %+v, want none", chunks)
+	}
+	if _, err := c.UploadStatus(active.ID); err != nil {
+		t.Errorf("UploadStatus() of active session error = %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Package db opens the LBX SQLite database and manages its schema through
+// versioned migrations embedded in the binary.
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration is a schema change, stored in migrations/NNNN_name.sql.
+// Migration N upgrades the schema from version N-1 to version N.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+var migrationName = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.sql$`)
+
+// migrations is the list of embedded migrations, sorted by version.
+var migrations = mustLoadMigrations(migrationFS)
+
+func mustLoadMigrations(fsys fs.FS) []Migration {
+	ms, err := loadMigrations(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
+
+// loadMigrations reads the migrations in fsys and checks that their versions are 1, 2, ..., N.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	ms := []Migration{}
+	for _, fn := range files {
+		m := migrationName.FindStringSubmatch(path.Base(fn))
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", fn)
+		}
+		sql, err := fs.ReadFile(fsys, fn)
+		if err != nil {
+			return nil, err
+		}
+		v, _ := strconv.Atoi(m[1])
+		ms = append(ms, Migration{Version: v, Name: m[2], SQL: string(sql)})
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Version < ms[j].Version
+	})
+	for i, m := range ms {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %04d_%s: expected version %d", m.Version, m.Name, i+1)
+		}
+	}
+	return ms, nil
+}
+
+// LatestVersion returns the schema version this binary understands.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// Open opens the SQLite database at path, enabling foreign key enforcement
+// (a per-connection setting in SQLite). It does not check the schema version.
+func Open(path string) (*sql.DB, error) {
+	// The path is escaped so that characters such as '?', '#' and '%' are part
+	// of the filename rather than of the URI.
+	dsn := url.URL{Scheme: "file", Path: path, RawQuery: "_foreign_keys=on&_busy_timeout=5000"}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return db, nil
+}
+
+// Version returns the schema version of a database: 0 for an empty database.
+// Databases created from the original schema.sql, before versioning existed, are version 1.
+func Version(db *sql.DB) (int, error) {
+	if exists, err := tableExists(db, "schema_version"); err != nil {
+		return 0, err
+	} else if exists {
+		var v int
+		if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&v); err != nil {
+			return 0, fmt.Errorf("failed to read schema version: %v", err)
+		}
+		return v, nil
+	}
+	if legacy, err := tableExists(db, "folders"); err != nil {
+		return 0, err
+	} else if legacy {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Check returns an error unless the database schema is at the latest version.
+func Check(db *sql.DB) error {
+	v, err := Version(db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v > LatestVersion():
+		return fmt.Errorf("database schema version %d is newer than the latest supported version %d; upgrade LBX", v, LatestVersion())
+	case v < LatestVersion():
+		return fmt.Errorf("database schema version %d is older than version %d; run `dbinit up`", v, LatestVersion())
+	}
+	return nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction.
+// Returns the schema versions before and after migrating.
+func Migrate(db *sql.DB) (from, to int, err error) {
+	from, err = Version(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > LatestVersion() {
+		return from, from, Check(db)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		applied INTEGER NOT NULL -- Unix time
+	)`); err != nil {
+		return from, from, fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+	to = from
+	if from == 1 {
+		// Record the version of a legacy database (no-op if already recorded).
+		if _, err := db.Exec("INSERT OR IGNORE INTO schema_version (version, applied) VALUES (1, strftime('%s', 'now'))"); err != nil {
+			return from, to, fmt.Errorf("failed to record schema version: %v", err)
+		}
+	}
+	for _, m := range migrations[from:] {
+		if err := apply(db, m); err != nil {
+			return from, to, err
+		}
+		to = m.Version
+	}
+	return from, to, nil
+}
+
+// apply runs a migration and records its version in a single transaction.
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, applied) VALUES (?, strftime('%s', 'now'))", m.Version); err != nil {
+		return fmt.Errorf("failed to record schema version: %v", err)
+	}
+	return tx.Commit()
+}
+
+func tableExists(db *sql.DB, name string) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to read database schema: %v", err)
+	}
+	return n > 0, nil
+}
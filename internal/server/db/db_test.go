@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "lbx.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := openTestDB(t)
+	if v, err := Version(db); err != nil || v != 0 {
+		t.Fatalf("Version() = %d, %v, want 0", v, err)
+	}
+	if err := Check(db); err == nil {
+		t.Errorf("Check() on empty database error = nil, want error")
+	}
+	from, to, err := Migrate(db)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if from != 0 || to != LatestVersion() {
+		t.Errorf("Migrate() = %d, %d, want 0, %d", from, to, LatestVersion())
+	}
+	if err := Check(db); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO folders (name, path) VALUES ('', '')"); err != nil {
+		t.Errorf("Failed to insert into migrated schema: %v", err)
+	}
+	// Migrating again is a no-op.
+	if from, to, err := Migrate(db); err != nil || from != to {
+		t.Errorf("Migrate() = %d, %d, %v, want no-op", from, to, err)
+	}
+}
+
+func TestForeignKeysEnabled(t *testing.T) {
+	db := openTestDB(t)
+	if _, _, err := Migrate(db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	_, err := db.Exec("INSERT INTO albums (folder_id, name, path) VALUES (42, 'a', 'a')")
+	if err == nil || !strings.Contains(err.Error(), "FOREIGN KEY") {
+		t.Errorf("Insert with dangling foreign key error = %v, want FOREIGN KEY constraint error", err)
+	}
+}
+
+func TestOpenSpecialPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "my photos?#%20", "lbx #1?.db")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+	if _, _, err := Migrate(db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Stat() error = %v, want database at %s", err, path)
+	}
+	// Connection options still apply.
+	if _, err := db.Exec("INSERT INTO albums (folder_id, name, path) VALUES (42, 'a', 'a')"); err == nil {
+		t.Errorf("Insert with dangling foreign key error = nil, want error")
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	// A database created by the original dbinit from schema.sql has no schema_version table.
+	db := openTestDB(t)
+	if _, err := db.Exec(migrations[0].SQL); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	if v, err := Version(db); err != nil || v != 1 {
+		t.Fatalf("Version() = %d, %v, want 1", v, err)
+	}
+	from, to, err := Migrate(db)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if from != 1 || to != LatestVersion() {
+		t.Errorf("Migrate() = %d, %d, want 1, %d", from, to, LatestVersion())
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&n); err != nil || n != LatestVersion() {
+		t.Errorf("schema_version has %d rows (%v), want %d", n, err, LatestVersion())
+	}
+}
+
+func TestNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	if _, _, err := Migrate(db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_version (version, applied) VALUES (?, 0)", LatestVersion()+1); err != nil {
+		t.Fatalf("Failed to bump schema version: %v", err)
+	}
+	if err := Check(db); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Check() error = %v, want newer schema error", err)
+	}
+	if _, _, err := Migrate(db); err == nil {
+		t.Errorf("Migrate() error = nil, want error")
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr bool
+	}{
+		{"valid", []string{"0001_a.sql", "0002_b.sql"}, false},
+		{"gap", []string{"0001_a.sql", "0003_c.sql"}, true},
+		{"duplicate", []string{"0001_a.sql", "0001_b.sql"}, true},
+		{"bad name", []string{"0001_a.sql", "2_b.sql"}, true},
+		{"not starting at 1", []string{"0002_b.sql"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, fn := range tt.files {
+				fsys["migrations/"+fn] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			}
+			_, err := loadMigrations(fsys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
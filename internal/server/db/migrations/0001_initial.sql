@@ -1,6 +1,5 @@
 -- SQLite schema for LBX photo metadata.
-
-PRAGMA foreign_keys = ON;
+-- Foreign key enforcement is enabled per connection (see db.Open).
 
 ------ Folders
 CREATE TABLE folders (
@@ -0,0 +1,2 @@
+-- A source file appears at most once per album. Media are upserted by (album, source file).
+CREATE UNIQUE INDEX media_album_source ON media(album_id, source_filename);
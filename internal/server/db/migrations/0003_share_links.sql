@@ -0,0 +1,16 @@
+-- Share links. An unguessable token granting access to one album or folder
+-- (and everything below it) until it expires, is revoked, or runs out of views.
+CREATE TABLE share_links (
+    id INTEGER PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    album_id INTEGER, -- Exactly one of album_id and folder_id is set.
+    folder_id INTEGER,
+    created INTEGER NOT NULL,
+    expires INTEGER NOT NULL,
+    max_views INTEGER, -- NULL for unlimited
+    views INTEGER NOT NULL DEFAULT 0,
+    revoked INTEGER NOT NULL DEFAULT 0,
+    CHECK ((album_id IS NULL) != (folder_id IS NULL)),
+    FOREIGN KEY(album_id) REFERENCES albums(id) ON DELETE CASCADE,
+    FOREIGN KEY(folder_id) REFERENCES folders(id) ON DELETE CASCADE
+);
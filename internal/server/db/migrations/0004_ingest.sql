@@ -0,0 +1,35 @@
+-- Ingest API (see internal/server/api/ingest.go).
+
+-- Admin tokens authenticate the ingest API. They are unrelated to access keys,
+-- which only grant read access. Only the SHA-256 hash of each token is stored.
+CREATE TABLE admin_tokens (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created INTEGER NOT NULL
+);
+
+-- Hex SHA-256 hash and size of the source file of each media item, so that
+-- clients can publish only new or changed files.
+ALTER TABLE media ADD COLUMN source_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE media ADD COLUMN source_size INTEGER NOT NULL DEFAULT 0;
+
+-- Identical source files (e.g., the same photo in two albums) have identical
+-- renditions, so a content hash may belong to several media items.
+CREATE TABLE blobs_new (
+    media_id INTEGER NOT NULL,
+    content_hash TEXT NOT NULL,
+    bucket_name TEXT NOT NULL,
+    object_key TEXT NOT NULL,
+    height INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    max_dim INTEGER NOT NULL,
+    UNIQUE(media_id, content_hash),
+    FOREIGN KEY(media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+INSERT INTO blobs_new (media_id, content_hash, bucket_name, object_key, height, width, max_dim)
+    SELECT media_id, content_hash, bucket_name, object_key, height, width, max_dim FROM blobs;
+DROP TABLE blobs;
+ALTER TABLE blobs_new RENAME TO blobs;
+CREATE INDEX blobs_media_id_size ON blobs(media_id, max_dim);
+CREATE INDEX blobs_content_hash ON blobs(content_hash);
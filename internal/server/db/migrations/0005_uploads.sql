@@ -0,0 +1,13 @@
+-- Resumable uploads (see internal/server/api/upload.go). A session receives the
+-- chunks of one blob, identified by its content hash, which are stored as
+-- separate objects until the session is finalized or expires.
+CREATE TABLE uploads (
+    id INTEGER PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    content_hash TEXT NOT NULL UNIQUE,
+    size INTEGER NOT NULL,
+    received INTEGER NOT NULL DEFAULT 0, -- Number of bytes received so far.
+    created INTEGER NOT NULL,
+    updated INTEGER NOT NULL
+);
+CREATE INDEX uploads_updated ON uploads(updated);
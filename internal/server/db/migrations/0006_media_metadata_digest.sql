@@ -0,0 +1,4 @@
+-- Digest of the metadata with which clients published each media item (e.g.,
+-- texts, tags, access keys and rendition sizes), so that they can republish
+-- items whose metadata changed even if their source file did not.
+ALTER TABLE media ADD COLUMN metadata_digest TEXT NOT NULL DEFAULT '';
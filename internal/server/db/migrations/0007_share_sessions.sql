@@ -0,0 +1,10 @@
+-- Viewers of share links. Opening a link starts a session, whose unguessable ID
+-- the viewer presents instead of the token of the link, so that holding the
+-- token is not enough to keep viewing a link that ran out of views.
+CREATE TABLE share_sessions (
+    id TEXT PRIMARY KEY,
+    share_link_id INTEGER NOT NULL,
+    created INTEGER NOT NULL,
+    FOREIGN KEY(share_link_id) REFERENCES share_links(id) ON DELETE CASCADE
+);
+CREATE INDEX share_sessions_share_link_id ON share_sessions(share_link_id);
@@ -0,0 +1,4 @@
+-- BCP 47 code of the language of the default texts of each album (those whose
+-- language_code is ''), so that they can be chosen by language like the
+-- translations. Empty if the collection does not declare it.
+ALTER TABLE albums ADD COLUMN language TEXT NOT NULL DEFAULT '';
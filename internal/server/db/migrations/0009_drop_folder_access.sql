@@ -0,0 +1,4 @@
+-- Folder grants were never populated: lbx merges the access keys of the parent
+-- directories of an album into those of the album (see metadata.ReadMetadata), so
+-- album_access holds all the grants of every album.
+DROP TABLE folder_access;
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"slices"
+)
+
+// Viewer identifies who is looking at the collection.
+type Viewer struct {
+	// Key is the access key of the viewer, or "" for none.
+	Key string
+	// Share is the (valid) share link of the viewer, or nil for none. The albums a
+	// share link covers are visible as if the viewer had a key granted them.
+	Share *ShareLink
+}
+
+// albumGrants returns the grants of the albums selected by where (e.g., "WHERE id = ?"),
+// keyed by album ID. Public albums have no entry.
+func albumGrants(q querier, where string, args ...any) (map[int64][]string, error) {
+	rows, err := q.Query(`SELECT aa.album_id, k.key FROM album_access aa
+		JOIN access_keys k ON k.id = aa.access_key_id
+		WHERE aa.album_id IN (SELECT id FROM albums `+where+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	grants := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		grants[id] = append(grants[id], key)
+	}
+	return grants, rows.Err()
+}
+
+// AlbumGrants returns the access keys granted access to an album, sorted. An empty list means the album is public.
+func (r *Repo) AlbumGrants(albumID int64) ([]string, error) {
+	grants, err := albumGrants(r.db, "WHERE id = ?", albumID)
+	if err != nil {
+		return nil, err
+	}
+	keys := grants[albumID]
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+// AlbumVisible reports whether a viewer may see an album.
+//
+// The grants of an album are its access keys, which lbx merges from the metadata
+// of the album and of its parent directories; an album without grants is public.
+// A media item may also be restricted to its own keys (media_access), which only
+// narrows the access of its album: a key sees the media item only if it sees the
+// album and the media item is restricted to it. Keys that are not granted the
+// album never see it, even if some of its media items are restricted to them.
+func (r *Repo) AlbumVisible(albumID int64, v Viewer) (bool, error) {
+	if v.Share != nil {
+		var path string
+		if err := r.db.QueryRow("SELECT path FROM albums WHERE id = ?", albumID).Scan(&path); err != nil {
+			return false, err
+		}
+		if v.Share.covers(albumID, path) {
+			return true, nil
+		}
+	}
+	grants, err := r.AlbumGrants(albumID)
+	if err != nil {
+		return false, err
+	}
+	return len(grants) == 0 || (v.Key != "" && slices.Contains(grants, v.Key)), nil
+}
+
+// MediaVisible reports whether a viewer may see a media item: the viewer must
+// have access to its album and, if the media item is restricted, one of its keys.
+func (r *Repo) MediaVisible(m *Media, v Viewer) (bool, error) {
+	if len(m.Access) > 0 && (v.Key == "" || !slices.Contains(m.Access, v.Key)) {
+		return false, nil
+	}
+	return r.AlbumVisible(m.AlbumID, v)
+}
+
+// Visibility is the access a viewer has to every album and folder of the
+// collection, for filtering listings.
+type Visibility struct {
+	albums  map[int64]bool
+	folders map[int64]bool
+}
+
+// Album reports whether an album is visible to the viewer (see AlbumVisible).
+func (v *Visibility) Album(id int64) bool {
+	return v.albums[id]
+}
+
+// Folder reports whether a folder contains, at any depth, an album visible to the viewer.
+// The root folder is always visible.
+func (v *Visibility) Folder(f *Folder) bool {
+	return f.ParentID == 0 || v.folders[f.ID]
+}
+
+// VisibilityFor computes the access that a viewer has to all albums and folders.
+func (r *Repo) VisibilityFor(viewer Viewer) (*Visibility, error) {
+	key := viewer.Key
+	grants, err := albumGrants(r.db, "")
+	if err != nil {
+		return nil, err
+	}
+	parents, err := folderParents(r.db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query("SELECT id, folder_id, path FROM albums")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	v := &Visibility{albums: map[int64]bool{}, folders: map[int64]bool{}}
+	for rows.Next() {
+		var id, folderID int64
+		var path string
+		if err := rows.Scan(&id, &folderID, &path); err != nil {
+			return nil, err
+		}
+		g := grants[id]
+		switch {
+		case viewer.Share != nil && viewer.Share.covers(id, path):
+			v.albums[id] = true
+		case len(g) == 0 || (key != "" && slices.Contains(g, key)):
+			v.albums[id] = true
+		default:
+			continue
+		}
+		for f := folderID; f != 0 && !v.folders[f]; f = parents[f] {
+			v.folders[f] = true
+		}
+	}
+	return v, rows.Err()
+}
+
+// folderParents returns the parent ID of every folder (0 for the root).
+func folderParents(q querier) (map[int64]int64, error) {
+	rows, err := q.Query("SELECT id, COALESCE(parent_id, 0) FROM folders")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	parents := map[int64]int64{}
+	for rows.Next() {
+		var id, parent int64
+		if err := rows.Scan(&id, &parent); err != nil {
+			return nil, err
+		}
+		parents[id] = parent
+	}
+	return parents, rows.Err()
+}
@@ -0,0 +1,210 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// accessFixture is a collection with:
+//
+//	public:        no grants; media p1, p2 (p2 restricted to alice)
+//	private:       granted to alice; media q1
+//	family/xmas:   granted to fam (as if inherited from family/) and friend;
+//	               media x1, x2 (x2 restricted to friend), x3 (x3 restricted
+//	               to stranger)
+//	family/kids:   granted to fam (as if inherited from family/) and kids
+type accessFixture struct {
+	r                           *Repo
+	public, private, xmas, kids *Album
+	p1, p2, q1, x1, x2, x3      *Media
+	family                      *Folder
+}
+
+func newAccessFixture(t *testing.T) *accessFixture {
+	t.Helper()
+	r := newTestRepo(t)
+	f := &accessFixture{r: r}
+	f.public = mustUpsertAlbum(t, r, &Album{Path: "public"})
+	f.private = mustUpsertAlbum(t, r, &Album{Path: "private", Access: []string{"alice"}})
+	f.xmas = mustUpsertAlbum(t, r, &Album{Path: "family/xmas", Access: []string{"fam", "friend"}})
+	f.kids = mustUpsertAlbum(t, r, &Album{Path: "family/kids", Access: []string{"fam", "kids"}})
+	media := func(a *Album, name string, access ...string) *Media {
+		return mustUpsertMedia(t, r, &Media{AlbumID: a.ID, DisplayName: name, SourceFilename: name + ".jpg", MTime: time.Unix(0, 0), Access: access})
+	}
+	f.p1 = media(f.public, "p1")
+	f.p2 = media(f.public, "p2", "alice")
+	f.q1 = media(f.private, "q1")
+	f.x1 = media(f.xmas, "x1")
+	f.x2 = media(f.xmas, "x2", "friend")
+	f.x3 = media(f.xmas, "x3", "stranger")
+	var err error
+	if f.family, err = r.FolderByPath("family"); err != nil {
+		t.Fatalf("FolderByPath(family) error = %v", err)
+	}
+	return f
+}
+
+func TestAlbumGrants(t *testing.T) {
+	f := newAccessFixture(t)
+	tests := []struct {
+		album *Album
+		want  []string
+	}{
+		{f.public, []string{}},
+		{f.private, []string{"alice"}},
+		{f.xmas, []string{"fam", "friend"}},
+		{f.kids, []string{"fam", "kids"}},
+	}
+	for _, tt := range tests {
+		got, err := f.r.AlbumGrants(tt.album.ID)
+		if err != nil {
+			t.Fatalf("AlbumGrants(%s) error = %v", tt.album.Path, err)
+		}
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AlbumGrants(%s) = %v, want %v", tt.album.Path, got, tt.want)
+		}
+	}
+}
+
+func TestAlbumVisible(t *testing.T) {
+	f := newAccessFixture(t)
+	tests := []struct {
+		album *Album
+		key   string
+		want  bool
+	}{
+		{f.public, "", true},
+		{f.public, "unknown", true},
+		{f.private, "", false},
+		{f.private, "alice", true},
+		{f.private, "fam", false},
+		{f.xmas, "", false},
+		{f.xmas, "fam", true},
+		{f.xmas, "friend", true},
+		{f.xmas, "alice", false},
+		// Per-photo keys do not grant access to the album.
+		{f.xmas, "stranger", false},
+		{f.kids, "kids", true},
+		{f.kids, "fam", true},
+		{f.kids, "friend", false},
+	}
+	for _, tt := range tests {
+		got, err := f.r.AlbumVisible(tt.album.ID, Viewer{Key: tt.key})
+		if err != nil {
+			t.Fatalf("AlbumVisible(%s, %q) error = %v", tt.album.Path, tt.key, err)
+		}
+		if got != tt.want {
+			t.Errorf("AlbumVisible(%s, %q) = %v, want %v", tt.album.Path, tt.key, got, tt.want)
+		}
+
+		// Bulk computation agrees with the single-album one.
+		v, err := f.r.VisibilityFor(Viewer{Key: tt.key})
+		if err != nil {
+			t.Fatalf("VisibilityFor(%q) error = %v", tt.key, err)
+		}
+		if got := v.Album(tt.album.ID); got != tt.want {
+			t.Errorf("VisibilityFor(%q).Album(%s) = %v, want %v", tt.key, tt.album.Path, got, tt.want)
+		}
+	}
+}
+
+func TestMediaVisible(t *testing.T) {
+	f := newAccessFixture(t)
+	tests := []struct {
+		media *Media
+		key   string
+		want  bool
+	}{
+		{f.p1, "", true},
+		{f.q1, "", false},
+		{f.q1, "alice", true},
+		{f.x1, "fam", true},
+		{f.x1, "friend", true},
+		{f.x2, "friend", true},
+		{f.x2, "fam", false},
+		{f.x2, "", false},
+		{f.x2, "alice", false},
+		{f.x3, "stranger", false},
+		{f.x3, "fam", false},
+		{f.p2, "", false},
+		{f.p2, "alice", true},
+		{f.p2, "fam", false},
+	}
+	for _, tt := range tests {
+		got, err := f.r.MediaVisible(tt.media, Viewer{Key: tt.key})
+		if err != nil {
+			t.Fatalf("MediaVisible(%s, %q) error = %v", tt.media.SourceFilename, tt.key, err)
+		}
+		if got != tt.want {
+			t.Errorf("MediaVisible(%s, %q) = %v, want %v", tt.media.SourceFilename, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestVisibilityFolders(t *testing.T) {
+	f := newAccessFixture(t)
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"", false},
+		{"alice", false},
+		{"fam", true},
+		{"kids", true},
+		{"friend", true},
+		{"stranger", false},
+	}
+	for _, tt := range tests {
+		v, err := f.r.VisibilityFor(Viewer{Key: tt.key})
+		if err != nil {
+			t.Fatalf("VisibilityFor(%q) error = %v", tt.key, err)
+		}
+		if got := v.Folder(f.family); got != tt.want {
+			t.Errorf("VisibilityFor(%q).Folder(family) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestListVisibleMedia(t *testing.T) {
+	f := newAccessFixture(t)
+	tests := []struct {
+		album *Album
+		key   string
+		want  []*Media
+	}{
+		{f.public, "", []*Media{f.p1}},
+		{f.public, "alice", []*Media{f.p1, f.p2}},
+		{f.xmas, "fam", []*Media{f.x1}},
+		{f.xmas, "friend", []*Media{f.x1, f.x2}},
+		{f.xmas, "stranger", nil},
+		{f.xmas, "", nil},
+	}
+	for _, tt := range tests {
+		visible, err := f.r.AlbumVisible(tt.album.ID, Viewer{Key: tt.key})
+		if err != nil {
+			t.Fatalf("AlbumVisible(%s, %q) error = %v", tt.album.Path, tt.key, err)
+		}
+		list, err := f.r.ListVisibleMedia(tt.album.ID, 0, visible, tt.key, Page{})
+		if err != nil {
+			t.Fatalf("ListVisibleMedia(%s, %q) error = %v", tt.album.Path, tt.key, err)
+		}
+		var got, want []string
+		for _, m := range list {
+			got = append(got, m.SourceFilename)
+		}
+		for _, m := range tt.want {
+			want = append(want, m.SourceFilename)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ListVisibleMedia(%s, %q) = %v, want %v", tt.album.Path, tt.key, got, want)
+		}
+		n, err := f.r.CountVisibleMedia(tt.album.ID, visible, tt.key)
+		if err != nil || n != len(want) {
+			t.Errorf("CountVisibleMedia(%s, %q) = %d, %v, want %d", tt.album.Path, tt.key, n, err, len(want))
+		}
+	}
+}
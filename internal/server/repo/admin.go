@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AdminToken is a row of the admin_tokens table: a named credential for the
+// ingest API. The token itself is only known when it is created.
+type AdminToken struct {
+	ID      int64
+	Name    string
+	Created time.Time
+}
+
+// hashToken returns the hex SHA-256 hash of a token.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAdminToken mints an admin token with the given name and returns it.
+func (r *Repo) CreateAdminToken(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty admin token name")
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = r.db.Exec("INSERT INTO admin_tokens (name, token_hash, created) VALUES (?, ?, ?)",
+		name, hashToken(token), time.Now().Unix())
+	if err != nil {
+		return "", fmt.Errorf("failed to create admin token %s: %v", name, err)
+	}
+	return token, nil
+}
+
+// AdminTokens returns all admin tokens, sorted by name.
+func (r *Repo) AdminTokens() ([]AdminToken, error) {
+	rows, err := r.db.Query("SELECT id, name, created FROM admin_tokens ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []AdminToken{}
+	for rows.Next() {
+		var t AdminToken
+		var created int64
+		if err := rows.Scan(&t.ID, &t.Name, &created); err != nil {
+			return nil, err
+		}
+		t.Created = time.Unix(created, 0).UTC()
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAdminToken deletes the admin token with the given name.
+func (r *Repo) RevokeAdminToken(name string) error {
+	res, err := r.db.Exec("DELETE FROM admin_tokens WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CheckAdminToken returns the admin token matching token, or ErrNotFound. Tokens
+// are looked up by hash, so lookup time does not depend on how much of a guess
+// matches a stored token.
+func (r *Repo) CheckAdminToken(token string) (*AdminToken, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	var t AdminToken
+	var created int64
+	err := r.db.QueryRow("SELECT id, name, created FROM admin_tokens WHERE token_hash = ?", hashToken(token)).Scan(
+		&t.ID, &t.Name, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	t.Created = time.Unix(created, 0).UTC()
+	return &t, nil
+}
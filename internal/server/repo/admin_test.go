@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdminTokens(t *testing.T) {
+	r := newTestRepo(t)
+	token, err := r.CreateAdminToken("laptop")
+	if err != nil {
+		t.Fatalf("CreateAdminToken() error = %v", err)
+	}
+	if _, err := r.CreateAdminToken("laptop"); err == nil {
+		t.Errorf("CreateAdminToken() with duplicate name error = nil, want error")
+	}
+	if _, err := r.CreateAdminToken(""); err == nil {
+		t.Errorf("CreateAdminToken() with empty name error = nil, want error")
+	}
+
+	got, err := r.CheckAdminToken(token)
+	if err != nil || got.Name != "laptop" {
+		t.Errorf("CheckAdminToken() = %+v, %v, want laptop", got, err)
+	}
+	for _, bad := range []string{"", "nope", token + "x"} {
+		if _, err := r.CheckAdminToken(bad); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CheckAdminToken(%q) error = %v, want ErrNotFound", bad, err)
+		}
+	}
+
+	tokens, err := r.AdminTokens()
+	if err != nil || len(tokens) != 1 || tokens[0].Name != "laptop" || time.Since(tokens[0].Created) > time.Minute {
+		t.Errorf("AdminTokens() = %+v, %v", tokens, err)
+	}
+	if err := r.RevokeAdminToken("laptop"); err != nil {
+		t.Fatalf("RevokeAdminToken() error = %v", err)
+	}
+	if _, err := r.CheckAdminToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CheckAdminToken() after revocation error = %v, want ErrNotFound", err)
+	}
+	if err := r.RevokeAdminToken("laptop"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeAdminToken() again error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	r := newTestRepo(t)
+	b := mustUpsertAlbum(t, r, &Album{Path: "b"})
+	a := mustUpsertAlbum(t, r, &Album{Path: "a"})
+	mustUpsertMedia(t, r, &Media{AlbumID: b.ID, SourceFilename: "1.jpg", SourceHash: "h1", SourceSize: 1, MetadataDigest: "d1", MTime: time.Unix(0, 0)})
+	mustUpsertMedia(t, r, &Media{AlbumID: a.ID, SourceFilename: "2.jpg", SourceHash: "h2", SourceSize: 2, MTime: time.Unix(0, 0)})
+	got, err := r.Sources()
+	if err != nil {
+		t.Fatalf("Sources() error = %v", err)
+	}
+	want := []Source{{"a", "2.jpg", "h2", 2, ""}, {"b", "1.jpg", "h1", 1, "d1"}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Sources() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AlbumText is the title and blurb of an album in one language (a row of album_text).
+type AlbumText struct {
+	Language string
+	Title    string
+	Blurb    string
+}
+
+// Album is a row of the albums table, with its texts, aliases, tags and access keys.
+type Album struct {
+	ID       int64
+	FolderID int64
+	// Name is the last component of Path.
+	Name string
+	// Path is the slash-separated path of the album relative to the collection root.
+	Path string
+	// TitlePhotoID and HighlightPhotoID are media IDs, or 0 if unset.
+	TitlePhotoID     int64
+	HighlightPhotoID int64
+	// SortOrder is the media sort order (see metadata.SortOrderCode).
+	SortOrder int
+	// Language is the BCP 47 code of the language of the text whose Language is
+	// "" (the default language of the collection), or "" if unknown.
+	Language string
+	Texts    []AlbumText
+	Aliases  []string
+	Tags     []string
+	// Access is the list of access keys granted access to the album. Empty means public.
+	Access []string
+}
+
+const albumColumns = "id, folder_id, name, path, COALESCE(title_photo, 0), COALESCE(highlight_photo, 0), sort_order, language"
+
+// queryAlbums returns the albums selected by a WHERE/ORDER BY clause, without details.
+func queryAlbums(q querier, clause string, args ...any) ([]*Album, error) {
+	rows, err := q.Query("SELECT "+albumColumns+" FROM albums "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	albums := []*Album{}
+	for rows.Next() {
+		var a Album
+		if err := rows.Scan(&a.ID, &a.FolderID, &a.Name, &a.Path, &a.TitlePhotoID, &a.HighlightPhotoID, &a.SortOrder, &a.Language); err != nil {
+			return nil, err
+		}
+		albums = append(albums, &a)
+	}
+	return albums, rows.Err()
+}
+
+// loadAlbumDetails fills in the texts, aliases, tags and access keys of an album.
+func loadAlbumDetails(q querier, a *Album) error {
+	rows, err := q.Query("SELECT language_code, title, COALESCE(blurb, '') FROM album_text WHERE album_id = ? ORDER BY language_code", a.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	a.Texts = []AlbumText{}
+	for rows.Next() {
+		var t AlbumText
+		if err := rows.Scan(&t.Language, &t.Title, &t.Blurb); err != nil {
+			return err
+		}
+		a.Texts = append(a.Texts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if a.Aliases, err = aliases(q, a.ID); err != nil {
+		return err
+	}
+	if a.Tags, err = linkedNames(q, "album_tags", "album_id", a.ID, "tags", "name", "tag_id"); err != nil {
+		return err
+	}
+	a.Access, err = linkedNames(q, "album_access", "album_id", a.ID, "access_keys", "key", "access_key_id")
+	return err
+}
+
+func aliases(q querier, albumID int64) ([]string, error) {
+	rows, err := q.Query("SELECT alias FROM album_aliases WHERE album_id = ? ORDER BY alias", albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	l := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		l = append(l, s)
+	}
+	return l, rows.Err()
+}
+
+// albumBy returns the single album selected by clause, with details.
+func (r *Repo) albumBy(clause string, args ...any) (*Album, error) {
+	albums, err := queryAlbums(r.db, clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(albums) == 0 {
+		return nil, ErrNotFound
+	}
+	if err := loadAlbumDetails(r.db, albums[0]); err != nil {
+		return nil, err
+	}
+	return albums[0], nil
+}
+
+// AlbumByID returns the album with the given ID.
+func (r *Repo) AlbumByID(id int64) (*Album, error) {
+	return r.albumBy("WHERE id = ?", id)
+}
+
+// AlbumByPath returns the album at path.
+func (r *Repo) AlbumByPath(path string) (*Album, error) {
+	return r.albumBy("WHERE path = ?", path)
+}
+
+// AlbumByAlias returns the album with the given alias.
+func (r *Repo) AlbumByAlias(alias string) (*Album, error) {
+	return r.albumBy("WHERE id = (SELECT album_id FROM album_aliases WHERE alias = ?)", alias)
+}
+
+// UpsertAlbum inserts or updates the album at a.Path, creating its parent folders
+// if needed and replacing its texts, aliases, tags and access keys. Sets a.ID,
+// a.FolderID and a.Name. Returns ErrConflict if an alias is already taken, by
+// another album or by an earlier entry of a.Aliases.
+func (r *Repo) UpsertAlbum(a *Album) error {
+	if a.Path == "" {
+		return fmt.Errorf("empty album path")
+	}
+	return r.tx(func(tx *sql.Tx) error {
+		folderID, err := ensureFolder(tx, parentPath(a.Path))
+		if err != nil {
+			return err
+		}
+		var id int64
+		err = tx.QueryRow(`INSERT INTO albums (folder_id, name, path, title_photo, highlight_photo, sort_order, language)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(path) DO UPDATE SET
+				folder_id = excluded.folder_id,
+				name = excluded.name,
+				title_photo = excluded.title_photo,
+				highlight_photo = excluded.highlight_photo,
+				sort_order = excluded.sort_order,
+				language = excluded.language
+			RETURNING id`,
+			folderID, baseName(a.Path), a.Path, nullID(a.TitlePhotoID), nullID(a.HighlightPhotoID), a.SortOrder, a.Language).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to upsert album %s: %v", a.Path, err)
+		}
+		if _, err := tx.Exec("DELETE FROM album_text WHERE album_id = ?", id); err != nil {
+			return err
+		}
+		for _, t := range a.Texts {
+			if _, err := tx.Exec("INSERT INTO album_text (album_id, language_code, title, blurb) VALUES (?, ?, ?, ?)",
+				id, t.Language, t.Title, nullString(t.Blurb)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM album_aliases WHERE album_id = ?", id); err != nil {
+			return err
+		}
+		for _, alias := range a.Aliases {
+			var owner string
+			err := tx.QueryRow(`SELECT albums.path FROM album_aliases JOIN albums ON albums.id = album_aliases.album_id
+				WHERE alias = ?`, alias).Scan(&owner)
+			if err == nil {
+				return fmt.Errorf("%w: alias %s of album %s is already an alias of album %s", ErrConflict, alias, a.Path, owner)
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO album_aliases (alias, album_id) VALUES (?, ?)", alias, id); err != nil {
+				return fmt.Errorf("failed to add alias %s to album %s: %v", alias, a.Path, err)
+			}
+		}
+		if err := replaceLinks(tx, "album_tags", "album_id", id, "tags", "name", "tag_id", a.Tags); err != nil {
+			return err
+		}
+		if err := replaceLinks(tx, "album_access", "album_id", id, "access_keys", "key", "access_key_id", a.Access); err != nil {
+			return err
+		}
+		a.ID, a.FolderID, a.Name = id, folderID, baseName(a.Path)
+		return nil
+	})
+}
+
+// AlbumPaths returns the paths of all albums, sorted.
+func (r *Repo) AlbumPaths() ([]string, error) {
+	rows, err := r.db.Query("SELECT path FROM albums ORDER BY path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	paths := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// DeleteAlbum deletes the album at path with its media items, and returns the
+// renditions of the deleted media items. Its folders are kept, with their share
+// links and access grants. Deleting a missing album is not an error.
+func (r *Repo) DeleteAlbum(path string) ([]Blob, error) {
+	var blobs []Blob
+	err := r.tx(func(tx *sql.Tx) error {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM albums WHERE path = ?", path).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if blobs, err = queryBlobs(tx, "WHERE media_id IN (SELECT id FROM media WHERE album_id = ?) ORDER BY media_id, max_dim", id); err != nil {
+			return err
+		}
+		// The title and highlight photos refer to media items of the album.
+		if _, err := tx.Exec("UPDATE albums SET title_photo = NULL, highlight_photo = NULL WHERE id = ?", id); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM albums WHERE id = ?", id)
+		return err
+	})
+	return blobs, err
+}
+
+// nullID maps the ID 0 to NULL.
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUpsertAlbum(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{
+		Path:      "2019/europe/paris",
+		SortOrder: 4,
+		Language:  "en",
+		Texts:     []AlbumText{{Title: "Paris", Blurb: "Spring trip"}, {Language: "fr", Title: "Paris"}},
+		Aliases:   []string{"paris"},
+		Tags:      []string{"travel", "france"},
+		Access:    []string{"family"},
+	})
+	if a.ID == 0 || a.Name != "paris" {
+		t.Fatalf("UpsertAlbum() set ID %d, name %s", a.ID, a.Name)
+	}
+
+	got, err := r.AlbumByPath("2019/europe/paris")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	want := &Album{
+		ID:        a.ID,
+		FolderID:  a.FolderID,
+		Name:      "paris",
+		Path:      "2019/europe/paris",
+		SortOrder: 4,
+		Language:  "en",
+		Texts:     []AlbumText{{Title: "Paris", Blurb: "Spring trip"}, {Language: "fr", Title: "Paris"}},
+		Aliases:   []string{"paris"},
+		Tags:      []string{"france", "travel"},
+		Access:    []string{"family"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AlbumByPath() = %+v, want %+v", got, want)
+	}
+	if got, err := r.AlbumByAlias("paris"); err != nil || got.ID != a.ID {
+		t.Errorf("AlbumByAlias() = %v, %v, want album %d", got, err, a.ID)
+	}
+
+	// Updating replaces details and keeps the ID.
+	mustUpsertAlbum(t, r, &Album{Path: "2019/europe/paris", SortOrder: 0, Aliases: []string{"paris-2019"}})
+	got, err = r.AlbumByPath("2019/europe/paris")
+	if err != nil {
+		t.Fatalf("AlbumByPath() error = %v", err)
+	}
+	if got.ID != a.ID || got.SortOrder != 0 || got.Language != "" || len(got.Texts) != 0 || len(got.Tags) != 0 || len(got.Access) != 0 ||
+		!reflect.DeepEqual(got.Aliases, []string{"paris-2019"}) {
+		t.Errorf("AlbumByPath() after update = %+v", got)
+	}
+	if _, err := r.AlbumByAlias("paris"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AlbumByAlias(old alias) error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.AlbumByPath("nowhere"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AlbumByPath(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpsertAlbumAliasConflict(t *testing.T) {
+	r := newTestRepo(t)
+	mustUpsertAlbum(t, r, &Album{Path: "a", Aliases: []string{"x"}})
+	if err := r.UpsertAlbum(&Album{Path: "b", Aliases: []string{"x"}}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("UpsertAlbum() with duplicate alias error = %v, want ErrConflict", err)
+	}
+	// The failed upsert is rolled back entirely.
+	if _, err := r.AlbumByPath("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AlbumByPath(b) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListChildren(t *testing.T) {
+	r := newTestRepo(t)
+	mustUpsertAlbum(t, r, &Album{Path: "2019/paris"})
+	mustUpsertAlbum(t, r, &Album{Path: "2019/berlin"})
+	mustUpsertAlbum(t, r, &Album{Path: "2019/italy/rome"})
+	mustUpsertAlbum(t, r, &Album{Path: "misc"})
+
+	root, err := r.FolderByPath("")
+	if err != nil {
+		t.Fatalf("FolderByPath(root) error = %v", err)
+	}
+	folders, albums, err := r.ListChildren(root.ID)
+	if err != nil {
+		t.Fatalf("ListChildren() error = %v", err)
+	}
+	if len(folders) != 1 || folders[0].Path != "2019" || folders[0].ParentID != root.ID {
+		t.Errorf("ListChildren(root) folders = %+v", folders)
+	}
+	if len(albums) != 1 || albums[0].Path != "misc" {
+		t.Errorf("ListChildren(root) albums = %+v", albums)
+	}
+
+	folders, albums, err = r.ListChildren(folders[0].ID)
+	if err != nil {
+		t.Fatalf("ListChildren() error = %v", err)
+	}
+	if len(folders) != 1 || folders[0].Path != "2019/italy" || folders[0].Name != "italy" {
+		t.Errorf("ListChildren(2019) folders = %+v", folders)
+	}
+	names := []string{}
+	for _, a := range albums {
+		names = append(names, a.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"berlin", "paris"}) {
+		t.Errorf("ListChildren(2019) albums = %v, want [berlin paris]", names)
+	}
+}
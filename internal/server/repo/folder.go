@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Folder is a row of the folders table: a non-album directory of the collection.
+// The root folder has an empty path.
+type Folder struct {
+	ID int64
+	// ParentID is the ID of the parent folder, or 0 for the root folder.
+	ParentID int64
+	// Name is the last component of Path.
+	Name string
+	// Path is the slash-separated path of the folder relative to the collection root.
+	Path string
+}
+
+// ensureFolder returns the ID of the folder at path, creating it and its ancestors if needed.
+func ensureFolder(q querier, path string) (int64, error) {
+	var id int64
+	err := q.QueryRow("SELECT id FROM folders WHERE path = ?", path).Scan(&id)
+	if err == nil {
+		return id, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	var parentID sql.NullInt64
+	if path != "" {
+		pid, err := ensureFolder(q, parentPath(path))
+		if err != nil {
+			return 0, err
+		}
+		parentID = sql.NullInt64{Int64: pid, Valid: true}
+	}
+	res, err := q.Exec("INSERT INTO folders (parent_id, name, path) VALUES (?, ?, ?)", parentID, baseName(path), path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create folder %s: %v", path, err)
+	}
+	return res.LastInsertId()
+}
+
+const folderColumns = "id, COALESCE(parent_id, 0), name, path"
+
+func scanFolder(row interface{ Scan(...any) error }) (*Folder, error) {
+	var f Folder
+	if err := row.Scan(&f.ID, &f.ParentID, &f.Name, &f.Path); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// FolderByPath returns the folder at path ("" for the root folder).
+func (r *Repo) FolderByPath(path string) (*Folder, error) {
+	return scanFolder(r.db.QueryRow("SELECT "+folderColumns+" FROM folders WHERE path = ?", path))
+}
+
+// FolderByID returns the folder with the given ID.
+func (r *Repo) FolderByID(id int64) (*Folder, error) {
+	return scanFolder(r.db.QueryRow("SELECT "+folderColumns+" FROM folders WHERE id = ?", id))
+}
+
+// ListChildren returns the subfolders and albums of a folder, each sorted by name.
+func (r *Repo) ListChildren(folderID int64) ([]*Folder, []*Album, error) {
+	rows, err := r.db.Query("SELECT "+folderColumns+" FROM folders WHERE parent_id = ? ORDER BY name", folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	folders := []*Folder{}
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		folders = append(folders, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+	albums, err := queryAlbums(r.db, "WHERE folder_id = ? ORDER BY name", folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range albums {
+		if err := loadAlbumDetails(r.db, a); err != nil {
+			return nil, nil, err
+		}
+	}
+	return folders, albums, nil
+}
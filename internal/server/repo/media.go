@@ -0,0 +1,453 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MediaType is the type of a media item (media.media_type).
+type MediaType int
+
+const (
+	Photo MediaType = 0
+	Video MediaType = 1
+)
+
+// MediaText is the title and caption of a media item in one language (a row of media_text).
+type MediaText struct {
+	Language string
+	Title    string
+	Caption  string
+}
+
+// Media is a row of the media table (a logical photo or video), with its texts,
+// tags and access keys. Optional EXIF fields are nil, empty or zero when unknown.
+type Media struct {
+	ID             int64
+	AlbumID        int64
+	Type           MediaType
+	DisplayName    string
+	SourceFilename string
+	// SourceHash and SourceSize are the hex SHA-256 hash and the size of the
+	// source file, or "" and 0 if unknown.
+	SourceHash string
+	SourceSize int64
+	// MetadataDigest is an opaque digest of the metadata with which the client
+	// published the media item, or "" if unknown.
+	MetadataDigest string
+	MTime          time.Time
+	ExifTime       time.Time
+	Latitude       *float64
+	Longitude      *float64
+	Camera         string
+	Lens           string
+	FocalLength    *float64
+	ExposureTime   *float64
+	Aperture       *float64
+	ISO            *int
+	Flash          *int
+	// Portrait is true for portrait orientation (media.orientation = 1).
+	Portrait bool
+	Texts    []MediaText
+	Tags     []string
+	// Access is the list of access keys to which this media item is restricted,
+	// among those with access to its album. Empty means the access of the album.
+	Access []string
+}
+
+// Blob is a row of the blobs table: one rendition of a media item.
+type Blob struct {
+	MediaID     int64
+	ContentHash string
+	BucketName  string
+	ObjectKey   string
+	Width       int
+	Height      int
+	MaxDim      int
+}
+
+const mediaColumns = `id, album_id, media_type, display_name, source_filename, source_hash, source_size, metadata_digest, mtime,
+	exif_time, latitude, longitude, camera, lens, focal_length, exposure_time, aperture, iso, flash, orientation`
+
+// queryMedia returns the media selected by a WHERE/ORDER BY clause, with details.
+func queryMedia(q querier, clause string, args ...any) ([]*Media, error) {
+	rows, err := q.Query("SELECT "+mediaColumns+" FROM media "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Media{}
+	for rows.Next() {
+		var m Media
+		var mtime int64
+		var exifTime, iso, flash, orientation sql.NullInt64
+		var lat, long, focal, exposure, aperture sql.NullFloat64
+		var camera, lens sql.NullString
+		if err := rows.Scan(&m.ID, &m.AlbumID, &m.Type, &m.DisplayName, &m.SourceFilename, &m.SourceHash, &m.SourceSize, &m.MetadataDigest, &mtime, &exifTime,
+			&lat, &long, &camera, &lens, &focal, &exposure, &aperture, &iso, &flash, &orientation); err != nil {
+			return nil, err
+		}
+		m.MTime = time.Unix(mtime, 0).UTC()
+		if exifTime.Valid {
+			m.ExifTime = time.Unix(exifTime.Int64, 0).UTC()
+		}
+		m.Latitude, m.Longitude = floatPtr(lat), floatPtr(long)
+		m.FocalLength, m.ExposureTime, m.Aperture = floatPtr(focal), floatPtr(exposure), floatPtr(aperture)
+		m.ISO, m.Flash = intPtr(iso), intPtr(flash)
+		m.Camera, m.Lens = camera.String, lens.String
+		m.Portrait = orientation.Int64 == 1
+		list = append(list, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for _, m := range list {
+		if err := loadMediaDetails(q, m); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// loadMediaDetails fills in the texts, tags and access keys of a media item.
+func loadMediaDetails(q querier, m *Media) error {
+	rows, err := q.Query("SELECT language_code, title, COALESCE(caption, '') FROM media_text WHERE media_id = ? ORDER BY language_code", m.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	m.Texts = []MediaText{}
+	for rows.Next() {
+		var t MediaText
+		if err := rows.Scan(&t.Language, &t.Title, &t.Caption); err != nil {
+			return err
+		}
+		m.Texts = append(m.Texts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if m.Tags, err = linkedNames(q, "media_tags", "media_id", m.ID, "tags", "name", "tag_id"); err != nil {
+		return err
+	}
+	m.Access, err = linkedNames(q, "media_access", "media_id", m.ID, "access_keys", "key", "access_key_id")
+	return err
+}
+
+// mediaOrder maps albums.sort_order values to ORDER BY clauses. Media without
+// an EXIF time sort by mtime, and ties are broken by filename, as in the client
+// (see metadata.SortMedia).
+var mediaOrder = map[int]string{
+	0: "source_filename",
+	1: "source_filename DESC",
+	2: "mtime, source_filename",
+	3: "mtime DESC, source_filename DESC",
+	4: "COALESCE(exif_time, mtime), source_filename",
+	5: "COALESCE(exif_time, mtime) DESC, source_filename DESC",
+}
+
+// ListMedia returns a page of the media of an album in the given sort order.
+func (r *Repo) ListMedia(albumID int64, sortOrder int, page Page) ([]*Media, error) {
+	order, ok := mediaOrder[sortOrder]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort order %d", sortOrder)
+	}
+	return queryMedia(r.db, "WHERE album_id = ? ORDER BY "+order+page.limitClause(), albumID)
+}
+
+// visibleClause returns a WHERE condition (and its arguments) restricting a media
+// query to the items visible to key: if their album is visible to it, unrestricted
+// items and those restricted to key; otherwise, none.
+func visibleClause(albumVisible bool, key string) (string, []any) {
+	if !albumVisible {
+		return ` AND 0`, nil
+	}
+	return ` AND (NOT EXISTS (SELECT 1 FROM media_access ma WHERE ma.media_id = media.id) OR
+		id IN (SELECT ma.media_id FROM media_access ma
+			JOIN access_keys k ON k.id = ma.access_key_id WHERE k.key = ?))`, []any{key}
+}
+
+// ListVisibleMedia is like ListMedia, but returns only the media items visible to
+// key ("" for none), given whether the album is visible to it (see AlbumVisible).
+func (r *Repo) ListVisibleMedia(albumID int64, sortOrder int, albumVisible bool, key string, page Page) ([]*Media, error) {
+	order, ok := mediaOrder[sortOrder]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort order %d", sortOrder)
+	}
+	cond, args := visibleClause(albumVisible, key)
+	return queryMedia(r.db, "WHERE album_id = ?"+cond+" ORDER BY "+order+page.limitClause(), append([]any{albumID}, args...)...)
+}
+
+// MediaByID returns the media item with the given ID.
+func (r *Repo) MediaByID(id int64) (*Media, error) {
+	list, err := queryMedia(r.db, "WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return list[0], nil
+}
+
+// CountMedia returns the number of media items in an album.
+func (r *Repo) CountMedia(albumID int64) (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM media WHERE album_id = ?", albumID).Scan(&n)
+	return n, err
+}
+
+// Source describes the source file of a media item.
+type Source struct {
+	// Album is the path of the album.
+	Album string
+	// Filename is the source filename.
+	Filename string
+	// Hash and Size are the hex SHA-256 hash and the size of the source file.
+	Hash string
+	Size int64
+	// MetadataDigest is the digest of the metadata of the media item (see Media).
+	MetadataDigest string
+}
+
+// Sources returns the source files of all media items, sorted by album and filename.
+func (r *Repo) Sources() ([]Source, error) {
+	rows, err := r.db.Query(`SELECT a.path, m.source_filename, m.source_hash, m.source_size, m.metadata_digest
+		FROM media m JOIN albums a ON a.id = m.album_id ORDER BY a.path, m.source_filename`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sources := []Source{}
+	for rows.Next() {
+		var src Source
+		if err := rows.Scan(&src.Album, &src.Filename, &src.Hash, &src.Size, &src.MetadataDigest); err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
+
+// MediaBySource returns the media item of an album with the given source filename.
+func (r *Repo) MediaBySource(albumID int64, filename string) (*Media, error) {
+	list, err := queryMedia(r.db, "WHERE album_id = ? AND source_filename = ?", albumID, filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return list[0], nil
+}
+
+// DeleteMedia deletes the media item of an album with the given source filename,
+// with its renditions, and returns the deleted renditions. Deleting a missing
+// media item is not an error.
+func (r *Repo) DeleteMedia(albumID int64, filename string) ([]Blob, error) {
+	var blobs []Blob
+	err := r.tx(func(tx *sql.Tx) error {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM media WHERE album_id = ? AND source_filename = ?", albumID, filename).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if blobs, err = queryBlobs(tx, "WHERE media_id = ? ORDER BY max_dim", id); err != nil {
+			return err
+		}
+		// Albums may refer to the media item as title or highlight photo.
+		for _, col := range []string{"title_photo", "highlight_photo"} {
+			if _, err := tx.Exec("UPDATE albums SET "+col+" = NULL WHERE "+col+" = ?", id); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("DELETE FROM media WHERE id = ?", id)
+		return err
+	})
+	return blobs, err
+}
+
+// CountVisibleMedia returns the number of media items in an album visible to key
+// (see ListVisibleMedia).
+func (r *Repo) CountVisibleMedia(albumID int64, albumVisible bool, key string) (int, error) {
+	var n int
+	cond, args := visibleClause(albumVisible, key)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM media WHERE album_id = ?"+cond, append([]any{albumID}, args...)...).Scan(&n)
+	return n, err
+}
+
+// UpsertMedia inserts or updates the media item identified by (m.AlbumID, m.SourceFilename),
+// replacing its texts, tags and access keys. Sets m.ID.
+func (r *Repo) UpsertMedia(m *Media) error {
+	return r.tx(func(tx *sql.Tx) error {
+		return upsertMedia(tx, m)
+	})
+}
+
+// PutMedia upserts a media item (see UpsertMedia) and replaces its renditions
+// with blobs (see ReplaceBlobs) in one transaction. Sets m.ID and the MediaID of
+// blobs. Returns the renditions that the media item had before.
+func (r *Repo) PutMedia(m *Media, blobs []Blob) ([]Blob, error) {
+	var old []Blob
+	err := r.tx(func(tx *sql.Tx) error {
+		if err := upsertMedia(tx, m); err != nil {
+			return err
+		}
+		var err error
+		if old, err = queryBlobs(tx, "WHERE media_id = ? ORDER BY max_dim", m.ID); err != nil {
+			return err
+		}
+		for i := range blobs {
+			blobs[i].MediaID = m.ID
+		}
+		return replaceBlobs(tx, m.ID, blobs)
+	})
+	return old, err
+}
+
+// upsertMedia implements UpsertMedia in transaction tx.
+func upsertMedia(tx *sql.Tx, m *Media) error {
+	var exifTime sql.NullInt64
+	if !m.ExifTime.IsZero() {
+		exifTime = sql.NullInt64{Int64: m.ExifTime.Unix(), Valid: true}
+	}
+	orientation := 0
+	if m.Portrait {
+		orientation = 1
+	}
+	var id int64
+	err := tx.QueryRow(`INSERT INTO media (album_id, media_type, display_name, source_filename, source_hash, source_size,
+			metadata_digest, mtime, exif_time, latitude, longitude, camera, lens, focal_length, exposure_time, aperture, iso,
+			flash, orientation)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(album_id, source_filename) DO UPDATE SET
+			media_type = excluded.media_type,
+			display_name = excluded.display_name,
+			source_hash = excluded.source_hash,
+			source_size = excluded.source_size,
+			metadata_digest = excluded.metadata_digest,
+			mtime = excluded.mtime,
+			exif_time = excluded.exif_time,
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			camera = excluded.camera,
+			lens = excluded.lens,
+			focal_length = excluded.focal_length,
+			exposure_time = excluded.exposure_time,
+			aperture = excluded.aperture,
+			iso = excluded.iso,
+			flash = excluded.flash,
+			orientation = excluded.orientation
+		RETURNING id`,
+		m.AlbumID, m.Type, m.DisplayName, m.SourceFilename, m.SourceHash, m.SourceSize, m.MetadataDigest, m.MTime.Unix(), exifTime,
+		m.Latitude, m.Longitude, nullString(m.Camera), nullString(m.Lens),
+		m.FocalLength, m.ExposureTime, m.Aperture, m.ISO, m.Flash, orientation).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to upsert media %s: %v", m.SourceFilename, err)
+	}
+	if _, err := tx.Exec("DELETE FROM media_text WHERE media_id = ?", id); err != nil {
+		return err
+	}
+	for _, t := range m.Texts {
+		if _, err := tx.Exec("INSERT INTO media_text (media_id, title, caption, language_code) VALUES (?, ?, ?, ?)",
+			id, t.Title, nullString(t.Caption), t.Language); err != nil {
+			return err
+		}
+	}
+	if err := replaceLinks(tx, "media_tags", "media_id", id, "tags", "name", "tag_id", m.Tags); err != nil {
+		return err
+	}
+	if err := replaceLinks(tx, "media_access", "media_id", id, "access_keys", "key", "access_key_id", m.Access); err != nil {
+		return err
+	}
+	m.ID = id
+	return nil
+}
+
+// queryBlobs returns the blobs selected by a WHERE/ORDER BY clause.
+func queryBlobs(q querier, clause string, args ...any) ([]Blob, error) {
+	rows, err := q.Query(`SELECT media_id, content_hash, bucket_name, object_key, width, height, max_dim
+		FROM blobs `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blobs := []Blob{}
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.MediaID, &b.ContentHash, &b.BucketName, &b.ObjectKey, &b.Width, &b.Height, &b.MaxDim); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
+// Blobs returns the renditions of a media item, from smallest to largest.
+func (r *Repo) Blobs(mediaID int64) ([]Blob, error) {
+	return queryBlobs(r.db, "WHERE media_id = ? ORDER BY max_dim", mediaID)
+}
+
+// BlobsByHash returns the renditions with the given content hash. Identical
+// source files have identical renditions, so there may be several.
+func (r *Repo) BlobsByHash(hash string) ([]Blob, error) {
+	return queryBlobs(r.db, "WHERE content_hash = ? ORDER BY media_id", hash)
+}
+
+// UnreferencedBlobs returns the blobs of a list whose content hash no longer
+// belongs to any media item, i.e., whose stored objects may be deleted.
+func (r *Repo) UnreferencedBlobs(blobs []Blob) ([]Blob, error) {
+	l := []Blob{}
+	for _, b := range blobs {
+		var used bool
+		if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM blobs WHERE content_hash = ?)", b.ContentHash).Scan(&used); err != nil {
+			return nil, err
+		}
+		if !used {
+			l = append(l, b)
+		}
+	}
+	return l, nil
+}
+
+// ReplaceBlobs replaces the renditions of a media item.
+func (r *Repo) ReplaceBlobs(mediaID int64, blobs []Blob) error {
+	return r.tx(func(tx *sql.Tx) error {
+		return replaceBlobs(tx, mediaID, blobs)
+	})
+}
+
+// replaceBlobs implements ReplaceBlobs in transaction tx.
+func replaceBlobs(tx *sql.Tx, mediaID int64, blobs []Blob) error {
+	if _, err := tx.Exec("DELETE FROM blobs WHERE media_id = ?", mediaID); err != nil {
+		return err
+	}
+	for _, b := range blobs {
+		if _, err := tx.Exec(`INSERT INTO blobs (media_id, content_hash, bucket_name, object_key, height, width, max_dim)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			mediaID, b.ContentHash, b.BucketName, b.ObjectKey, b.Height, b.Width, b.MaxDim); err != nil {
+			return fmt.Errorf("failed to add blob %s: %v", b.ContentHash, err)
+		}
+	}
+	return nil
+}
+
+func floatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
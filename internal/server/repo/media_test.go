@@ -0,0 +1,258 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpsertMedia(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "paris"})
+	lat, long, focal := 48.8584, 2.2945, 35.0
+	iso := 200
+	m := mustUpsertMedia(t, r, &Media{
+		AlbumID:        a.ID,
+		Type:           Photo,
+		DisplayName:    "IMG_1",
+		SourceFilename: "IMG_1.jpg",
+		MTime:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExifTime:       time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC),
+		Latitude:       &lat,
+		Longitude:      &long,
+		Camera:         "Canon EOS R",
+		FocalLength:    &focal,
+		ISO:            &iso,
+		Portrait:       true,
+		Texts:          []MediaText{{Language: "en", Title: "Eiffel Tower", Caption: "From Trocadéro"}},
+		Tags:           []string{"tower"},
+		Access:         []string{"family"},
+	})
+
+	got, err := r.MediaByID(m.ID)
+	if err != nil {
+		t.Fatalf("MediaByID() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("MediaByID() = %+v, want %+v", got, m)
+	}
+
+	// Upserting the same source file updates the row in place.
+	m2 := mustUpsertMedia(t, r, &Media{AlbumID: a.ID, Type: Photo, DisplayName: "IMG_1", SourceFilename: "IMG_1.jpg", MTime: m.MTime})
+	if m2.ID != m.ID {
+		t.Errorf("UpsertMedia() ID = %d, want %d", m2.ID, m.ID)
+	}
+	got, err = r.MediaByID(m.ID)
+	if err != nil {
+		t.Fatalf("MediaByID() error = %v", err)
+	}
+	if got.Latitude != nil || got.Camera != "" || len(got.Texts) != 0 || len(got.Tags) != 0 || got.Portrait {
+		t.Errorf("MediaByID() after update = %+v", got)
+	}
+}
+
+func TestListMedia(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "paris"})
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	// Same layout as the client-side ordering test: c.jpg and e.jpg have no EXIF
+	// time and fall back to their (equal) mtime.
+	for _, m := range []*Media{
+		{SourceFilename: "a.jpg", MTime: day(1), ExifTime: day(9)},
+		{SourceFilename: "b.jpg", MTime: day(8), ExifTime: day(3)},
+		{SourceFilename: "c.jpg", MTime: day(5)},
+		{SourceFilename: "d.jpg", MTime: day(6), ExifTime: day(2)},
+		{SourceFilename: "e.jpg", MTime: day(5)},
+	} {
+		m.AlbumID, m.DisplayName = a.ID, m.SourceFilename
+		mustUpsertMedia(t, r, m)
+	}
+	tests := []struct {
+		sortOrder int
+		page      Page
+		want      []string
+	}{
+		{0, Page{}, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"}},
+		{1, Page{}, []string{"e.jpg", "d.jpg", "c.jpg", "b.jpg", "a.jpg"}},
+		{2, Page{}, []string{"a.jpg", "c.jpg", "e.jpg", "d.jpg", "b.jpg"}},
+		{3, Page{}, []string{"b.jpg", "d.jpg", "e.jpg", "c.jpg", "a.jpg"}},
+		{4, Page{}, []string{"d.jpg", "b.jpg", "c.jpg", "e.jpg", "a.jpg"}},
+		{5, Page{}, []string{"a.jpg", "e.jpg", "c.jpg", "b.jpg", "d.jpg"}},
+		{4, Page{Offset: 1, Limit: 2}, []string{"b.jpg", "c.jpg"}},
+		{4, Page{Offset: 4, Limit: 2}, []string{"a.jpg"}},
+	}
+	for _, tt := range tests {
+		list, err := r.ListMedia(a.ID, tt.sortOrder, tt.page)
+		if err != nil {
+			t.Fatalf("ListMedia() error = %v", err)
+		}
+		got := []string{}
+		for _, m := range list {
+			got = append(got, m.SourceFilename)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListMedia(%d, %+v) = %v, want %v", tt.sortOrder, tt.page, got, tt.want)
+		}
+	}
+	if n, err := r.CountMedia(a.ID); err != nil || n != 5 {
+		t.Errorf("CountMedia() = %d, %v, want 5", n, err)
+	}
+	if _, err := r.ListMedia(a.ID, 6, Page{}); err == nil {
+		t.Errorf("ListMedia() with invalid sort order error = nil, want error")
+	}
+}
+
+func TestReplaceBlobs(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "paris"})
+	m := mustUpsertMedia(t, r, &Media{AlbumID: a.ID, DisplayName: "a", SourceFilename: "a.jpg", MTime: time.Unix(0, 0)})
+	blobs := []Blob{
+		{MediaID: m.ID, ContentHash: "h2048", BucketName: "b", ObjectKey: "k2048", Width: 2048, Height: 1365, MaxDim: 2048},
+		{MediaID: m.ID, ContentHash: "h256", BucketName: "b", ObjectKey: "k256", Width: 256, Height: 171, MaxDim: 256},
+	}
+	if err := r.ReplaceBlobs(m.ID, blobs); err != nil {
+		t.Fatalf("ReplaceBlobs() error = %v", err)
+	}
+	got, err := r.Blobs(m.ID)
+	if err != nil {
+		t.Fatalf("Blobs() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []Blob{blobs[1], blobs[0]}) {
+		t.Errorf("Blobs() = %+v", got)
+	}
+	// A duplicate content hash fails and leaves the previous blobs in place.
+	if err := r.ReplaceBlobs(m.ID, []Blob{blobs[0], blobs[0]}); err == nil {
+		t.Errorf("ReplaceBlobs() with duplicate hash error = nil, want error")
+	}
+	if got, _ := r.Blobs(m.ID); len(got) != 2 {
+		t.Errorf("Blobs() after failed replace = %+v", got)
+	}
+	if b, err := r.BlobsByHash("h256"); err != nil || !reflect.DeepEqual(b, []Blob{blobs[1]}) {
+		t.Errorf("BlobsByHash() = %+v, %v, want [%+v]", b, err, blobs[1])
+	}
+	// Another media item may share a rendition.
+	m2 := mustUpsertMedia(t, r, &Media{AlbumID: a.ID, DisplayName: "b", SourceFilename: "b.jpg", MTime: time.Unix(0, 0)})
+	shared := blobs[1]
+	shared.MediaID = m2.ID
+	if err := r.ReplaceBlobs(m2.ID, []Blob{shared}); err != nil {
+		t.Fatalf("ReplaceBlobs() with shared hash error = %v", err)
+	}
+	if b, err := r.BlobsByHash("h256"); err != nil || len(b) != 2 {
+		t.Errorf("BlobsByHash() = %+v, %v, want 2 blobs", b, err)
+	}
+	if err := r.ReplaceBlobs(m.ID, nil); err != nil {
+		t.Fatalf("ReplaceBlobs(nil) error = %v", err)
+	}
+	if got, _ := r.Blobs(m.ID); len(got) != 0 {
+		t.Errorf("Blobs() after clearing = %+v", got)
+	}
+	// h256 is still used by m2.
+	if got, err := r.UnreferencedBlobs(blobs); err != nil || !reflect.DeepEqual(got, []Blob{blobs[0]}) {
+		t.Errorf("UnreferencedBlobs() = %+v, %v, want [%+v]", got, err, blobs[0])
+	}
+}
+
+func TestPutMedia(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "paris"})
+	m := &Media{AlbumID: a.ID, DisplayName: "a", SourceFilename: "a.jpg", SourceHash: "s1", MTime: time.Unix(0, 0)}
+	blobs := []Blob{{ContentHash: "h1", BucketName: "b", ObjectKey: "k1", Width: 256, Height: 171, MaxDim: 256}}
+	old, err := r.PutMedia(m, blobs)
+	if err != nil || len(old) != 0 {
+		t.Fatalf("PutMedia() = %+v, %v, want no blobs", old, err)
+	}
+	if got, err := r.Blobs(m.ID); err != nil || !reflect.DeepEqual(got, blobs) || blobs[0].MediaID != m.ID {
+		t.Errorf("Blobs() = %+v, %v, want %+v", got, err, blobs)
+	}
+	m.SourceHash = "s2"
+	old, err = r.PutMedia(m, []Blob{{ContentHash: "h2", BucketName: "b", ObjectKey: "k2", Width: 256, Height: 171, MaxDim: 256}})
+	if err != nil || !reflect.DeepEqual(old, blobs) {
+		t.Fatalf("PutMedia() = %+v, %v, want %+v", old, err, blobs)
+	}
+	// A failure to replace the blobs rolls back the media item too.
+	m.SourceHash = "s3"
+	if _, err := r.PutMedia(m, []Blob{blobs[0], blobs[0]}); err == nil {
+		t.Errorf("PutMedia() with duplicate hash error = nil, want error")
+	}
+	if got, err := r.MediaBySource(a.ID, "a.jpg"); err != nil || got.SourceHash != "s2" {
+		t.Errorf("MediaBySource() after failed put = %+v, %v, want source hash s2", got, err)
+	}
+	if got, _ := r.Blobs(m.ID); len(got) != 1 || got[0].ContentHash != "h2" {
+		t.Errorf("Blobs() after failed put = %+v, want h2", got)
+	}
+}
+
+func TestDeleteMedia(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "paris"})
+	m := mustUpsertMedia(t, r, &Media{AlbumID: a.ID, DisplayName: "a", SourceFilename: "a.jpg", SourceHash: "abc", SourceSize: 3, MTime: time.Unix(0, 0)})
+	blobs := []Blob{{MediaID: m.ID, ContentHash: "h256", BucketName: "b", ObjectKey: "k256", Width: 256, Height: 171, MaxDim: 256}}
+	if err := r.ReplaceBlobs(m.ID, blobs); err != nil {
+		t.Fatalf("ReplaceBlobs() error = %v", err)
+	}
+	if got, err := r.MediaBySource(a.ID, "a.jpg"); err != nil || got.ID != m.ID || got.SourceHash != "abc" || got.SourceSize != 3 {
+		t.Errorf("MediaBySource() = %+v, %v", got, err)
+	}
+
+	a.TitlePhotoID, a.HighlightPhotoID = m.ID, m.ID
+	mustUpsertAlbum(t, r, a)
+
+	deleted, err := r.DeleteMedia(a.ID, "a.jpg")
+	if err != nil {
+		t.Fatalf("DeleteMedia() error = %v", err)
+	}
+	if !reflect.DeepEqual(deleted, blobs) {
+		t.Errorf("DeleteMedia() = %+v, want %+v", deleted, blobs)
+	}
+	if _, err := r.MediaBySource(a.ID, "a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MediaBySource() after delete error = %v, want ErrNotFound", err)
+	}
+	if got, _ := r.BlobsByHash("h256"); len(got) != 0 {
+		t.Errorf("BlobsByHash() after delete = %+v", got)
+	}
+	if got, err := r.AlbumByID(a.ID); err != nil || got.TitlePhotoID != 0 || got.HighlightPhotoID != 0 {
+		t.Errorf("AlbumByID() after delete = %+v, %v, want no title or highlight photo", got, err)
+	}
+	// Deleting again is a no-op.
+	if deleted, err := r.DeleteMedia(a.ID, "a.jpg"); err != nil || len(deleted) != 0 {
+		t.Errorf("DeleteMedia() again = %+v, %v", deleted, err)
+	}
+}
+
+func TestDeleteAlbum(t *testing.T) {
+	r := newTestRepo(t)
+	a := mustUpsertAlbum(t, r, &Album{Path: "europe/paris", Aliases: []string{"paris"}})
+	mustUpsertAlbum(t, r, &Album{Path: "europe/rome"})
+	m := mustUpsertMedia(t, r, &Media{AlbumID: a.ID, DisplayName: "a", SourceFilename: "a.jpg", SourceHash: "abc", SourceSize: 3, MTime: time.Unix(0, 0)})
+	blobs := []Blob{{MediaID: m.ID, ContentHash: "h256", BucketName: "b", ObjectKey: "k256", Width: 256, Height: 171, MaxDim: 256}}
+	if err := r.ReplaceBlobs(m.ID, blobs); err != nil {
+		t.Fatalf("ReplaceBlobs() error = %v", err)
+	}
+	a.TitlePhotoID = m.ID
+	mustUpsertAlbum(t, r, a)
+
+	deleted, err := r.DeleteAlbum("europe/paris")
+	if err != nil {
+		t.Fatalf("DeleteAlbum() error = %v", err)
+	}
+	if !reflect.DeepEqual(deleted, blobs) {
+		t.Errorf("DeleteAlbum() = %+v, want %+v", deleted, blobs)
+	}
+	if _, err := r.AlbumByAlias("paris"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AlbumByAlias() after delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.MediaByID(m.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MediaByID() after delete error = %v, want ErrNotFound", err)
+	}
+	if got, _ := r.BlobsByHash("h256"); len(got) != 0 {
+		t.Errorf("BlobsByHash() after delete = %+v", got)
+	}
+	if got, err := r.AlbumPaths(); err != nil || !reflect.DeepEqual(got, []string{"europe/rome"}) {
+		t.Errorf("AlbumPaths() = %v, %v, want [europe/rome]", got, err)
+	}
+	// Deleting again is a no-op.
+	if deleted, err := r.DeleteAlbum("europe/paris"); err != nil || len(deleted) != 0 {
+		t.Errorf("DeleteAlbum() again = %+v, %v", deleted, err)
+	}
+}
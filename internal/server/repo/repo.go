@@ -0,0 +1,126 @@
+// Package repo is the data-access layer over the LBX SQLite schema
+// (see internal/server/db/migrations).
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned when a requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Repo reads and writes LBX metadata. All methods that modify several rows do so in a transaction.
+type Repo struct {
+	db *sql.DB
+}
+
+// New returns a repository over db, whose schema must be up to date (see db.Check).
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// tx runs fn in a transaction, committing if it returns nil and rolling back otherwise.
+func (r *Repo) tx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Page selects a range of rows.
+type Page struct {
+	// Offset is the number of rows to skip.
+	Offset int
+	// Limit is the maximum number of rows to return, or 0 for no limit.
+	Limit int
+}
+
+// limitClause returns the LIMIT/OFFSET clause of a page.
+func (p Page) limitClause() string {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = -1 // No limit in SQLite.
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, max(p.Offset, 0))
+}
+
+// ensureNamed returns the ID of the row of table (tags or access_keys) whose
+// column col equals value, inserting the row if needed.
+func ensureNamed(q querier, table, col, value string) (int64, error) {
+	if _, err := q.Exec("INSERT OR IGNORE INTO "+table+" ("+col+") VALUES (?)", value); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := q.QueryRow("SELECT id FROM "+table+" WHERE "+col+" = ?", value).Scan(&id)
+	return id, err
+}
+
+// replaceLinks replaces the rows of a link table (e.g., album_tags) for owner ownerID
+// with links to the named rows (e.g., tags) in values.
+func replaceLinks(q querier, linkTable, ownerCol string, ownerID int64, targetTable, targetCol, linkCol string, values []string) error {
+	if _, err := q.Exec("DELETE FROM "+linkTable+" WHERE "+ownerCol+" = ?", ownerID); err != nil {
+		return err
+	}
+	for _, v := range values {
+		id, err := ensureNamed(q, targetTable, targetCol, v)
+		if err != nil {
+			return err
+		}
+		if _, err := q.Exec("INSERT INTO "+linkTable+" ("+ownerCol+", "+linkCol+") VALUES (?, ?)", ownerID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkedNames returns the sorted names linked to owner ownerID through a link table.
+func linkedNames(q querier, linkTable, ownerCol string, ownerID int64, targetTable, targetCol, linkCol string) ([]string, error) {
+	rows, err := q.Query("SELECT t."+targetCol+" FROM "+linkTable+" l JOIN "+targetTable+" t ON t.id = l."+linkCol+
+		" WHERE l."+ownerCol+" = ? ORDER BY t."+targetCol, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// parentPath returns the parent of a slash-separated path ("" for top-level paths).
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// baseName returns the last component of a slash-separated path.
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// nullString maps "" to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
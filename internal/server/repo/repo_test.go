@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/maxpoletto/lbx/internal/server/db"
+)
+
+// newTestRepo returns a repository over a fresh, fully migrated temporary database.
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	conn, err := db.Open(filepath.Join(t.TempDir(), "lbx.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, _, err := db.Migrate(conn); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return New(conn)
+}
+
+// mustUpsertAlbum upserts an album and returns it.
+func mustUpsertAlbum(t *testing.T, r *Repo, a *Album) *Album {
+	t.Helper()
+	if err := r.UpsertAlbum(a); err != nil {
+		t.Fatalf("UpsertAlbum(%s) error = %v", a.Path, err)
+	}
+	return a
+}
+
+// mustUpsertMedia upserts a media item and returns it.
+func mustUpsertMedia(t *testing.T, r *Repo, m *Media) *Media {
+	t.Helper()
+	if err := r.UpsertMedia(m); err != nil {
+		t.Fatalf("UpsertMedia(%s) error = %v", m.SourceFilename, err)
+	}
+	return m
+}
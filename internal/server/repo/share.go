@@ -0,0 +1,185 @@
+package repo
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShareLink is a row of the share_links table: a token granting access to one
+// album, or to one folder and everything below it, until it expires or is revoked.
+type ShareLink struct {
+	ID    int64
+	Token string
+	// AlbumID is the ID of the shared album, or 0 if a folder is shared.
+	AlbumID int64
+	// FolderID is the ID of the shared folder, or 0 if an album is shared.
+	FolderID int64
+	// Path is the path of the shared album or folder.
+	Path    string
+	Created time.Time
+	Expires time.Time
+	// MaxViews is the number of times the link may be opened, or 0 for no limit.
+	MaxViews int
+	// Views is the number of times the link has been opened.
+	Views   int
+	Revoked bool
+}
+
+// covers reports whether the link grants access to the album with the given ID and path.
+func (l *ShareLink) covers(albumID int64, albumPath string) bool {
+	if l.AlbumID != 0 {
+		return l.AlbumID == albumID
+	}
+	return l.Path == "" || strings.HasPrefix(albumPath, l.Path+"/")
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+const shareLinkColumns = `s.id, s.token, COALESCE(s.album_id, 0), COALESCE(s.folder_id, 0),
+	COALESCE(a.path, f.path), s.created, s.expires, COALESCE(s.max_views, 0), s.views, s.revoked`
+
+const shareLinkFrom = ` FROM share_links s
+	LEFT JOIN albums a ON a.id = s.album_id
+	LEFT JOIN folders f ON f.id = s.folder_id `
+
+func scanShareLink(row interface{ Scan(...any) error }) (*ShareLink, error) {
+	var l ShareLink
+	var created, expires int64
+	if err := row.Scan(&l.ID, &l.Token, &l.AlbumID, &l.FolderID, &l.Path, &created, &expires, &l.MaxViews, &l.Views, &l.Revoked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	l.Created, l.Expires = time.Unix(created, 0).UTC(), time.Unix(expires, 0).UTC()
+	return &l, nil
+}
+
+// CreateShareLink mints a share link for the album l.AlbumID or the folder
+// l.FolderID, valid until l.Expires and for at most l.MaxViews views (0 for no
+// limit). Sets l.ID, l.Token, l.Path and l.Created.
+func (r *Repo) CreateShareLink(l *ShareLink) error {
+	if (l.AlbumID == 0) == (l.FolderID == 0) {
+		return fmt.Errorf("share link must have exactly one of album and folder")
+	}
+	if l.MaxViews < 0 {
+		return fmt.Errorf("invalid share link view limit %d", l.MaxViews)
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	created := time.Now().UTC().Truncate(time.Second)
+	maxViews := sql.NullInt64{Int64: int64(l.MaxViews), Valid: l.MaxViews > 0}
+	res, err := r.db.Exec(`INSERT INTO share_links (token, album_id, folder_id, created, expires, max_views)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token, nullID(l.AlbumID), nullID(l.FolderID), created.Unix(), l.Expires.Unix(), maxViews)
+	if err != nil {
+		return fmt.Errorf("failed to create share link: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	got, err := r.ShareLinkByID(id)
+	if err != nil {
+		return err
+	}
+	*l = *got
+	return nil
+}
+
+// ShareLinkByID returns the share link with the given ID, whether or not it is valid.
+func (r *Repo) ShareLinkByID(id int64) (*ShareLink, error) {
+	return scanShareLink(r.db.QueryRow("SELECT "+shareLinkColumns+shareLinkFrom+"WHERE s.id = ?", id))
+}
+
+// ShareLinks returns all share links, including expired and revoked ones, newest first.
+func (r *Repo) ShareLinks() ([]*ShareLink, error) {
+	rows, err := r.db.Query("SELECT " + shareLinkColumns + shareLinkFrom + "ORDER BY s.created DESC, s.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	links := []*ShareLink{}
+	for rows.Next() {
+		l, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink revokes the share link with the given token.
+func (r *Repo) RevokeShareLink(token string) error {
+	res, err := r.db.Exec("UPDATE share_links SET revoked = 1 WHERE token = ?", token)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ShareLinkBySession returns the share link opened in the session with the given
+// ID (see RedeemShareLink) if it is neither revoked nor expired at time now, nor
+// viewed more often than allowed (e.g., because the limit was lowered), and
+// ErrNotFound otherwise.
+func (r *Repo) ShareLinkBySession(session string, now time.Time) (*ShareLink, error) {
+	return scanShareLink(r.db.QueryRow("SELECT "+shareLinkColumns+shareLinkFrom+
+		`JOIN share_sessions ss ON ss.share_link_id = s.id
+		WHERE ss.id = ? AND s.revoked = 0 AND s.expires > ? AND (s.max_views IS NULL OR s.views <= s.max_views)`,
+		session, now.Unix()))
+}
+
+// RedeemShareLink opens the share link with the given token: if it is neither
+// revoked nor expired at time now and has views left, it counts a view and
+// starts a session, whose ID it returns with the link. Returns ErrNotFound
+// otherwise. The viewer keeps using the link through the session (see
+// ShareLinkBySession), without counting further views.
+func (r *Repo) RedeemShareLink(token string, now time.Time) (*ShareLink, string, error) {
+	session, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	var l *ShareLink
+	err = r.tx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE share_links SET views = views + 1
+			WHERE token = ? AND revoked = 0 AND expires > ? AND (max_views IS NULL OR views < max_views)`,
+			token, now.Unix())
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		if l, err = scanShareLink(tx.QueryRow("SELECT "+shareLinkColumns+shareLinkFrom+"WHERE s.token = ?", token)); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO share_sessions (id, share_link_id, created) VALUES (?, ?, ?)", session, l.ID, now.Unix())
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return l, session, nil
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCreateShareLink(t *testing.T) {
+	f := newAccessFixture(t)
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	album := &ShareLink{AlbumID: f.private.ID, Expires: expires, MaxViews: 3}
+	if err := f.r.CreateShareLink(album); err != nil {
+		t.Fatalf("CreateShareLink(album) error = %v", err)
+	}
+	if len(album.Token) < 32 || album.Path != "private" || !album.Expires.Equal(expires) || album.MaxViews != 3 || album.Created.IsZero() {
+		t.Errorf("CreateShareLink(album) = %+v", album)
+	}
+	folder := &ShareLink{FolderID: f.family.ID, Expires: expires}
+	if err := f.r.CreateShareLink(folder); err != nil {
+		t.Fatalf("CreateShareLink(folder) error = %v", err)
+	}
+	if folder.Token == album.Token || folder.Path != "family" || folder.MaxViews != 0 {
+		t.Errorf("CreateShareLink(folder) = %+v", folder)
+	}
+
+	for _, l := range []*ShareLink{
+		{Expires: expires},
+		{AlbumID: f.private.ID, FolderID: f.family.ID, Expires: expires},
+		{AlbumID: f.private.ID, Expires: expires, MaxViews: -1},
+	} {
+		if err := f.r.CreateShareLink(l); err == nil {
+			t.Errorf("CreateShareLink(%+v) error = nil, want error", l)
+		}
+	}
+
+	links, err := f.r.ShareLinks()
+	if err != nil {
+		t.Fatalf("ShareLinks() error = %v", err)
+	}
+	if len(links) != 2 || links[0].ID != folder.ID || links[1].ID != album.ID {
+		t.Errorf("ShareLinks() = %+v", links)
+	}
+}
+
+func TestShareLinkValidity(t *testing.T) {
+	f := newAccessFixture(t)
+	now := time.Now()
+	l := &ShareLink{AlbumID: f.private.ID, Expires: now.Add(time.Hour), MaxViews: 2}
+	if err := f.r.CreateShareLink(l); err != nil {
+		t.Fatalf("CreateShareLink() error = %v", err)
+	}
+
+	if _, _, err := f.r.RedeemShareLink(l.Token, now.Add(2*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemShareLink() after expiry error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := f.r.RedeemShareLink("nope", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemShareLink(unknown) error = %v, want ErrNotFound", err)
+	}
+
+	// Each redemption counts a view, up to the limit, and starts a session.
+	sessions := []string{}
+	for i := 1; i <= 2; i++ {
+		got, session, err := f.r.RedeemShareLink(l.Token, now)
+		if err != nil {
+			t.Fatalf("RedeemShareLink() #%d error = %v", i, err)
+		}
+		if got.Views != i || len(session) < 32 || slices.Contains(sessions, session) {
+			t.Errorf("RedeemShareLink() #%d = %d views, session %q", i, got.Views, session)
+		}
+		sessions = append(sessions, session)
+	}
+	if _, _, err := f.r.RedeemShareLink(l.Token, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemShareLink() past view limit error = %v, want ErrNotFound", err)
+	}
+	// Viewers who opened the link keep using it through their session, but the
+	// token is not a session.
+	if got, err := f.r.ShareLinkBySession(sessions[0], now); err != nil || got.ID != l.ID {
+		t.Errorf("ShareLinkBySession() at view limit = %+v, %v, want link %d", got, err, l.ID)
+	}
+	for _, session := range []string{l.Token, "nope"} {
+		if _, err := f.r.ShareLinkBySession(session, now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ShareLinkBySession(%q) error = %v, want ErrNotFound", session, err)
+		}
+	}
+	if _, err := f.r.ShareLinkBySession(sessions[0], now.Add(2*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ShareLinkBySession() after expiry error = %v, want ErrNotFound", err)
+	}
+	// Sessions end if the limit is exceeded (e.g., lowered after the views).
+	if _, err := f.r.db.Exec("UPDATE share_links SET max_views = 1 WHERE id = ?", l.ID); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if _, err := f.r.ShareLinkBySession(sessions[0], now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ShareLinkBySession() past view limit error = %v, want ErrNotFound", err)
+	}
+	if _, err := f.r.db.Exec("UPDATE share_links SET max_views = 2 WHERE id = ?", l.ID); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	if err := f.r.RevokeShareLink(l.Token); err != nil {
+		t.Fatalf("RevokeShareLink() error = %v", err)
+	}
+	if _, err := f.r.ShareLinkBySession(sessions[1], now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ShareLinkBySession() after revocation error = %v, want ErrNotFound", err)
+	}
+	if err := f.r.RevokeShareLink("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeShareLink(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestShareLinkAccess(t *testing.T) {
+	f := newAccessFixture(t)
+	expires := time.Now().Add(time.Hour)
+	album := &ShareLink{AlbumID: f.private.ID, Expires: expires}
+	folder := &ShareLink{FolderID: f.family.ID, Expires: expires}
+	for _, l := range []*ShareLink{album, folder} {
+		if err := f.r.CreateShareLink(l); err != nil {
+			t.Fatalf("CreateShareLink() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		share *ShareLink
+		album *Album
+		want  bool
+	}{
+		{album, f.private, true},
+		{album, f.xmas, false},
+		{album, f.public, true},
+		{folder, f.xmas, true},
+		{folder, f.kids, true},
+		{folder, f.private, false},
+	}
+	for _, tt := range tests {
+		v := Viewer{Share: tt.share}
+		got, err := f.r.AlbumVisible(tt.album.ID, v)
+		if err != nil {
+			t.Fatalf("AlbumVisible(%s) error = %v", tt.album.Path, err)
+		}
+		if got != tt.want {
+			t.Errorf("AlbumVisible(%s) with share of %s = %v, want %v", tt.album.Path, tt.share.Path, got, tt.want)
+		}
+		vis, err := f.r.VisibilityFor(v)
+		if err != nil {
+			t.Fatalf("VisibilityFor() error = %v", err)
+		}
+		if got := vis.Album(tt.album.ID); got != tt.want {
+			t.Errorf("VisibilityFor().Album(%s) with share of %s = %v, want %v", tt.album.Path, tt.share.Path, got, tt.want)
+		}
+	}
+
+	// A share link does not reveal media restricted to specific keys.
+	if ok, err := f.r.MediaVisible(f.x1, Viewer{Share: folder}); err != nil || !ok {
+		t.Errorf("MediaVisible(x1) with share of family = %v, %v, want true", ok, err)
+	}
+	if ok, err := f.r.MediaVisible(f.x2, Viewer{Share: folder}); err != nil || ok {
+		t.Errorf("MediaVisible(x2) with share of family = %v, %v, want false", ok, err)
+	}
+}
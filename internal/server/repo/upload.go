@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConflict is returned when an update is based on stale state.
+var ErrConflict = errors.New("conflict")
+
+// Upload is a row of the uploads table: a resumable upload session for the blob
+// with content hash Hash.
+type Upload struct {
+	ID    int64
+	Token string
+	Hash  string
+	Size  int64
+	// Received is the number of bytes received so far.
+	Received int64
+	Created  time.Time
+	// Updated is the time the last chunk was received.
+	Updated time.Time
+}
+
+const uploadColumns = "id, token, content_hash, size, received, created, updated"
+
+func scanUpload(row interface{ Scan(...any) error }) (*Upload, error) {
+	var u Upload
+	var created, updated int64
+	if err := row.Scan(&u.ID, &u.Token, &u.Hash, &u.Size, &u.Received, &created, &updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	u.Created, u.Updated = time.Unix(created, 0).UTC(), time.Unix(updated, 0).UTC()
+	return &u, nil
+}
+
+// CreateUpload starts an upload session for a blob of the given hash and size,
+// or returns the existing session for that blob, so that an interrupted upload
+// can be resumed without remembering its token. Returns ErrConflict if there is a
+// session for a blob of the same hash but a different size.
+func (r *Repo) CreateUpload(hash string, size int64, now time.Time) (*Upload, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid upload size %d", size)
+	}
+	u, err := r.UploadByHash(hash)
+	if err == nil {
+		if u.Size != size {
+			return nil, ErrConflict
+		}
+		return u, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	return scanUpload(r.db.QueryRow(`INSERT INTO uploads (token, content_hash, size, created, updated)
+		VALUES (?, ?, ?, ?, ?) RETURNING `+uploadColumns,
+		token, hash, size, now.Unix(), now.Unix()))
+}
+
+// UploadByHash returns the upload session for the blob with the given hash.
+func (r *Repo) UploadByHash(hash string) (*Upload, error) {
+	return scanUpload(r.db.QueryRow("SELECT "+uploadColumns+" FROM uploads WHERE content_hash = ?", hash))
+}
+
+// UploadByToken returns the upload session with the given token.
+func (r *Repo) UploadByToken(token string) (*Upload, error) {
+	return scanUpload(r.db.QueryRow("SELECT "+uploadColumns+" FROM uploads WHERE token = ?", token))
+}
+
+// AdvanceUpload records that the upload session with the given token received
+// the bytes from offset from to offset to. Returns ErrConflict if the session
+// did not have exactly from bytes, e.g., because a chunk was sent twice.
+func (r *Repo) AdvanceUpload(token string, from, to int64, now time.Time) error {
+	res, err := r.db.Exec("UPDATE uploads SET received = ?, updated = ? WHERE token = ? AND received = ? AND ? <= size",
+		to, now.Unix(), token, from, to)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := r.UploadByToken(token); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+// DeleteUpload deletes the upload session with the given token. Deleting a
+// missing session is not an error.
+func (r *Repo) DeleteUpload(token string) error {
+	_, err := r.db.Exec("DELETE FROM uploads WHERE token = ?", token)
+	return err
+}
+
+// StaleUploads returns the upload sessions that received no data since before.
+func (r *Repo) StaleUploads(before time.Time) ([]*Upload, error) {
+	rows, err := r.db.Query("SELECT "+uploadColumns+" FROM uploads WHERE updated < ? ORDER BY id", before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	uploads := []*Upload{}
+	for rows.Next() {
+		u, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUploads(t *testing.T) {
+	r := newTestRepo(t)
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	u, err := r.CreateUpload("h1", 100, t0)
+	if err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+	if u.Token == "" || u.Hash != "h1" || u.Size != 100 || u.Received != 0 || !u.Created.Equal(t0) {
+		t.Errorf("CreateUpload() = %+v", u)
+	}
+	if err := r.AdvanceUpload(u.Token, 0, 60, t0.Add(time.Minute)); err != nil {
+		t.Fatalf("AdvanceUpload() error = %v", err)
+	}
+	// A repeated or out-of-order chunk conflicts, as does one past the end.
+	for _, c := range []struct{ from, to int64 }{{0, 60}, {70, 100}, {60, 101}} {
+		if err := r.AdvanceUpload(u.Token, c.from, c.to, t0); !errors.Is(err, ErrConflict) {
+			t.Errorf("AdvanceUpload(%d, %d) error = %v, want ErrConflict", c.from, c.to, err)
+		}
+	}
+	if err := r.AdvanceUpload("nope", 0, 1, t0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AdvanceUpload() of missing session error = %v, want ErrNotFound", err)
+	}
+
+	// Creating the session again resumes it.
+	got, err := r.CreateUpload("h1", 100, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateUpload() again error = %v", err)
+	}
+	if got.Token != u.Token || got.Received != 60 || !got.Updated.Equal(t0.Add(time.Minute)) {
+		t.Errorf("CreateUpload() again = %+v", got)
+	}
+	if _, err := r.CreateUpload("h1", 99, t0); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateUpload() with different size error = %v, want ErrConflict", err)
+	}
+	if got, err := r.UploadByToken(u.Token); err != nil || got.Hash != "h1" {
+		t.Errorf("UploadByToken() = %+v, %v", got, err)
+	}
+
+	if _, err := r.CreateUpload("h2", 10, t0.Add(2*time.Minute)); err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+	stale, err := r.StaleUploads(t0.Add(90 * time.Second))
+	if err != nil || len(stale) != 1 || stale[0].Token != u.Token {
+		t.Errorf("StaleUploads() = %+v, %v, want h1", stale, err)
+	}
+	if err := r.DeleteUpload(u.Token); err != nil {
+		t.Fatalf("DeleteUpload() error = %v", err)
+	}
+	if _, err := r.UploadByHash("h1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UploadByHash() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := r.DeleteUpload(u.Token); err != nil {
+		t.Errorf("DeleteUpload() again error = %v", err)
+	}
+}
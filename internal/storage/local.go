@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// tmpPrefix prefixes the names of files being written by Put.
+const tmpPrefix = ".tmp-"
+
+// Local stores objects as files in a directory. The key of an object is its
+// path relative to the directory.
+type Local struct {
+	root string
+}
+
+// NewLocal returns a backend that stores objects under dir, creating it if needed.
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("storage: empty directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	return &Local{root: dir}, nil
+}
+
+// path returns the file path of an object.
+func (l *Local) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object to a temporary file and renames it into place, so that
+// readers never observe a partially written object.
+func (l *Local) Put(key string, r io.Reader, size int64) error {
+	fn, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fn), tmpPrefix)
+	if err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("storage: failed to write %s: %v", key, err)
+	}
+	if n != size {
+		return fmt.Errorf("storage: wrote %d bytes to %s, expected %d", n, key, size)
+	}
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	return l.GetRange(key, 0, -1)
+}
+
+func (l *Local) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	fn, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("storage: %v", err)
+		}
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+func (l *Local) Stat(key string) (*ObjectInfo, error) {
+	fn, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// Delete removes the file of an object, and any parent directories left empty.
+func (l *Local) Delete(key string) error {
+	fn, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("storage: %v", err)
+	}
+	for dir := filepath.Dir(fn); dir != filepath.Clean(l.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List walks the directory of prefix (up to its last slash) rather than the whole
+// root, so listing an album does not read the files of the others.
+func (l *Local) List(prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	start := l.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		// No key is in a directory with an invalid name.
+		fn, err := l.path(prefix[:i])
+		if err != nil {
+			return objects, nil
+		}
+		start = fn
+	}
+	// A missing directory, or a file in its path, holds no objects.
+	if _, err := os.Stat(start); os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return objects, nil
+	}
+	err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	// WalkDir order differs from key order (e.g., "a/b" vs. "a.b").
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// notFound maps file-not-found errors to ErrNotFound.
+func notFound(err error) error {
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("storage: %v", err)
+}
+
+// limitedReadCloser combines a limited reader with the closer of the underlying file.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	testBackend(t, s)
+}
+
+func TestLocalPutSizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	if err := s.Put("a/b.jpg", strings.NewReader("abc"), 4); err == nil {
+		t.Fatalf("Put() error = nil, want size mismatch error")
+	}
+	// Neither the object nor the temporary file may be left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected empty directory, got %v", entries)
+	}
+}
+
+func TestLocalDeleteRemovesEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	if err := s.Put("a/b/c.jpg", strings.NewReader("c"), 1); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Delete("a/b/c.jpg"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("Expected empty directories to be removed, got %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Expected root directory to remain, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3 backend.
+type S3Config struct {
+	// Endpoint is the base URL of the S3 service. Default is "https://s3.REGION.amazonaws.com".
+	Endpoint string
+	// Region is the region of the bucket. Default is "us-east-1".
+	Region string
+	// Bucket is the name of the bucket.
+	Bucket string
+	// AccessKey and SecretKey are the credentials used to sign requests.
+	AccessKey string
+	SecretKey string
+	// PathStyle selects path-style URLs (ENDPOINT/BUCKET/KEY) rather than
+	// virtual-host-style URLs (BUCKET.ENDPOINT/KEY). Most S3-compatible
+	// services other than AWS require path-style URLs.
+	PathStyle bool
+	// Client is the HTTP client used to send requests. Default is http.DefaultClient.
+	Client *http.Client
+}
+
+// S3 stores objects in an S3 bucket, using the S3 REST API with SigV4 signing.
+type S3 struct {
+	base   *url.URL // URL of the bucket.
+	client *http.Client
+	signer *signer
+	now    func() time.Time
+}
+
+// NewS3 returns a backend that stores objects in an S3 bucket.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("storage: empty S3 bucket name")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("storage: missing S3 credentials")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(cfg.Endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %s", cfg.Endpoint)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	if cfg.PathStyle {
+		base.Path += "/" + cfg.Bucket
+	} else {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &S3{
+		base:   base,
+		client: cfg.Client,
+		signer: &signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: cfg.Region},
+		now:    time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of an object.
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.base
+	u.Path += "/" + key
+	u.RawPath = ""
+	return &u
+}
+
+// do signs and sends a request, returning the response if its status is one of ok.
+// Otherwise the response is closed and an error is returned.
+func (s *S3) do(req *http.Request, payloadHash string, ok ...int) (*http.Response, error) {
+	s.signer.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("storage: S3 %s %s: %s: %s", req.Method, req.URL.Path, e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("storage: S3 %s %s: %s", req.Method, req.URL.Path, resp.Status)
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), io.NopCloser(r))
+	if err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	req.ContentLength = size
+	if size == 0 {
+		// A zero ContentLength with a non-nil body means "unknown" (chunked encoding).
+		req.Body = http.NoBody
+	}
+	// The body is streamed, so it is not hashed.
+	resp, err := s.do(req, unsignedPayload, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	return s.GetRange(key, 0, -1)
+}
+
+func (s *S3) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	switch {
+	case length > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(req, emptyPayloadHash, http.StatusOK, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Stat(key string) (*ObjectInfo, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %v", err)
+	}
+	resp, err := s.do(req, emptyPayloadHash, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	info := &ObjectInfo{Key: key, Size: resp.ContentLength}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	return info, nil
+}
+
+func (s *S3) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	resp, err := s.do(req, emptyPayloadHash, http.StatusNoContent, http.StatusOK)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List uses ListObjectsV2, following continuation tokens until all objects are listed.
+func (s *S3) List(prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	token := ""
+	for {
+		u := *s.base
+		u.Path += "/"
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("storage: %v", err)
+		}
+		resp, err := s.do(req, emptyPayloadHash, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage: invalid S3 list response: %v", err)
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		if result.NextContinuationToken == "" {
+			return nil, fmt.Errorf("storage: truncated S3 list response without continuation token")
+		}
+		token = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory, path-style S3 server implementing the subset of the
+// REST API used by the S3 backend. It verifies request signatures.
+type fakeS3 struct {
+	bucket   string
+	signer   *signer
+	pageSize int // Maximum number of keys per list response.
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+func newFakeS3(bucket, accessKey, secretKey, region string) *fakeS3 {
+	return &fakeS3{
+		bucket:   bucket,
+		signer:   &signer{accessKey: accessKey, secretKey: secretKey, region: region},
+		pageSize: 2,
+		objects:  map[string]fakeObject{},
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.checkSignature(r) {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if path == "" && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			writeS3Error(w, http.StatusLengthRequired, "MissingContentLength")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[path] = fakeObject{data: data, modTime: time.Now().UTC().Truncate(time.Second)}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[path]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		http.ServeContent(w, r, "", obj.modTime, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list implements ListObjectsV2. Continuation tokens are the last key returned.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, q.Get("prefix")) && k > q.Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		obj := f.objects[k]
+		result.Contents = append(result.Contents, content{
+			Key:          k,
+			Size:         int64(len(obj.data)),
+			LastModified: obj.modTime.Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// checkSignature recomputes the signature of r from its signed headers.
+func (f *fakeS3) checkSignature(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	date, err := time.Parse(amzDateLayout, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	clone := &http.Request{
+		Method: r.Method,
+		URL:    &url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery},
+		Host:   r.Host,
+		Header: http.Header{},
+	}
+	for _, h := range strings.Split(signed, ";") {
+		if h != "host" {
+			clone.Header[http.CanonicalHeaderKey(h)] = r.Header.Values(h)
+		}
+	}
+	f.signer.sign(clone, r.Header.Get("X-Amz-Content-Sha256"), date)
+	return clone.Header.Get("Authorization") == auth
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, "<Error><Code>"+code+"</Code><Message>"+strconv.Itoa(status)+"</Message></Error>")
+}
+
+func newTestS3(t *testing.T, secretKey string) (*S3, *fakeS3) {
+	fake := newFakeS3("photos", "AKID", "SECRET", "eu-west-1")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	s, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "photos",
+		AccessKey: "AKID",
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3() error = %v", err)
+	}
+	return s, fake
+}
+
+func TestS3(t *testing.T) {
+	s, _ := newTestS3(t, "SECRET")
+	testBackend(t, s)
+}
+
+func TestS3BadCredentials(t *testing.T) {
+	s, _ := newTestS3(t, "WRONG")
+	err := s.Put("a.jpg", strings.NewReader("a"), 1)
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put() error = %v, want SignatureDoesNotMatch", err)
+	}
+	if _, err := s.Stat("a.jpg"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() error = %v, want signature error", err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package storage stores LBX media objects in a local directory or an S3-compatible bucket.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	// Key is the key of the object.
+	Key string
+	// Size is the size of the object in bytes.
+	Size int64
+	// ModTime is the time the object was last written.
+	ModTime time.Time
+}
+
+// Storage is a flat namespace of objects identified by slash-separated keys
+// (e.g., "albums/2019/paris/IMG_1234.jpg").
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any existing object.
+	Put(key string, r io.Reader, size int64) error
+	// Get returns a reader for the contents of an object.
+	Get(key string) (io.ReadCloser, error)
+	// GetRange returns a reader for length bytes of an object starting at offset.
+	// If length is negative, the reader extends to the end of the object.
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+	// Stat returns information about an object.
+	Stat(key string) (*ObjectInfo, error)
+	// Delete deletes an object. Deleting a missing object is not an error.
+	Delete(key string) error
+	// List returns all objects whose key starts with prefix, sorted by key.
+	List(prefix string) ([]ObjectInfo, error)
+}
+
+// Open opens a storage backend from a location string, one of:
+//
+//	/path/to/dir or file:///path/to/dir: a local directory (see NewLocal).
+//	s3://BUCKET?endpoint=URL&region=REGION&path_style=true: an S3 bucket (see NewS3).
+//	  endpoint defaults to AWS; region defaults to "us-east-1".
+//
+// accessKey and secretKey are the S3 credentials; they are ignored for local storage.
+func Open(location, accessKey, secretKey string) (Storage, error) {
+	if !strings.Contains(location, "://") {
+		return NewLocal(location)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage location %s: %v", location, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewLocal(u.Path)
+	case "s3":
+		q := u.Query()
+		return NewS3(S3Config{
+			Endpoint:  q.Get("endpoint"),
+			Region:    q.Get("region"),
+			Bucket:    u.Host,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			PathStyle: q.Get("path_style") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme %s", u.Scheme)
+	}
+}
+
+// checkKey checks that key is a valid object key: a non-empty, slash-separated
+// relative path without empty, "." or ".." components.
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("storage: empty key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("storage: invalid key %s", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testBackend exercises the Storage interface. It is shared by all backends.
+func testBackend(t *testing.T, s Storage) {
+	put := func(key, content string) {
+		t.Helper()
+		if err := s.Put(key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	read := func(r io.ReadCloser, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("read error = %v", err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		return string(data)
+	}
+
+	put("albums/paris/IMG_1.jpg", "0123456789")
+	put("albums/paris/IMG 2.jpg", "abc")
+	put("albums/paris.txt", "")
+	put("albums/rome/IMG_1.jpg", "rome")
+	put("other", "x")
+	// Overwrite.
+	put("albums/rome/IMG_1.jpg", "roma")
+
+	if got := read(s.Get("albums/rome/IMG_1.jpg")); got != "roma" {
+		t.Errorf("Get() = %q, want %q", got, "roma")
+	}
+	if got := read(s.Get("albums/paris.txt")); got != "" {
+		t.Errorf("Get() = %q, want empty", got)
+	}
+	rangeTests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, 4, "3456"},
+		{7, -1, "789"},
+		{0, 1, "0"},
+		{8, 10, "89"},
+	}
+	for _, tt := range rangeTests {
+		if got := read(s.GetRange("albums/paris/IMG_1.jpg", tt.offset, tt.length)); got != tt.want {
+			t.Errorf("GetRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+
+	info, err := s.Stat("albums/paris/IMG 2.jpg")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Key != "albums/paris/IMG 2.jpg" || info.Size != 3 || info.ModTime.IsZero() {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	listTests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"albums/paris.txt", "albums/paris/IMG 2.jpg", "albums/paris/IMG_1.jpg", "albums/rome/IMG_1.jpg", "other"}},
+		{"albums/paris", []string{"albums/paris.txt", "albums/paris/IMG 2.jpg", "albums/paris/IMG_1.jpg"}},
+		{"albums/paris/", []string{"albums/paris/IMG 2.jpg", "albums/paris/IMG_1.jpg"}},
+		{"albums/paris/IMG_", []string{"albums/paris/IMG_1.jpg"}},
+		{"nothing", []string{}},
+		{"nothing/", []string{}},
+		{"albums/paris.txt/", []string{}},
+		{"other/x/", []string{}},
+		{"../", []string{}},
+	}
+	for _, tt := range listTests {
+		objects, err := s.List(tt.prefix)
+		if err != nil {
+			t.Fatalf("List(%s) error = %v", tt.prefix, err)
+		}
+		keys := []string{}
+		for _, o := range objects {
+			keys = append(keys, o.Key)
+		}
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+
+	if err := s.Delete("albums/rome/IMG_1.jpg"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete("albums/rome/IMG_1.jpg"); err != nil {
+		t.Errorf("Delete() of missing object error = %v, want nil", err)
+	}
+	if _, err := s.Get("albums/rome/IMG_1.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of deleted object error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat("albums/rome/IMG_1.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() of deleted object error = %v, want ErrNotFound", err)
+	}
+	if objects, _ := s.List("albums/rome"); len(objects) != 0 {
+		t.Errorf("List() after Delete() = %v, want empty", objects)
+	}
+
+	for _, key := range []string{"", "/abs", "a//b", "a/../b", "./a", "a/"} {
+		if err := s.Put(key, strings.NewReader(""), 0); err == nil {
+			t.Errorf("Put(%q) error = nil, want error", key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, loc := range []string{dir, "file://" + dir} {
+		s, err := Open(loc, "", "")
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", loc, err)
+		}
+		if _, ok := s.(*Local); !ok {
+			t.Errorf("Open(%s) = %T, want *Local", loc, s)
+		}
+	}
+	s, err := Open("s3://photos?endpoint=http://localhost:9000&region=eu-west-1&path_style=true", "a", "b")
+	if err != nil {
+		t.Fatalf("Open(s3) error = %v", err)
+	}
+	if u := s.(*S3).objectURL("x/y.jpg").String(); u != "http://localhost:9000/photos/x/y.jpg" {
+		t.Errorf("objectURL() = %s", u)
+	}
+	s, err = Open("s3://photos", "a", "b")
+	if err != nil {
+		t.Fatalf("Open(s3) error = %v", err)
+	}
+	if u := s.(*S3).objectURL("y.jpg").String(); u != "https://photos.s3.us-east-1.amazonaws.com/y.jpg" {
+		t.Errorf("objectURL() = %s", u)
+	}
+	if _, err := Open("s3://photos", "", ""); err == nil {
+		t.Errorf("Open(s3) without credentials error = nil, want error")
+	}
+	if _, err := Open("ftp://host/dir", "", ""); err == nil {
+		t.Errorf("Open(ftp) error = nil, want error")
+	}
+}
@@ -0,0 +1,77 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+// IPTC IIM datasets read by parseIPTC, as record<<8 | dataset.
+const (
+	iptcCharset    = 1<<8 | 90
+	iptcObjectName = 2<<8 | 5
+	iptcKeywords   = 2<<8 | 25
+	iptcCaption    = 2<<8 | 120
+)
+
+// utf8Charset is the value of the coded character set dataset for UTF-8.
+const utf8Charset = "\x1b%G"
+
+// parseIPTC parses IPTC IIM data. The object name and caption become texts in
+// the default language. Values are decoded as UTF-8 if the data declares it or
+// if they are valid UTF-8, and as ISO 8859-1 otherwise. Parsing stops at the
+// first malformed dataset.
+func parseIPTC(data []byte) *Metadata {
+	m := newMetadata()
+	isUTF8 := false
+	for len(data) >= 5 && data[0] == 0x1C {
+		tag := int(data[1])<<8 | int(data[2])
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+		if size&0x8000 != 0 {
+			// Extended dataset: the low bits are the size of the size.
+			n := size & 0x7FFF
+			if n > 4 || len(data) < n {
+				break
+			}
+			size = 0
+			for _, b := range data[:n] {
+				size = size<<8 | int(b)
+			}
+			data = data[n:]
+		}
+		if size > len(data) {
+			break
+		}
+		value := data[:size]
+		data = data[size:]
+		switch tag {
+		case iptcCharset:
+			isUTF8 = bytes.Equal(value, []byte(utf8Charset))
+		case iptcObjectName:
+			if s := strings.TrimSpace(iptcString(value, isUTF8)); s != "" {
+				m.Title[DefaultLanguage] = s
+			}
+		case iptcCaption:
+			if s := strings.TrimSpace(iptcString(value, isUTF8)); s != "" {
+				m.Description[DefaultLanguage] = s
+			}
+		case iptcKeywords:
+			m.addKeyword(iptcString(value, isUTF8))
+		}
+	}
+	return m
+}
+
+// iptcString decodes an IPTC string value.
+func iptcString(b []byte, isUTF8 bool) string {
+	if isUTF8 || utf8.Valid(b) {
+		return string(b)
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,419 @@
+// Package xmp extracts descriptive metadata (titles, captions, keywords and
+// ratings) from XMP sidecar files and from XMP and IPTC data embedded in JPEG
+// and TIFF files, as written by Lightroom and similar tools.
+package xmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language of XMP language alternatives that applies
+// when no other language matches.
+const DefaultLanguage = "x-default"
+
+// Metadata holds the descriptive metadata of a photo.
+type Metadata struct {
+	// Title maps xml:lang language codes (DefaultLanguage for the default) to
+	// titles (dc:title, IPTC Object Name).
+	Title map[string]string
+	// Description maps language codes to captions (dc:description, IPTC Caption/Abstract).
+	Description map[string]string
+	// Keywords are the keywords of the photo (dc:subject, the leaves of
+	// lr:hierarchicalSubject, IPTC Keywords), without duplicates.
+	Keywords []string
+	// Rating is the star rating (xmp:Rating): 1-5, 0 if unrated and -1 if rejected.
+	Rating int
+}
+
+func newMetadata() *Metadata {
+	return &Metadata{Title: map[string]string{}, Description: map[string]string{}, Keywords: []string{}}
+}
+
+// addKeyword adds a keyword unless it is empty or already present.
+func (m *Metadata) addKeyword(k string) {
+	k = strings.TrimSpace(k)
+	if k == "" {
+		return
+	}
+	for _, x := range m.Keywords {
+		if x == k {
+			return
+		}
+	}
+	m.Keywords = append(m.Keywords, k)
+}
+
+// fill sets the fields of m that are unset from other.
+func (m *Metadata) fill(other *Metadata) {
+	if len(m.Title) == 0 {
+		m.Title = other.Title
+	}
+	if len(m.Description) == 0 {
+		m.Description = other.Description
+	}
+	if len(m.Keywords) == 0 {
+		m.Keywords = other.Keywords
+	}
+	if m.Rating == 0 {
+		m.Rating = other.Rating
+	}
+}
+
+// ErrUnsupported is returned for files that are neither JPEG nor TIFF.
+var ErrUnsupported = errors.New("xmp: unsupported file format")
+
+// SidecarPaths returns the paths where the XMP sidecar of the photo at path may
+// be, in order of preference: "IMG_1234.jpg.xmp" and "IMG_1234.xmp".
+func SidecarPaths(path string) []string {
+	return []string{path + ".xmp", strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"}
+}
+
+// ReadSidecar returns the path and contents of the XMP sidecar of the photo at
+// path (see SidecarPaths), or "" and nil if it has none.
+func ReadSidecar(path string) (string, []byte, error) {
+	for _, fn := range SidecarPaths(path) {
+		data, err := os.ReadFile(fn)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+		return fn, data, nil
+	}
+	return "", nil, nil
+}
+
+// ReadFile reads the metadata of the photo at path from its XMP sidecar, if any,
+// and from the XMP and IPTC data embedded in the photo. Fields set in the sidecar
+// take precedence over embedded ones, and embedded XMP over IPTC. Photos in formats
+// other than JPEG and TIFF only get metadata from their sidecar.
+func ReadFile(path string) (*Metadata, error) {
+	m := newMetadata()
+	fn, data, err := ReadSidecar(path)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		if m, err = Parse(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	embedded, err := Decode(f)
+	if errors.Is(err, ErrUnsupported) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.fill(embedded)
+	return m, nil
+}
+
+// Decode reads the XMP and IPTC metadata embedded in a JPEG or TIFF image. An
+// image without such metadata is not an error: the result is then empty.
+func Decode(r io.ReaderAt) (*Metadata, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, ErrUnsupported
+	}
+	var packet, iptc []byte
+	var err error
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		packet, iptc, err = scanJPEG(r)
+	case string(magic[:]) == "II*\x00" || string(magic[:]) == "MM\x00*":
+		packet, iptc, err = scanTIFF(r)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := newMetadata()
+	if packet != nil {
+		if m, err = Parse(packet); err != nil {
+			return nil, err
+		}
+	}
+	if iptc != nil {
+		m.fill(parseIPTC(iptc))
+	}
+	return m, nil
+}
+
+// Namespaces of the XMP properties read by Parse.
+const (
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+	nsXMP = "http://ns.adobe.com/xap/1.0/"
+	nsLR  = "http://ns.adobe.com/lightroom/1.0/"
+	nsXML = "http://www.w3.org/XML/1998/namespace"
+)
+
+var (
+	rdfDescription = xml.Name{Space: nsRDF, Local: "Description"}
+	rdfLi          = xml.Name{Space: nsRDF, Local: "li"}
+	dcTitle        = xml.Name{Space: nsDC, Local: "title"}
+	dcDescription  = xml.Name{Space: nsDC, Local: "description"}
+	dcSubject      = xml.Name{Space: nsDC, Local: "subject"}
+	lrHierarchical = xml.Name{Space: nsLR, Local: "hierarchicalSubject"}
+	xmpRating      = xml.Name{Space: nsXMP, Local: "Rating"}
+	xmlLang        = xml.Name{Space: nsXML, Local: "lang"}
+)
+
+// item is an rdf:li item of an XMP array.
+type item struct {
+	lang, text string
+}
+
+// Parse parses an XMP packet (or the contents of an XMP sidecar file). Properties
+// may be written as elements of rdf:Description or, for simple values such as
+// xmp:Rating, as its attributes.
+func Parse(data []byte) (*Metadata, error) {
+	m := newMetadata()
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		stack     []xml.Name
+		prop      *xml.Name // Property being read.
+		propDepth int       // Depth of the property element.
+		text      strings.Builder
+		items     []item
+		li        *item
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("xmp: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case prop == nil && t.Name == rdfDescription:
+				for _, a := range t.Attr {
+					m.set(a.Name, a.Value, nil)
+				}
+			case prop == nil && len(stack) > 0 && stack[len(stack)-1] == rdfDescription:
+				prop, propDepth = &t.Name, len(stack)
+				text.Reset()
+				items = nil
+			case prop != nil && t.Name == rdfLi:
+				li = &item{}
+				for _, a := range t.Attr {
+					if a.Name == xmlLang {
+						li.lang = a.Value
+					}
+				}
+				text.Reset()
+			}
+			stack = append(stack, t.Name)
+		case xml.CharData:
+			if prop != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			switch {
+			case li != nil && t.Name == rdfLi:
+				li.text = text.String()
+				items = append(items, *li)
+				li = nil
+			case prop != nil && len(stack) == propDepth:
+				m.set(*prop, text.String(), items)
+				prop = nil
+			}
+		}
+	}
+	return m, nil
+}
+
+// set sets the property name from its value: its items if it is an array, or
+// its text otherwise. Unknown properties are ignored.
+func (m *Metadata) set(name xml.Name, text string, items []item) {
+	switch name {
+	case dcTitle, dcDescription:
+		dst := m.Title
+		if name == dcDescription {
+			dst = m.Description
+		}
+		for _, it := range items {
+			lang := it.lang
+			if lang == "" {
+				lang = DefaultLanguage
+			}
+			if s := strings.TrimSpace(it.text); s != "" {
+				dst[lang] = s
+			}
+		}
+	case dcSubject:
+		for _, it := range items {
+			m.addKeyword(it.text)
+		}
+	case lrHierarchical:
+		// Keywords are "PARENT|CHILD"; only the leaf is kept.
+		for _, it := range items {
+			m.addKeyword(it.text[strings.LastIndex(it.text, "|")+1:])
+		}
+	case xmpRating:
+		if r, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil && r >= -1 && r <= 5 {
+			m.Rating = int(r)
+		}
+	}
+}
+
+// JPEG markers and APP segment identifiers.
+const (
+	markerSOS  = 0xDA
+	markerEOI  = 0xD9
+	markerAPP1 = 0xE1
+	// markerAPP13 holds Photoshop image resources, which include IPTC data.
+	markerAPP13 = 0xED
+	xmpHeader   = "http://ns.adobe.com/xap/1.0/\x00"
+	psHeader    = "Photoshop 3.0\x00"
+)
+
+// scanJPEG returns the XMP packet and IPTC data of a JPEG image, or nil if absent.
+func scanJPEG(r io.ReaderAt) (packet, iptc []byte, err error) {
+	off := int64(2)
+	var hdr [4]byte
+	for {
+		if _, err := r.ReadAt(hdr[:2], off); err != nil {
+			return nil, nil, fmt.Errorf("xmp: truncated JPEG: %v", err)
+		}
+		if hdr[0] != 0xFF {
+			return nil, nil, fmt.Errorf("xmp: invalid JPEG marker at offset %d", off)
+		}
+		marker := hdr[1]
+		switch {
+		case marker == 0xFF:
+			off++
+			continue
+		case marker == markerSOS || marker == markerEOI:
+			return packet, iptc, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8):
+			off += 2
+			continue
+		}
+		if _, err := r.ReadAt(hdr[2:4], off+2); err != nil {
+			return nil, nil, fmt.Errorf("xmp: truncated JPEG: %v", err)
+		}
+		length := int64(binary.BigEndian.Uint16(hdr[2:4]))
+		if length < 2 {
+			return nil, nil, fmt.Errorf("xmp: invalid JPEG segment length at offset %d", off)
+		}
+		if marker == markerAPP1 || marker == markerAPP13 {
+			data := make([]byte, length-2)
+			if _, err := r.ReadAt(data, off+4); err != nil {
+				return nil, nil, fmt.Errorf("xmp: truncated JPEG: %v", err)
+			}
+			if p, ok := bytes.CutPrefix(data, []byte(xmpHeader)); ok && marker == markerAPP1 && packet == nil {
+				packet = p
+			} else if p, ok := bytes.CutPrefix(data, []byte(psHeader)); ok && marker == markerAPP13 && iptc == nil {
+				iptc = photoshopIPTC(p)
+			}
+		}
+		off += 2 + length
+	}
+}
+
+// photoshopIPTC returns the IPTC data among Photoshop image resources, or nil if absent.
+func photoshopIPTC(data []byte) []byte {
+	const iptcResource = 0x0404
+	for len(data) >= 4+2+2+4 && string(data[:4]) == "8BIM" {
+		id := binary.BigEndian.Uint16(data[4:6])
+		// The resource name is a Pascal string, padded to an even size.
+		nameLen := int(data[6])
+		nameLen += (nameLen + 1) % 2
+		if len(data) < 7+nameLen+4 {
+			return nil
+		}
+		data = data[7+nameLen:]
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		data = data[4:]
+		if size > len(data) {
+			return nil
+		}
+		if id == iptcResource {
+			return data[:size]
+		}
+		// The pad byte of a final resource of odd size may be missing.
+		data = data[min(size+size%2, len(data)):]
+	}
+	return nil
+}
+
+// TIFF tags holding XMP and IPTC data.
+const (
+	tagXMP  = 700
+	tagIPTC = 33723
+)
+
+// Limits that protect against corrupt or malicious files.
+const (
+	maxIFDEntries = 1000
+	maxValueSize  = 16 << 20
+)
+
+// scanTIFF returns the XMP packet and IPTC data in IFD0 of a TIFF image, or nil if absent.
+func scanTIFF(r io.ReaderAt) (packet, iptc []byte, err error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, nil, fmt.Errorf("xmp: truncated TIFF header: %v", err)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if hdr[0] == 'I' {
+		order = binary.LittleEndian
+	}
+	off := int64(order.Uint32(hdr[4:8]))
+	var buf [12]byte
+	if _, err := r.ReadAt(buf[:2], off); err != nil {
+		return nil, nil, fmt.Errorf("xmp: truncated IFD at offset %d", off)
+	}
+	n := int(order.Uint16(buf[:2]))
+	if n > maxIFDEntries {
+		return nil, nil, fmt.Errorf("xmp: too many IFD entries at offset %d", off)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := r.ReadAt(buf[:], off+2+int64(i)*12); err != nil {
+			return nil, nil, fmt.Errorf("xmp: truncated IFD at offset %d", off)
+		}
+		tag := order.Uint16(buf[0:2])
+		if tag != tagXMP && tag != tagIPTC {
+			continue
+		}
+		// Both tags are arrays of bytes, although IPTC data is often typed as LONG.
+		size := int64(order.Uint32(buf[4:8]))
+		if order.Uint16(buf[2:4]) == 4 {
+			size *= 4
+		}
+		if size > maxValueSize {
+			return nil, nil, fmt.Errorf("xmp: IFD value too large for tag %d", tag)
+		}
+		data := make([]byte, size)
+		if size <= 4 {
+			copy(data, buf[8:8+size])
+		} else if _, err := r.ReadAt(data, int64(order.Uint32(buf[8:12]))); err != nil {
+			return nil, nil, fmt.Errorf("xmp: truncated value for tag %d", tag)
+		}
+		if tag == tagXMP {
+			packet = data
+		} else {
+			iptc = data
+		}
+	}
+	return packet, iptc, nil
+}
@@ -0,0 +1,244 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// lightroomPacket is an XMP packet as written by Lightroom Classic.
+const lightroomPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Adobe XMP Core 7.0-c000">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:lr="http://ns.adobe.com/lightroom/1.0/"
+   xmp:Rating="4">
+   <dc:title>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default">Eiffel Tower</rdf:li>
+     <rdf:li xml:lang="fr">La tour Eiffel</rdf:li>
+    </rdf:Alt>
+   </dc:title>
+   <dc:description>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default"> From Trocadéro </rdf:li>
+    </rdf:Alt>
+   </dc:description>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>tower</rdf:li>
+     <rdf:li>Paris</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+   <lr:hierarchicalSubject>
+    <rdf:Bag>
+     <rdf:li>Places|France|Paris</rdf:li>
+     <rdf:li>Events|Night</rdf:li>
+    </rdf:Bag>
+   </lr:hierarchicalSubject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(lightroomPacket))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := &Metadata{
+		Title:       map[string]string{"x-default": "Eiffel Tower", "fr": "La tour Eiffel"},
+		Description: map[string]string{"x-default": "From Trocadéro"},
+		Keywords:    []string{"tower", "Paris", "Night"},
+		Rating:      4,
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Parse() = %+v, want %+v", m, want)
+	}
+
+	// Simple properties may also be elements.
+	m, err = Parse([]byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/"><xmp:Rating>-1</xmp:Rating></rdf:Description>
+	</rdf:RDF>`))
+	if err != nil || m.Rating != -1 {
+		t.Errorf("Parse() with rating element = %+v, %v, want rating -1", m, err)
+	}
+
+	if _, err := Parse([]byte("<rdf:RDF><rdf:Description>")); err == nil {
+		t.Errorf("Parse() with truncated XML error = nil, want error")
+	}
+}
+
+// iptcDataset encodes an IPTC IIM dataset.
+func iptcDataset(record, dataset byte, value string) []byte {
+	b := []byte{0x1C, record, dataset, 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(value)))
+	return append(b, value...)
+}
+
+func TestParseIPTC(t *testing.T) {
+	var data []byte
+	data = append(data, iptcDataset(2, 5, "Tour Eiffel")...)
+	data = append(data, iptcDataset(2, 120, "Vue du Trocad\xe9ro")...) // ISO 8859-1
+	data = append(data, iptcDataset(2, 25, "tower")...)
+	data = append(data, iptcDataset(2, 25, "night")...)
+	data = append(data, iptcDataset(2, 25, "tower")...)
+	m := parseIPTC(data)
+	want := &Metadata{
+		Title:       map[string]string{"x-default": "Tour Eiffel"},
+		Description: map[string]string{"x-default": "Vue du Trocadéro"},
+		Keywords:    []string{"tower", "night"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("parseIPTC() = %+v, want %+v", m, want)
+	}
+
+	// Truncated data keeps what was parsed so far.
+	m = parseIPTC(append(iptcDataset(2, 25, "tower"), 0x1C, 2, 5, 0, 100, 'x'))
+	if len(m.Keywords) != 1 || len(m.Title) != 0 {
+		t.Errorf("parseIPTC() with truncated data = %+v", m)
+	}
+}
+
+func TestPhotoshopIPTC(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"IPTC", "8BIM\x04\x04\x00\x00\x00\x00\x00\x02IP", "IP"},
+		{"After odd-sized resource", "8BIM\x04\x05\x00\x00\x00\x00\x00\x01X\x008BIM\x04\x04\x00\x00\x00\x00\x00\x02IP", "IP"},
+		{"Named resource", "8BIM\x04\x04\x01N\x00\x00\x00\x02IP", "IP"},
+		{"Odd-sized final resource without padding", "8BIM\x04\x05\x00\x00\x00\x00\x00\x01X", ""},
+		{"Truncated resource", "8BIM\x04\x04\x00\x00\x00\x00\x00\x03IP", ""},
+		{"No resources", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := photoshopIPTC([]byte(tt.data)); string(got) != tt.want {
+				t.Errorf("photoshopIPTC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// jpegSegment encodes a JPEG marker segment.
+func jpegSegment(marker byte, payload []byte) []byte {
+	b := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+// testJPEG returns a JPEG file (without image data) with an XMP packet and IPTC data.
+func testJPEG(packet string, iptc []byte) []byte {
+	b := []byte{0xFF, 0xD8}
+	b = append(b, jpegSegment(0xE0, []byte("JFIF\x00\x01\x02"))...)
+	if packet != "" {
+		b = append(b, jpegSegment(0xE1, append([]byte(xmpHeader), packet...))...)
+	}
+	if iptc != nil {
+		res := []byte("8BIM\x04\x04\x00\x00\x00\x00\x00\x00")
+		binary.BigEndian.PutUint32(res[8:], uint32(len(iptc)))
+		res = append(res, iptc...)
+		if len(iptc)%2 != 0 {
+			res = append(res, 0)
+		}
+		b = append(b, jpegSegment(0xED, append([]byte(psHeader), res...))...)
+	}
+	return append(b, 0xFF, 0xD9)
+}
+
+// testTIFF returns a little-endian TIFF file (without image data) with an XMP packet.
+func testTIFF(packet string) []byte {
+	b := []byte("II*\x00\x08\x00\x00\x00")
+	entry := make([]byte, 2+12+4)
+	binary.LittleEndian.PutUint16(entry[0:], 1)
+	binary.LittleEndian.PutUint16(entry[2:], tagXMP)
+	binary.LittleEndian.PutUint16(entry[4:], 1) // BYTE
+	binary.LittleEndian.PutUint32(entry[6:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(entry[10:], uint32(8+len(entry)))
+	return append(append(b, entry...), packet...)
+}
+
+func TestDecode(t *testing.T) {
+	iptc := append(iptcDataset(2, 5, "IPTC title"), iptcDataset(2, 120, "IPTC caption")...)
+	tests := []struct {
+		name            string
+		data            []byte
+		wantTitle       string
+		wantDescription string
+	}{
+		{"jpeg", testJPEG(lightroomPacket, iptc), "Eiffel Tower", "From Trocadéro"},
+		{"jpeg iptc", testJPEG("", iptc), "IPTC title", "IPTC caption"},
+		{"jpeg none", testJPEG("", nil), "", ""},
+		{"tiff", testTIFF(lightroomPacket), "Eiffel Tower", "From Trocadéro"},
+	}
+	for _, tt := range tests {
+		m, err := Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("Decode(%s) error = %v", tt.name, err)
+			continue
+		}
+		if m.Title[DefaultLanguage] != tt.wantTitle || m.Description[DefaultLanguage] != tt.wantDescription {
+			t.Errorf("Decode(%s) = %+v, want title %q and description %q", tt.name, m, tt.wantTitle, tt.wantDescription)
+		}
+	}
+	if _, err := Decode(bytes.NewReader([]byte("\x89PNG\r\n"))); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Decode(png) error = %v, want ErrUnsupported", err)
+	}
+	if _, err := Decode(bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10})); err == nil {
+		t.Errorf("Decode(truncated jpeg) error = nil, want error")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(path, testJPEG(lightroomPacket, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadFile(path)
+	if err != nil || m.Title[DefaultLanguage] != "Eiffel Tower" || m.Rating != 4 {
+		t.Fatalf("ReadFile() = %+v, %v", m, err)
+	}
+
+	// Sidecar fields override embedded ones; others are kept.
+	sidecar := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<dc:title><rdf:Alt><rdf:li xml:lang="x-default">From the sidecar</rdf:li></rdf:Alt></dc:title>
+		</rdf:Description>
+	</rdf:RDF>`
+	if err := os.WriteFile(filepath.Join(dir, "a.xmp"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err = ReadFile(path)
+	if err != nil || !reflect.DeepEqual(m.Title, map[string]string{"x-default": "From the sidecar"}) ||
+		m.Description[DefaultLanguage] != "From Trocadéro" || m.Rating != 4 {
+		t.Errorf("ReadFile() with sidecar = %+v, %v", m, err)
+	}
+
+	// Photos in other formats only have sidecar metadata.
+	png := filepath.Join(dir, "b.png")
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(png+".xmp", []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ReadFile(png); err != nil || m.Title[DefaultLanguage] != "From the sidecar" {
+		t.Errorf("ReadFile(%s) = %+v, %v", png, m, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.xmp"), []byte("<rdf:RDF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Errorf("ReadFile() with malformed sidecar error = nil, want error")
+	}
+}